@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actions shared by most entities; handlers use more specific ones (void, send, payment...) where they apply
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// ignoredFields change on every save and would only add noise to diffs
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// maxValueLength is the longest string kept verbatim; longer values such as base64 logos are
+// replaced by a digest, which still shows that they changed
+const maxValueLength = 1024
+
+// State is a JSON snapshot of an entity. Fields tagged json:"-", such as password hashes, are never captured.
+type State map[string]interface{}
+
+// Change is the before and after value of one field
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Snapshot captures v as it is now, so later changes to v don't leak into the "before" side of a diff
+func Snapshot(v interface{}) State {
+	if v == nil {
+		return nil
+	}
+	if state, ok := v.(State); ok {
+		return state
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil
+	}
+	decoded = shortenValues(decoded)
+	if state, ok := decoded.(map[string]interface{}); ok {
+		return state
+	}
+	return State{"value": decoded}
+}
+
+// shortenValues replaces overlong strings at any depth with their length and digest
+func shortenValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxValueLength {
+			sum := sha256.Sum256([]byte(v))
+			return fmt.Sprintf("<%d bytes, sha256 %s>", len(v), hex.EncodeToString(sum[:8]))
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = shortenValues(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = shortenValues(child)
+		}
+	}
+	return value
+}
+
+// Diff returns the fields whose values differ between two snapshots. Nested objects and
+// lists, such as order lines, are compared and reported as a whole.
+func Diff(before, after State) map[string]Change {
+	changes := make(map[string]Change)
+	for key, old := range before {
+		if ignoredFields[key] {
+			continue
+		}
+		if current, ok := after[key]; !ok || !reflect.DeepEqual(old, current) {
+			changes[key] = Change{Before: old, After: after[key]}
+		}
+	}
+	for key, current := range after {
+		if ignoredFields[key] {
+			continue
+		}
+		if _, ok := before[key]; !ok {
+			changes[key] = Change{After: current}
+		}
+	}
+	return changes
+}
+
+// Record writes an audit entry for a change to an entity. db should be the transaction that made
+// the change so the entry commits or rolls back with it. before and after may be entities, snapshots
+// taken with Snapshot, or nil for creates and deletes respectively.
+func Record(db *gorm.DB, c *gin.Context, action, entityType string, entityID uint, before, after interface{}) error {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CreatedAt:  time.Now(),
+	}
+
+	if before != nil || after != nil {
+		changes, err := json.Marshal(Diff(Snapshot(before), Snapshot(after)))
+		if err != nil {
+			return err
+		}
+		entry.Changes = models.JSONText(changes)
+	}
+
+	if c != nil {
+		if userID, ok := c.Get("user_id"); ok {
+			if id, ok := userID.(uint); ok {
+				entry.ActorID = &id
+			}
+		}
+		entry.ActorEmail = c.GetString("user_email")
+		if keyID, ok := c.Get("api_key_id"); ok {
+			if id, ok := keyID.(uint); ok {
+				entry.APIKeyID = &id
+			}
+		}
+		entry.IPAddress = c.ClientIP()
+	}
+
+	return db.Create(&entry).Error
+}
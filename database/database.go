@@ -3,8 +3,11 @@ package database
 import (
 	"fmt"
 	"inventory_system/models"
+	"inventory_system/money"
+	"inventory_system/permissions"
 	"log"
 	"os"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
@@ -66,6 +69,14 @@ func InitDatabase() {
 	// Auto migrate the schema first
 	err = DB.AutoMigrate(
 		&models.User{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.UserSession{},
+		&models.TwoFactorRecoveryCode{},
+		&models.PasswordResetToken{},
+		&models.APIKey{},
+		&models.APIKeyScope{},
+		&models.Invitation{},
 		&models.Product{},
 		&models.Supplier{},
 		&models.ProductSupplier{},
@@ -73,11 +84,36 @@ func InitDatabase() {
 		&models.Sale{},
 		&models.SaleItem{},
 		&models.SalePayment{},
+		&models.Shift{},
+		&models.CashMovement{},
+		&models.ShiftTotal{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.StockReservation{},
+		&models.Quotation{},
+		&models.QuotationItem{},
+		&models.SalesOrder{},
+		&models.SalesOrderItem{},
+		&models.DeliveryNote{},
+		&models.DeliveryNoteItem{},
 		&models.PurchaseOrder{},
 		&models.PurchaseOrderItem{},
 		&models.PurchasePayment{},
+		&models.PurchaseOrderSendLog{},
+		&models.PurchaseReturn{},
+		&models.PurchaseReturnItem{},
+		&models.SupplierCreditNote{},
+		&models.SupplierCreditApplication{},
+		&models.ExchangeRate{},
+		&models.RFQ{},
+		&models.RFQItem{},
+		&models.RFQSupplier{},
+		&models.RFQQuote{},
 		&models.ActivityLog{},
+		&models.AuditLog{},
 		&models.CompanyProfile{},
+		&models.ProductImportJob{},
+		&models.SchemaMigration{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
@@ -85,10 +121,174 @@ func InitDatabase() {
 
 	log.Println("Database schema migration completed")
 
-	// Create default admin user
+	runMigration("backfill_base_currency_amounts_v2", backfillBaseCurrencyAmounts)
+	runMigration("normalize_money_amounts", normalizeMoneyAmounts)
+
+	// Seed the built-in roles and default admin user
+	seedDefaultRoles()
 	createDefaultAdmin()
 }
 
+// runMigration runs a one-off data migration in a transaction unless it has run before, and records
+// it as applied. A failed migration is rolled back and tried again on the next startup.
+func runMigration(id string, migrate func(tx *gorm.DB) error) {
+	var applied int64
+	if err := DB.Model(&models.SchemaMigration{}).Where("id = ?", id).Count(&applied).Error; err != nil {
+		log.Printf("Error checking migration %s: %v", id, err)
+		return
+	}
+	if applied > 0 {
+		return
+	}
+
+	tx := DB.Begin()
+	if err := migrate(tx); err != nil {
+		tx.Rollback()
+		log.Printf("Migration %s failed: %v", id, err)
+		return
+	}
+	if err := tx.Create(&models.SchemaMigration{ID: id, AppliedAt: time.Now()}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Error recording migration %s: %v", id, err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Error committing migration %s: %v", id, err)
+		return
+	}
+	log.Printf("Applied migration %s", id)
+}
+
+// backfillBaseCurrencyAmounts fills in the currency and base amounts of purchase records created
+// before purchase orders had a currency. They were all in the base currency, and the columns added
+// for them are NULL on those rows.
+func backfillBaseCurrencyAmounts(tx *gorm.DB) error {
+	base := activeBaseCurrency()
+
+	if err := tx.Model(&models.PurchaseOrder{}).Unscoped().Where("currency IS NULL OR currency = ''").
+		Updates(map[string]interface{}{"currency": base, "exchange_rate": 1, "total_base": gorm.Expr("total")}).Error; err != nil {
+		return fmt.Errorf("backfilling purchase order currencies: %w", err)
+	}
+	if err := tx.Model(&models.PurchaseOrder{}).Unscoped().Where("total_base IS NULL").
+		Update("total_base", gorm.Expr("total * COALESCE(exchange_rate, 1)")).Error; err != nil {
+		return fmt.Errorf("backfilling purchase order base totals: %w", err)
+	}
+	if err := tx.Model(&models.PurchasePayment{}).Where("COALESCE(amount_base, 0) = 0 AND amount <> 0").
+		Update("amount_base", gorm.Expr("amount * COALESCE(exchange_rate, 1)")).Error; err != nil {
+		return fmt.Errorf("backfilling purchase payment base amounts: %w", err)
+	}
+	if err := tx.Model(&models.PurchasePayment{}).Where("fx_gain_loss IS NULL").
+		Update("fx_gain_loss", 0).Error; err != nil {
+		return fmt.Errorf("backfilling purchase payment exchange differences: %w", err)
+	}
+	if err := tx.Model(&models.SupplierCreditNote{}).Where("currency IS NULL OR currency = ''").
+		Update("currency", base).Error; err != nil {
+		return fmt.Errorf("backfilling credit note currencies: %w", err)
+	}
+	return nil
+}
+
+// activeBaseCurrency returns the currency of the active company profile, IDR without one
+func activeBaseCurrency() string {
+	var profile models.CompanyProfile
+	if err := DB.Where("is_active = ?", true).First(&profile).Error; err == nil && profile.Currency != "" {
+		return profile.Currency
+	}
+	return "IDR"
+}
+
+// normalizeMoneyAmounts rounds the totals saved while amounts were floating point to the minor unit
+// of their currency, and settles the documents whose amount due was only a rounding residue
+func normalizeMoneyAmounts(tx *gorm.DB) error {
+	base := activeBaseCurrency()
+	scale := money.Scale(base)
+
+	// Totals of documents kept in the base currency; unit prices keep money.UnitScale decimals
+	baseAmounts := []struct {
+		model   interface{}
+		columns []string
+	}{
+		{&models.Sale{}, []string{"subtotal", "tax", "discount", "total", "down_payment", "amount_paid", "amount_due", "change_given"}},
+		{&models.SaleItem{}, []string{"total"}},
+		{&models.SalePayment{}, []string{"amount", "tendered"}},
+		{&models.Quotation{}, []string{"subtotal", "tax", "discount", "total"}},
+		{&models.QuotationItem{}, []string{"total"}},
+		{&models.SalesOrder{}, []string{"subtotal", "tax", "discount", "total"}},
+		{&models.SalesOrderItem{}, []string{"total"}},
+		{&models.Shift{}, []string{"opening_float", "expected_cash", "counted_cash", "cash_variance"}},
+		{&models.ShiftTotal{}, []string{"expected", "counted", "variance"}},
+		{&models.CashMovement{}, []string{"amount"}},
+		{&models.PurchaseOrder{}, []string{"total_base"}},
+		{&models.PurchasePayment{}, []string{"amount_base", "fx_gain_loss"}},
+	}
+	for _, table := range baseAmounts {
+		for _, column := range table.columns {
+			if err := tx.Model(table.model).Unscoped().Where(column+" <> ROUND("+column+", ?)", scale).
+				UpdateColumn(column, gorm.Expr("ROUND("+column+", ?)", scale)).Error; err != nil {
+				return fmt.Errorf("rounding %s amounts: %w", column, err)
+			}
+		}
+	}
+
+	// Purchase amounts are kept in the currency of their order
+	var currencies []string
+	if err := tx.Model(&models.PurchaseOrder{}).Unscoped().Distinct().Pluck("currency", &currencies).Error; err != nil {
+		return fmt.Errorf("listing purchase order currencies: %w", err)
+	}
+	for _, currency := range currencies {
+		scale := money.Scale(currency)
+		for _, column := range []string{"total", "down_payment", "amount_paid", "amount_due"} {
+			if err := tx.Model(&models.PurchaseOrder{}).Unscoped().
+				Where("currency = ? AND "+column+" <> ROUND("+column+", ?)", currency, scale).
+				UpdateColumn(column, gorm.Expr("ROUND("+column+", ?)", scale)).Error; err != nil {
+				return fmt.Errorf("rounding purchase order %s amounts: %w", column, err)
+			}
+		}
+		if err := tx.Model(&models.PurchasePayment{}).
+			Where("purchase_order_id IN (?) AND amount <> ROUND(amount, ?)",
+				tx.Model(&models.PurchaseOrder{}).Unscoped().Select("id").Where("currency = ?", currency), scale).
+			UpdateColumn("amount", gorm.Expr("ROUND(amount, ?)", scale)).Error; err != nil {
+			return fmt.Errorf("rounding purchase payment amounts: %w", err)
+		}
+	}
+
+	// A few hundredths of a cent left due used to count as paid; settle them for good
+	settled := map[string]interface{}{"payment_status": "paid", "amount_due": 0, "paid_date": gorm.Expr("COALESCE(paid_date, updated_at)")}
+	if err := tx.Model(&models.Sale{}).Unscoped().Where("payment_status IN ? AND amount_due <= 0", []string{"pending", "overdue"}).
+		Updates(settled).Error; err != nil {
+		return fmt.Errorf("settling paid sales: %w", err)
+	}
+	if err := tx.Model(&models.PurchaseOrder{}).Unscoped().Where("payment_status IN ? AND amount_due <= 0", []string{"pending", "overdue"}).
+		Updates(settled).Error; err != nil {
+		return fmt.Errorf("settling paid purchase orders: %w", err)
+	}
+	return nil
+}
+
+// seedDefaultRoles creates the built-in roles with their default permissions if missing
+func seedDefaultRoles() {
+	descriptions := map[string]string{
+		"employee":            "Cashiers and warehouse staff",
+		"manager":             "Store and purchasing managers",
+		permissions.AdminRole: "Full system access",
+	}
+
+	for name, granted := range permissions.Defaults {
+		var role models.Role
+		if err := DB.Where("name = ?", name).First(&role).Error; err == nil {
+			continue
+		}
+
+		role = models.Role{Name: name, Description: descriptions[name], IsSystem: true}
+		for _, permission := range granted {
+			role.Permissions = append(role.Permissions, models.RolePermission{Permission: permission})
+		}
+		if err := DB.Create(&role).Error; err != nil {
+			log.Printf("Error creating role %s: %v", name, err)
+		}
+	}
+}
+
 // createDefaultAdmin creates a default admin user if none exists
 func createDefaultAdmin() {
 	var count int64
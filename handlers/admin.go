@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"inventory_system/audit"
 	"inventory_system/database"
 	"inventory_system/models"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 // DashboardStats represents admin dashboard statistics
@@ -20,13 +22,13 @@ type DashboardStats struct {
 	TotalProducts      int64          `json:"total_products"`
 	TotalSales         int64          `json:"total_sales"`
 	TodaySales         int64          `json:"today_sales"`
-	TotalRevenue       float64        `json:"total_revenue"`
-	TodayRevenue       float64        `json:"today_revenue"`
-	TotalProfit        float64        `json:"total_profit"`
-	TodayProfit        float64        `json:"today_profit"`
-	TotalPurchasing    float64        `json:"total_purchasing"`
-	TotalPurchasingPaid float64       `json:"total_purchasing_paid"`
-	TotalPurchasingDue  float64       `json:"total_purchasing_due"`
+	TotalRevenue       decimal.Decimal `json:"total_revenue"`
+	TodayRevenue       decimal.Decimal `json:"today_revenue"`
+	TotalProfit        decimal.Decimal `json:"total_profit" cost:"true"`
+	TodayProfit        decimal.Decimal `json:"today_profit" cost:"true"`
+	TotalPurchasing    decimal.Decimal `json:"total_purchasing"`
+	TotalPurchasingPaid decimal.Decimal `json:"total_purchasing_paid"`
+	TotalPurchasingDue  decimal.Decimal `json:"total_purchasing_due"`
 	LowStockProducts   int64          `json:"low_stock_products"`
 	RecentSales        []models.Sale  `json:"recent_sales"`
 	TopProducts        []ProductStats `json:"top_products"`
@@ -34,16 +36,16 @@ type DashboardStats struct {
 }
 
 type ProductStats struct {
-	ProductID   uint    `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	TotalSold   int     `json:"total_sold"`
-	Revenue     float64 `json:"revenue"`
+	ProductID   uint            `json:"product_id"`
+	ProductName string          `json:"product_name"`
+	TotalSold   int             `json:"total_sold"`
+	Revenue     decimal.Decimal `json:"revenue"`
 }
 
 type SalesChart struct {
-	Date    string  `json:"date"`
-	Sales   int64   `json:"sales"`
-	Revenue float64 `json:"revenue"`
+	Date    string          `json:"date"`
+	Sales   int64           `json:"sales"`
+	Revenue decimal.Decimal `json:"revenue"`
 }
 
 // GetDashboardStats returns admin dashboard statistics
@@ -192,10 +194,10 @@ func GetAdminSalesReport(c *gin.Context) {
 	endDate := c.DefaultQuery("end_date", time.Now().Format("2006-01-02"))
 
 	type SalesReportData struct {
-		Date         string  `json:"date"`
-		TotalSales   int64   `json:"total_sales"`
-		TotalRevenue float64 `json:"total_revenue"`
-		TotalItems   int64   `json:"total_items"`
+		Date         string          `json:"date"`
+		TotalSales   int64           `json:"total_sales"`
+		TotalRevenue decimal.Decimal `json:"total_revenue"`
+		TotalItems   int64           `json:"total_items"`
 	}
 
 	var reportData []SalesReportData
@@ -240,6 +242,15 @@ func UpdateSystemSettings(c *gin.Context) {
 		return
 	}
 
+	tx := database.DB.Begin()
+	if !recordAudit(c, tx, audit.ActionUpdate, "system_settings", 0, nil, settings) {
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to write audit log"})
+		return
+	}
+
 	// In a real application, you would save these to a settings table
 	// For now, we'll just return success
 	c.JSON(http.StatusOK, gin.H{
@@ -314,6 +325,17 @@ func BackupDatabase(c *gin.Context) {
 		return
 	}
 
+	tx := database.DB.Begin()
+	if !recordAudit(c, tx, "backup", "database", 0, nil, gin.H{"filename": backupFilename}) {
+		os.Remove(backupPath)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		os.Remove(backupPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to write audit log"})
+		return
+	}
+
 	// Get file size
 	fileInfo, err := os.Stat(backupPath)
 	var fileSize string
@@ -423,6 +445,16 @@ func RestoreDatabase(c *gin.Context) {
 	// Reinitialize database connection
 	database.InitDatabase()
 
+	// The restore replaced the audit log too, so the restore is recorded in the restored database
+	tx := database.DB.Begin()
+	if !recordAudit(c, tx, "restore", "database", 0, nil, gin.H{"filename": filename}) {
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to write audit log"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Database restored successfully from " + filename,
@@ -524,8 +556,15 @@ func DeleteBackup(c *gin.Context) {
 		return
 	}
 
+	// The audit entry only commits once the file is gone
+	tx := database.DB.Begin()
+	if !recordAudit(c, tx, audit.ActionDelete, "backup", 0, gin.H{"filename": filename}, nil) {
+		return
+	}
+
 	// Delete the backup file
 	if err := os.Remove(backupPath); err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to delete backup file: " + err.Error(),
@@ -533,6 +572,11 @@ func DeleteBackup(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Backup file was deleted but the audit log could not be written"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Backup file deleted successfully: " + filename,
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
+	"inventory_system/audit"
 	"inventory_system/database"
 	"inventory_system/middleware"
 	"inventory_system/models"
@@ -21,18 +25,21 @@ type LoginRequest struct {
 // RegisterRequest represents user registration request
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"` // Checked against the password policy
 	Name     string `json:"name" binding:"required"`
 	Role     string `json:"role"`
 }
 
 // LoginResponse represents login response
 type LoginResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`         // Short-lived access token
+	RefreshToken string      `json:"refresh_token"` // Exchange at /auth/refresh for a new token pair
+	ExpiresIn    int         `json:"expires_in"`    // Access token lifetime in seconds
+	User         models.User `json:"user"`
 }
 
-// Login authenticates a user and returns a JWT token
+// Login checks a user's password and returns a JWT token, or a two-factor challenge to
+// complete at /auth/2fa/verify or /auth/2fa/enroll
 func Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -41,82 +48,222 @@ func Login(c *gin.Context) {
 	}
 
 	var user models.User
-	result := database.DB.Where("email = ? AND is_active = ?", req.Email, true).First(&user)
+	result := database.DB.Where("email = ? AND is_active = ? AND is_service_account = ?", req.Email, true, false).First(&user)
 	if result.Error != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		middleware.RejectTooManyAttempts(c, time.Until(*user.LockedUntil))
+		return
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
+		recordFailedLogin(c, &user)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Email, user.Role)
+	// Users with 2FA, or whose role requires it, get a challenge instead of tokens
+	if twoFactorChallenge(c, &user) {
+		return
+	}
+
+	finishLogin(c, &user, nil)
+}
+
+// publicRegistrationEnabled reports whether anyone may sign up through /auth/register
+func publicRegistrationEnabled() bool {
+	return os.Getenv("ALLOW_PUBLIC_REGISTRATION") == "true"
+}
+
+// isValidRole reports whether role names an existing role
+func isValidRole(role string) bool {
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", role).Count(&count)
+	return count > 0
+}
+
+// accountLockPolicy is the per-account failed login policy: short progressive
+// delays after two failures and a lockout at LOGIN_MAX_ATTEMPTS
+func accountLockPolicy() *middleware.AttemptLimiter {
+	maxAttempts := 5
+	if value, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS")); err == nil && value > 2 {
+		maxAttempts = value
+	}
+	lockoutMinutes := 15
+	if value, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES")); err == nil && value > 0 {
+		lockoutMinutes = value
+	}
+	lockout := time.Duration(lockoutMinutes) * time.Minute
+	return middleware.NewAttemptLimiter(2, maxAttempts, lockout, lockout)
+}
+
+// recordFailedLogin counts a failed password for the account and locks it when the policy says so
+func recordFailedLogin(c *gin.Context, user *models.User) {
+	policy := accountLockPolicy()
+	now := time.Now()
+
+	// Count in the database, so concurrent failures can't overwrite each other's increment; the
+	// count restarts once the last failure is outside the window
+	var failures int
+	err := database.DB.Raw(`UPDATE users SET
+			failed_login_attempts = CASE WHEN last_failed_login_at IS NULL OR last_failed_login_at >= ? THEN failed_login_attempts + 1 ELSE 1 END,
+			last_failed_login_at = ?
+		WHERE id = ?
+		RETURNING failed_login_attempts`, now.Add(-policy.Window), now, user.ID).Scan(&failures).Error
+	if err != nil {
+		fmt.Printf("Failed to record failed login for user %d: %v\n", user.ID, err)
+		return
+	}
+
+	wait, locked := policy.Delay(failures)
+	if wait > 0 {
+		// Never shorten a longer lock set by a concurrent failure
+		database.DB.Model(&models.User{}).
+			Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", user.ID, now.Add(wait)).
+			Update("locked_until", now.Add(wait))
+	}
+
+	if locked {
+		middleware.LogActivity(c, user.ID, "account_locked", "auth", user.ID,
+			fmt.Sprintf("Account locked for %s after %d failed login attempts", wait, failures))
+	}
+}
+
+// UnlockUser clears a user's failed login count and lockout (admin only)
+func UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.CanGrantRole(c, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't manage a user whose role has permissions you don't have"})
 		return
 	}
 
-	response := LoginResponse{
-		Token: token,
-		User:  user,
+	before := audit.Snapshot(user)
+
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
 	}
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    response,
-	})
+	if !recordAudit(c, tx, "unlock", "user", user.ID, before, user) {
+		return
+	}
+
+	tx.Commit()
+
+	adminID, _ := c.Get("user_id")
+	middleware.LogActivity(c, adminID.(uint), "account_unlocked", "users", user.ID, "Unlocked account "+user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully", "user": user})
 }
 
-// Register creates a new user
+// Register creates a new employee account when public registration is enabled
 func Register(c *gin.Context) {
+	if !publicRegistrationEnabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Public registration is disabled. Ask an administrator for an invitation"})
+		return
+	}
+
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		return
+	}
+
+	// Self-registered accounts never get elevated roles
+	createUser(c, req.Email, req.Password, req.Name, "employee")
+}
+
+// CreateUser creates a user with any role within the caller's own permissions (admin only)
+func CreateUser(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
 		return
 	}
 
+	// Set default role if not provided
+	if req.Role == "" {
+		req.Role = "employee"
+	}
+	if !isValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if !middleware.CanGrantRole(c, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't assign a role with permissions you don't have"})
+		return
+	}
+
+	createUser(c, req.Email, req.Password, req.Name, req.Role)
+}
+
+// createUser stores a new active user and writes the response
+func createUser(c *gin.Context, email, password, name, role string) {
+	if err := validatePassword(password, email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Check if user already exists
 	var existingUser models.User
-	result := database.DB.Where("email = ?", req.Email).First(&existingUser)
+	result := database.DB.Where("email = ?", email).First(&existingUser)
 	if result.Error == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 
-	// Set default role if not provided
-	if req.Role == "" {
-		req.Role = "employee"
-	}
-
-	// Validate role
-	if req.Role != "admin" && req.Role != "manager" && req.Role != "employee" {
-		req.Role = "employee"
-	}
-
 	user := models.User{
-		Email:    req.Email,
+		Email:    email,
 		Password: string(hashedPassword),
-		Name:     req.Name,
-		Role:     req.Role,
+		Name:     name,
+		Role:     role,
 		IsActive: true,
 	}
 
-	result = database.DB.Create(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + result.Error.Error()})
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + err.Error()})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "user", user.ID, nil, user) {
 		return
 	}
 
+	tx.Commit()
+
 	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully", "user": user})
 }
 
@@ -164,6 +311,10 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if !middleware.CanGrantRole(c, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't manage a user whose role has permissions you don't have"})
+		return
+	}
 
 	var updateData struct {
 		Name     string `json:"name"`
@@ -176,22 +327,49 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(user)
+
 	if updateData.Name != "" {
 		user.Name = updateData.Name
 	}
-	if updateData.Role != "" && (updateData.Role == "admin" || updateData.Role == "manager" || updateData.Role == "employee") {
+	if updateData.Role != "" {
+		if !isValidRole(updateData.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+		if updateData.Role != user.Role && !middleware.CanGrantRole(c, updateData.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can't assign a role with permissions you don't have"})
+			return
+		}
 		user.Role = updateData.Role
 	}
 	if updateData.IsActive != nil {
 		user.IsActive = *updateData.IsActive
 	}
 
-	result = database.DB.Save(&user)
-	if result.Error != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Save(&user).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	// Deactivated users are signed out everywhere
+	if !user.IsActive {
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user sessions"})
+			return
+		}
+	}
+
+	if !recordAudit(c, tx, audit.ActionUpdate, "user", user.ID, before, user) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -203,17 +381,36 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Delete(&models.User{}, id)
-	if result.Error != nil {
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.CanGrantRole(c, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't manage a user whose role has permissions you don't have"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Delete(&user).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := revokeUserSessions(tx, user.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke user sessions"})
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionDelete, "user", user.ID, user, nil) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
@@ -234,3 +431,52 @@ func GetProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// SetApprovalPIN sets the current manager's PIN for approving POS price overrides
+func SetApprovalPIN(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		PIN      string `json:"pin" binding:"required,min=4,max=12,numeric"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Require the account password so a borrowed session can't set a PIN
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	hashedPIN, err := bcrypt.GenerateFromPassword([]byte(req.PIN), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash PIN"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&user).Update("approval_pin", string(hashedPIN)).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval PIN"})
+		return
+	}
+
+	// The PIN itself is never written to the audit log
+	if !recordAudit(c, tx, "set_approval_pin", "user", user.ID, nil, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Approval PIN updated successfully"})
+}
@@ -0,0 +1,691 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// defaultCartParkMinutes is how long a parked cart is kept when CART_PARK_TTL_MINUTES isn't set
+const defaultCartParkMinutes = 60
+
+// CreateCartRequest starts a cart, optionally with its first lines
+type CreateCartRequest struct {
+	Till         string            `json:"till"`
+	CustomerName string            `json:"customer_name"`
+	Notes        string            `json:"notes"`
+	Items        []SaleItemRequest `json:"items" binding:"omitempty,dive"`
+}
+
+// ParkCartRequest parks a cart; Reserve overrides CART_RESERVE_STOCK for this cart
+type ParkCartRequest struct {
+	Notes   string `json:"notes"`
+	Reserve *bool  `json:"reserve"`
+}
+
+// FinalizeCartRequest is the payment part of a SaleRequest; the lines come from the cart
+type FinalizeCartRequest struct {
+	CustomerName     string                 `json:"customer_name"` // Replaces the cart's customer name when set
+	PaymentMethod    string                 `json:"payment_method"`
+	PaymentDays      int                    `json:"payment_days"`
+	DownPayment      decimal.Decimal        `json:"down_payment"`
+	Tenders          []TenderRequest        `json:"tenders" binding:"omitempty,dive"`
+	Discount         decimal.Decimal        `json:"discount"`
+	Tax              decimal.Decimal        `json:"tax"`
+	OverrideApproval *PriceOverrideApproval `json:"override_approval"`
+}
+
+// cartParkTTL returns how long a parked cart is kept before it expires
+func cartParkTTL() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("CART_PARK_TTL_MINUTES")); err == nil && value > 0 {
+		return time.Duration(value) * time.Minute
+	}
+	return defaultCartParkMinutes * time.Minute
+}
+
+// cartReserveStock reports whether parking a cart reserves its stock by default
+func cartReserveStock() bool {
+	return os.Getenv("CART_RESERVE_STOCK") == "true"
+}
+
+// expireCarts marks parked carts past their expiry as expired and drops their reservations.
+// Expired reservations are already ignored by reservedStock; this only tidies up.
+func expireCarts(db *gorm.DB) {
+	now := time.Now()
+	expired := db.Model(&models.Cart{}).Select("id").Where("status = ? AND expires_at <= ?", "parked", now)
+	db.Where("cart_id IN (?)", expired).Delete(&models.StockReservation{})
+	db.Model(&models.Cart{}).Where("status = ? AND expires_at <= ?", "parked", now).Update("status", "expired")
+}
+
+// reservedStock is the stock of a product held by live reservations, leaving out those of the cart
+// or sales order given (0 for none) so they can use their own. With a product supplier the
+// reservations pinned to it count, and so do unpinned ones, which may be filled from any supplier;
+// without one, every reservation does.
+func reservedStock(db *gorm.DB, productID uint, productSupplierID *uint, cartID, salesOrderID uint) int {
+	query := db.Model(&models.StockReservation{}).
+		Where("product_id = ? AND (expires_at IS NULL OR expires_at > ?)", productID, time.Now())
+	if productSupplierID != nil {
+		query = query.Where("(product_supplier_id = ? OR product_supplier_id IS NULL)", *productSupplierID)
+	}
+	if cartID != 0 {
+		query = query.Where("(cart_id IS NULL OR cart_id <> ?)", cartID)
+	}
+	if salesOrderID != 0 {
+		query = query.Where("(sales_order_id IS NULL OR sales_order_id <> ?)", salesOrderID)
+	}
+
+	var reserved int64
+	query.Select("COALESCE(SUM(quantity), 0)").Scan(&reserved)
+	return int(reserved)
+}
+
+// findProductSupplier finds the active product supplier a line refers to; like sales, supplierID may
+// be either the product supplier ID or the supplier company ID
+func findProductSupplier(db *gorm.DB, productID, supplierID uint) *models.ProductSupplier {
+	var productSupplier models.ProductSupplier
+	err := db.Where("product_id = ? AND (supplier_id = ? OR id = ?) AND is_active = ?", productID, supplierID, supplierID, true).
+		First(&productSupplier).Error
+	if err != nil {
+		return nil
+	}
+	return &productSupplier
+}
+
+// newCartItem validates a line and adds it to a cart inside tx. On failure it rolls tx back,
+// responds and returns false.
+func newCartItem(c *gin.Context, tx *gorm.DB, cartID uint, req SaleItemRequest) (*models.CartItem, bool) {
+	var product models.Product
+	if err := tx.First(&product, req.ProductID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product with ID %d not found", req.ProductID)})
+		return nil, false
+	}
+	if req.SupplierID != nil && findProductSupplier(tx, product.ID, *req.SupplierID) == nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Selected supplier ID %d not found or inactive for product %s", *req.SupplierID, product.Name)})
+		return nil, false
+	}
+
+	item := models.CartItem{
+		CartID:     cartID,
+		ProductID:  product.ID,
+		SupplierID: req.SupplierID,
+		Quantity:   req.Quantity,
+		Price:      req.Price,
+		Cost:       req.Cost,
+	}
+	if err := tx.Create(&item).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add cart item"})
+		return nil, false
+	}
+	item.Product = product
+	return &item, true
+}
+
+// reserveCartStock reserves the stock for every line of a parked cart until expiresAt. On failure it
+// rolls tx back, responds and returns false.
+func reserveCartStock(c *gin.Context, tx *gorm.DB, cart *models.Cart, expiresAt time.Time) bool {
+	// Stock already reserved for earlier lines of this cart
+	heldByProduct := make(map[uint]int)
+	heldBySupplier := make(map[uint]int)
+	heldUnpinned := make(map[uint]int) // Per product, by lines without a supplier
+
+	for _, item := range cart.Items {
+		reservation := models.StockReservation{
+			CartID:    &cart.ID,
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			ExpiresAt: &expiresAt,
+		}
+
+		var product models.Product
+		if err := tx.Preload("Suppliers").First(&product, item.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product with ID %d not found", item.ProductID)})
+			return false
+		}
+
+		var available int
+		if item.SupplierID != nil {
+			productSupplier := findProductSupplier(tx, item.ProductID, *item.SupplierID)
+			if productSupplier == nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Selected supplier ID %d not found or inactive for product %s", *item.SupplierID, product.Name)})
+				return false
+			}
+			reservation.ProductSupplierID = &productSupplier.ID
+			available = productSupplier.Stock - reservedStock(tx, item.ProductID, &productSupplier.ID, cart.ID, 0) - heldBySupplier[productSupplier.ID] - heldUnpinned[item.ProductID]
+		} else {
+			available = product.GetTotalStock() - reservedStock(tx, item.ProductID, nil, cart.ID, 0) - heldByProduct[item.ProductID]
+		}
+		if available < item.Quantity {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Insufficient stock to reserve product %s. Available: %d, Requested: %d", product.Name, available, item.Quantity),
+			})
+			return false
+		}
+
+		if err := tx.Create(&reservation).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+			return false
+		}
+		heldByProduct[item.ProductID] += item.Quantity
+		if reservation.ProductSupplierID != nil {
+			heldBySupplier[*reservation.ProductSupplierID] += item.Quantity
+		} else {
+			heldUnpinned[item.ProductID] += item.Quantity
+		}
+	}
+	return true
+}
+
+// loadCart loads the cart in the :id parameter with its lines and reservations. Carts belong to the
+// till, so any cashier can pick up a cart another one parked.
+func loadCart(c *gin.Context) (*models.Cart, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart ID"})
+		return nil, false
+	}
+
+	expireCarts(database.DB)
+
+	var cart models.Cart
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("Reservations").Preload("User").First(&cart, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		return nil, false
+	}
+	return &cart, true
+}
+
+// reloadCart returns the cart as stored after a change, for responses
+func reloadCart(id uint) models.Cart {
+	var cart models.Cart
+	database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("Reservations").Preload("User").First(&cart, id)
+	return cart
+}
+
+// CreateCart starts a cart for the current user at a till
+func CreateCart(c *gin.Context) {
+	var req CreateCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart := models.Cart{
+		UserID:       c.GetUint("user_id"),
+		Till:         strings.TrimSpace(req.Till),
+		CustomerName: req.CustomerName,
+		Status:       "open",
+		Notes:        req.Notes,
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&cart).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart"})
+		return
+	}
+
+	for _, itemReq := range req.Items {
+		item, ok := newCartItem(c, tx, cart.ID, itemReq)
+		if !ok {
+			return
+		}
+		cart.Items = append(cart.Items, *item)
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "cart", cart.ID, nil, cart) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Cart created successfully", "cart": reloadCart(cart.ID)})
+}
+
+// GetCarts lists carts, by default the parked carts, optionally for one till
+func GetCarts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	expireCarts(database.DB)
+
+	query := database.DB.Model(&models.Cart{})
+
+	if status := c.DefaultQuery("status", "parked"); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if till := c.Query("till"); till != "" {
+		query = query.Where("till = ?", till)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var carts []models.Cart
+	err := query.Preload("Items.Product").Preload("User").
+		Order("COALESCE(parked_at, created_at) ASC").Offset((page - 1) * limit).Limit(limit).Find(&carts).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch carts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"carts": carts,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetCart returns a cart with its lines and reservations
+func GetCart(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// touchOpenCart bumps an open cart's updated_at in tx, holding its row until tx ends so it can't
+// be parked or finalized while its lines change. On failure, or when the cart is no longer open,
+// it rolls tx back, responds and returns false.
+func touchOpenCart(c *gin.Context, tx *gorm.DB, cartID uint) bool {
+	result := tx.Model(&models.Cart{}).Where("id = ? AND status = ?", cartID, "open").Update("updated_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart was changed by someone else"})
+		return false
+	}
+	return true
+}
+
+// AddCartItem adds a line to an open cart
+func AddCartItem(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+
+	var req SaleItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cart.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cart is %s; resume it before changing it", cart.Status)})
+		return
+	}
+
+	tx := database.DB.Begin()
+	if !touchOpenCart(c, tx, cart.ID) {
+		return
+	}
+
+	item, ok := newCartItem(c, tx, cart.ID, req)
+	if !ok {
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "cart_item", item.ID, nil, item) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Item added to cart", "cart": reloadCart(cart.ID)})
+}
+
+// RemoveCartItem removes a line from an open cart
+func RemoveCartItem(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+	if cart.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cart is %s; resume it before changing it", cart.Status)})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var item *models.CartItem
+	for i := range cart.Items {
+		if cart.Items[i].ID == uint(itemID) {
+			item = &cart.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	}
+
+	tx := database.DB.Begin()
+	if !touchOpenCart(c, tx, cart.ID) {
+		return
+	}
+
+	if err := tx.Delete(&models.CartItem{}, item.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove cart item"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionDelete, "cart_item", item.ID, item, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item removed from cart", "cart": reloadCart(cart.ID)})
+}
+
+// ParkCart parks an open cart so the till can serve someone else, optionally reserving its stock
+func ParkCart(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+
+	var req ParkCartRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if cart.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Only open carts can be parked; cart is %s", cart.Status)})
+		return
+	}
+	if len(cart.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	}
+
+	reserve := cartReserveStock()
+	if req.Reserve != nil {
+		reserve = *req.Reserve
+	}
+
+	before := audit.Snapshot(cart)
+	now := time.Now()
+	expiresAt := now.Add(cartParkTTL())
+	notes := cart.Notes
+	if req.Notes != "" {
+		notes = strings.TrimSpace(notes + "\n" + req.Notes)
+	}
+
+	tx := database.DB.Begin()
+
+	// The status condition stops two tills parking or finalizing the same cart at once
+	result := tx.Model(&models.Cart{}).Where("id = ? AND status = ?", cart.ID, "open").Updates(map[string]interface{}{
+		"status":     "parked",
+		"parked_at":  now,
+		"expires_at": expiresAt,
+		"notes":      notes,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to park cart"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart was changed by someone else"})
+		return
+	}
+
+	if reserve && !reserveCartStock(c, tx, cart, expiresAt) {
+		return
+	}
+
+	cart.Status = "parked"
+	cart.ParkedAt = &now
+	cart.ExpiresAt = &expiresAt
+	cart.Notes = notes
+	if !recordAudit(c, tx, "park", "cart", cart.ID, before, cart) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart parked successfully", "cart": reloadCart(cart.ID)})
+}
+
+// ResumeCart reopens a parked or expired cart and releases its reservations
+func ResumeCart(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+	if cart.Status != "parked" && cart.Status != "expired" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Only parked carts can be resumed; cart is %s", cart.Status)})
+		return
+	}
+
+	before := audit.Snapshot(cart)
+
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.Cart{}).Where("id = ? AND status = ?", cart.ID, cart.Status).Updates(map[string]interface{}{
+		"status":     "open",
+		"expires_at": nil,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume cart"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart was changed by someone else"})
+		return
+	}
+
+	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.StockReservation{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reserved stock"})
+		return
+	}
+
+	cart.Status = "open"
+	cart.ExpiresAt = nil
+	cart.Reservations = nil
+	if !recordAudit(c, tx, "resume", "cart", cart.ID, before, cart) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart resumed successfully", "cart": reloadCart(cart.ID)})
+}
+
+// FinalizeCart rings up an open or parked cart as a sale, using its own reservations if it has any
+func FinalizeCart(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+
+	var req FinalizeCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cart.Status != "open" && cart.Status != "parked" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cart is %s and can't be finalized", cart.Status)})
+		return
+	}
+	if len(cart.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	}
+
+	request := SaleRequest{
+		CustomerName:     cart.CustomerName,
+		PaymentMethod:    req.PaymentMethod,
+		PaymentDays:      req.PaymentDays,
+		DownPayment:      req.DownPayment,
+		Tenders:          req.Tenders,
+		Discount:         req.Discount,
+		Tax:              req.Tax,
+		OverrideApproval: req.OverrideApproval,
+	}
+	if req.CustomerName != "" {
+		request.CustomerName = req.CustomerName
+	}
+	for _, item := range cart.Items {
+		request.Items = append(request.Items, SaleItemRequest{
+			ProductID:  item.ProductID,
+			SupplierID: item.SupplierID,
+			Quantity:   item.Quantity,
+			Price:      item.Price,
+			Cost:       item.Cost,
+		})
+	}
+	if !validateSaleRequest(c, &request) {
+		return
+	}
+
+	before := audit.Snapshot(cart)
+
+	tx := database.DB.Begin()
+
+	// Claim the cart first so it can't become two sales
+	result := tx.Model(&models.Cart{}).Where("id = ? AND status = ?", cart.ID, cart.Status).Update("status", "finalized")
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finalize cart"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart was changed by someone else"})
+		return
+	}
+
+	sale, ok := createSaleTx(c, tx, request, cart.ID)
+	if !ok {
+		return
+	}
+
+	if err := tx.Model(&models.Cart{}).Where("id = ?", cart.ID).Updates(map[string]interface{}{
+		"sale_id":       sale.ID,
+		"customer_name": request.CustomerName,
+		"expires_at":    nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finalize cart"})
+		return
+	}
+	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.StockReservation{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reserved stock"})
+		return
+	}
+
+	cart.Status = "finalized"
+	cart.SaleID = &sale.ID
+	cart.CustomerName = request.CustomerName
+	cart.ExpiresAt = nil
+	cart.Reservations = nil
+	if !recordAudit(c, tx, "finalize", "cart", cart.ID, before, cart) {
+		return
+	}
+
+	tx.Commit()
+
+	var completeSale models.Sale
+	database.DB.Preload("Items.Product").Preload("Items.OverrideApprovedBy").Preload("User").Preload("Payments").First(&completeSale, sale.ID)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Cart finalized successfully", "cart_id": cart.ID, "sale": completeSale})
+}
+
+// CancelCart abandons a cart that hasn't been finalized and releases its reservations
+func CancelCart(c *gin.Context) {
+	cart, ok := loadCart(c)
+	if !ok {
+		return
+	}
+	if cart.Status == "finalized" || cart.Status == "cancelled" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cart is already %s", cart.Status)})
+		return
+	}
+
+	before := audit.Snapshot(cart)
+
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.Cart{}).Where("id = ? AND status = ?", cart.ID, cart.Status).Updates(map[string]interface{}{
+		"status":     "cancelled",
+		"expires_at": nil,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel cart"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Cart was changed by someone else"})
+		return
+	}
+
+	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.StockReservation{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reserved stock"})
+		return
+	}
+
+	cart.Status = "cancelled"
+	cart.ExpiresAt = nil
+	cart.Reservations = nil
+	if !recordAudit(c, tx, "cancel", "cart", cart.ID, before, cart) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart cancelled successfully"})
+}
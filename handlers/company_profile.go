@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"inventory_system/audit"
 	"inventory_system/database"
 	"inventory_system/models"
 
@@ -82,6 +83,8 @@ func CreateOrUpdateCompanyProfile(c *gin.Context) {
 	}
 
 	if result.Error == nil {
+		before := audit.Snapshot(existingProfile)
+
 		// Update existing profile
 		existingProfile.CompanyName = req.CompanyName
 		existingProfile.CompanyAddress = req.CompanyAddress
@@ -101,6 +104,10 @@ func CreateOrUpdateCompanyProfile(c *gin.Context) {
 			return
 		}
 
+		if !recordAudit(c, tx, audit.ActionUpdate, "company_profile", existingProfile.ID, before, existingProfile) {
+			return
+		}
+
 		tx.Commit()
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Company profile updated successfully",
@@ -129,6 +136,10 @@ func CreateOrUpdateCompanyProfile(c *gin.Context) {
 			return
 		}
 
+		if !recordAudit(c, tx, audit.ActionCreate, "company_profile", newProfile.ID, nil, newProfile) {
+			return
+		}
+
 		tx.Commit()
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Company profile created successfully",
@@ -156,12 +167,23 @@ func UpdateCompanyLogo(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(profile)
+
+	tx := database.DB.Begin()
+
 	profile.LogoBase64 = req.LogoBase64
-	if err := database.DB.Save(&profile).Error; err != nil {
+	if err := tx.Save(&profile).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update logo"})
 		return
 	}
 
+	if !recordAudit(c, tx, "update_logo", "company_profile", profile.ID, before, profile) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logo updated successfully",
 		"data":    profile,
@@ -183,11 +205,20 @@ func DeleteCompanyProfile(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Delete(&profile).Error; err != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Delete(&profile).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete company profile"})
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionDelete, "company_profile", profile.ID, profile, nil) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{"message": "Company profile deleted successfully"})
 }
 
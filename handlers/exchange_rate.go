@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// CreateExchangeRateRequest represents the request body for entering an exchange rate
+type CreateExchangeRateRequest struct {
+	Currency      string          `json:"currency" binding:"required"`
+	Rate          decimal.Decimal `json:"rate"`           // Base currency per unit of Currency
+	EffectiveDate string          `json:"effective_date"` // YYYY-MM-DD, defaults to today
+}
+
+// FXCurrencySummary totals the realised exchange gains and losses of one currency
+type FXCurrencySummary struct {
+	Currency   string          `json:"currency"`
+	Payments   int             `json:"payments"`
+	Amount     decimal.Decimal `json:"amount"`                  // Paid in the currency
+	AmountBase decimal.Decimal `json:"amount_base" cost:"true"` // Paid in the base currency at the payment rates
+	FXGainLoss decimal.Decimal `json:"fx_gain_loss" cost:"true"`
+}
+
+// FXPayment is a purchase payment settled at a different rate than its order
+type FXPayment struct {
+	PaymentID       uint            `json:"payment_id"`
+	PurchaseOrderID uint            `json:"purchase_order_id"`
+	PONumber        string          `json:"po_number"`
+	SupplierName    string          `json:"supplier_name"`
+	Currency        string          `json:"currency"`
+	Amount          decimal.Decimal `json:"amount"`
+	OrderRate       decimal.Decimal `json:"order_rate"`
+	PaymentRate     decimal.Decimal `json:"payment_rate"`
+	AmountBase      decimal.Decimal `json:"amount_base" cost:"true"`
+	FXGainLoss      decimal.Decimal `json:"fx_gain_loss" cost:"true"`
+	PaidAt          time.Time       `json:"paid_at"`
+}
+
+// baseCurrency returns the currency of the company profile, in which costs and reports are kept
+func baseCurrency() string {
+	return loadDocumentProfile().Currency
+}
+
+// normalizeCurrency validates a three-letter ISO 4217 currency code and returns it in upper case
+func normalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency code %q", code)
+	}
+	return code, nil
+}
+
+// lookupExchangeRate returns the latest rate of currency into base effective on or before the date
+func lookupExchangeRate(db *gorm.DB, currency, base string, on time.Time) (decimal.Decimal, error) {
+	if currency == base {
+		return decimal.NewFromInt(1), nil
+	}
+
+	var rate models.ExchangeRate
+	err := db.Where("currency = ? AND base_currency = ? AND effective_date <= ?", currency, base, on.Format("2006-01-02")).
+		Order("effective_date DESC").First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, fmt.Errorf("no %s to %s exchange rate on or before %s", currency, base, on.Format("2006-01-02"))
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return rate.Rate, nil
+}
+
+// toBase converts an amount at an exchange rate, rounded to the base currency
+func toBase(amount decimal.Decimal, rate decimal.Decimal) decimal.Decimal {
+	return money.Round(baseCurrency(), amount.Mul(rate))
+}
+
+// atOrderRate converts a purchase payment to the base currency at its order's exchange rate, for
+// payments that settle without an exchange difference
+func atOrderRate(payment *models.PurchasePayment, po *models.PurchaseOrder) {
+	payment.ExchangeRate = po.ExchangeRate
+	payment.AmountBase = toBase(payment.Amount, po.ExchangeRate)
+}
+
+// saveExchangeRate enters the rate of currency for a day, replacing any rate already entered for it.
+// On failure it rolls tx back, responds and returns false.
+func saveExchangeRate(c *gin.Context, tx *gorm.DB, currency, base string, day time.Time, value decimal.Decimal, source string) (*models.ExchangeRate, bool) {
+	var rate models.ExchangeRate
+	err := tx.Where("currency = ? AND base_currency = ? AND effective_date = ?", currency, base, day.Format("2006-01-02")).
+		First(&rate).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rate"})
+		return nil, false
+	}
+
+	action := audit.ActionUpdate
+	var before interface{}
+	if rate.ID == 0 {
+		action = audit.ActionCreate
+		rate = models.ExchangeRate{Currency: currency, BaseCurrency: base, EffectiveDate: day}
+	} else {
+		before = audit.Snapshot(rate)
+	}
+	rate.Rate = money.RoundRate(value)
+	rate.Source = source
+	rate.UserID = c.GetUint("user_id")
+
+	if err := tx.Omit("User").Save(&rate).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save exchange rate"})
+		return nil, false
+	}
+
+	if !recordAudit(c, tx, action, "exchange_rate", rate.ID, before, rate) {
+		return nil, false
+	}
+	return &rate, true
+}
+
+// GetExchangeRates lists the exchange rates into the base currency, newest first
+func GetExchangeRates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	base := baseCurrency()
+	query := database.DB.Model(&models.ExchangeRate{}).Where("base_currency = ?", base)
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", strings.ToUpper(currency))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var rates []models.ExchangeRate
+	if err := query.Preload("User").Order("effective_date DESC, currency ASC").
+		Offset((page - 1) * limit).Limit(limit).Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency":  base,
+		"exchange_rates": rates,
+		"total":          total,
+		"page":           page,
+		"limit":          limit,
+	})
+}
+
+// CreateExchangeRate enters the rate of a currency into the base currency for a day
+func CreateExchangeRate(c *gin.Context) {
+	var req CreateExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := normalizeCurrency(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Currency must be a three-letter ISO code"})
+		return
+	}
+	base := baseCurrency()
+	if currency == base {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is the base currency", base)})
+		return
+	}
+	if !money.RoundRate(req.Rate).IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate must be greater than 0"})
+		return
+	}
+
+	day := startOfToday()
+	if req.EffectiveDate != "" {
+		day, err = time.Parse("2006-01-02", req.EffectiveDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effective date format. Use YYYY-MM-DD"})
+			return
+		}
+	}
+
+	tx := database.DB.Begin()
+
+	rate, ok := saveExchangeRate(c, tx, currency, base, day, req.Rate, "manual")
+	if !ok {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate saved", "exchange_rate": rate})
+}
+
+// ImportExchangeRates enters exchange rates from a CSV or XLSX file with currency, rate and
+// effective_date (or date) columns. Nothing is saved unless every row is valid.
+func ImportExchangeRates(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is too large (max 10 MB)"})
+		return
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(header.Filename), "."))
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file format. Use .csv or .xlsx"})
+		return
+	}
+
+	records, err := readImportRecords(file, format, c.PostForm("sheet"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import file: " + err.Error()})
+		return
+	}
+	if len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: import file has no data rows"})
+		return
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "date" {
+			name = "effective_date"
+		}
+		if _, seen := columns[name]; !seen {
+			columns[name] = i
+		}
+	}
+	for _, field := range []string{"currency", "rate", "effective_date"} {
+		if _, ok := columns[field]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid import file: missing column %s", field)})
+			return
+		}
+	}
+	cell := func(record []string, field string) string {
+		if i := columns[field]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	type parsedRate struct {
+		currency string
+		day      time.Time
+		rate     decimal.Decimal
+	}
+	base := baseCurrency()
+	var rates []parsedRate
+	var rowErrors []ImportRowError
+	seen := map[string]int{}
+	for i, record := range records[1:] {
+		line := i + 2
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		currency, err := normalizeCurrency(cell(record, "currency"))
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Field: "currency", Message: "Currency must be a three-letter ISO code"})
+			continue
+		}
+		if currency == base {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Field: "currency", Message: fmt.Sprintf("%s is the base currency", base)})
+			continue
+		}
+		rate, err := decimal.NewFromString(strings.ReplaceAll(cell(record, "rate"), ",", ""))
+		if err != nil || !money.RoundRate(rate).IsPositive() {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Field: "rate", Message: "Rate must be a number greater than 0"})
+			continue
+		}
+		day, err := time.Parse("2006-01-02", cell(record, "effective_date"))
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Field: "effective_date", Message: "Invalid date format. Use YYYY-MM-DD"})
+			continue
+		}
+
+		key := currency + " " + day.Format("2006-01-02")
+		if first, ok := seen[key]; ok {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Message: fmt.Sprintf("Duplicate of row %d", first)})
+			continue
+		}
+		seen[key] = line
+		rates = append(rates, parsedRate{currency: currency, day: day, rate: rate})
+	}
+
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Import file has invalid rows", "errors": rowErrors})
+		return
+	}
+	if len(rates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: import file has no data rows"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	for _, parsed := range rates {
+		if _, ok := saveExchangeRate(c, tx, parsed.currency, base, parsed.day, parsed.rate, "import"); !ok {
+			return
+		}
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rates imported", "imported": len(rates), "base_currency": base})
+}
+
+// DeleteExchangeRate removes an exchange rate. Orders and payments keep the rate they were converted at.
+func DeleteExchangeRate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate ID"})
+		return
+	}
+
+	var rate models.ExchangeRate
+	if err := database.DB.First(&rate, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exchange rate not found"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Delete(&rate).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exchange rate"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionDelete, "exchange_rate", rate.ID, rate, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+}
+
+// GetPurchaseFXReport reports the exchange gains and losses realised on purchase payments over a
+// period (start_date, end_date), per currency and per payment
+func GetPurchaseFXReport(c *gin.Context) {
+	start, end, ok := scorecardPeriod(c)
+	if !ok {
+		return
+	}
+
+	var payments []models.PurchasePayment
+	if err := database.DB.Preload("PurchaseOrder.Supplier").
+		Where("fx_gain_loss <> 0 AND created_at >= ? AND created_at < ?", start, end).
+		Order("created_at ASC").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase payments"})
+		return
+	}
+
+	details := make([]FXPayment, 0, len(payments))
+	byCurrency := make(map[string]*FXCurrencySummary)
+	total := decimal.Zero
+	for _, payment := range payments {
+		po := payment.PurchaseOrder
+		details = append(details, FXPayment{
+			PaymentID:       payment.ID,
+			PurchaseOrderID: po.ID,
+			PONumber:        po.PONumber,
+			SupplierName:    po.Supplier.Name,
+			Currency:        po.Currency,
+			Amount:          payment.Amount,
+			OrderRate:       po.ExchangeRate,
+			PaymentRate:     payment.ExchangeRate,
+			AmountBase:      payment.AmountBase,
+			FXGainLoss:      payment.FXGainLoss,
+			PaidAt:          payment.CreatedAt,
+		})
+
+		summary, ok := byCurrency[po.Currency]
+		if !ok {
+			summary = &FXCurrencySummary{Currency: po.Currency}
+			byCurrency[po.Currency] = summary
+		}
+		summary.Payments++
+		summary.Amount = summary.Amount.Add(payment.Amount)
+		summary.AmountBase = summary.AmountBase.Add(payment.AmountBase)
+		summary.FXGainLoss = summary.FXGainLoss.Add(payment.FXGainLoss)
+		total = total.Add(payment.FXGainLoss)
+	}
+
+	currencies := make([]FXCurrencySummary, 0, len(byCurrency))
+	for _, summary := range byCurrency {
+		currencies = append(currencies, *summary)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Currency < currencies[j].Currency })
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency": baseCurrency(),
+		"start_date":    start.Format("2006-01-02"),
+		"end_date":      end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"fx_gain_loss":  total,
+		"by_currency":   currencies,
+		"payments":      details,
+	})
+}
@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"inventory_system/database"
+	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/money"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of records loaded from the database per batch
+const exportBatchSize = 500
+
+// exportWriter streams tabular export rows to the response
+type exportWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// csvExportWriter writes rows straight to the response, flushing each batch
+type csvExportWriter struct {
+	writer *csv.Writer
+	rows   int
+}
+
+func (w *csvExportWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case float64:
+			record[i] = fmt.Sprintf("%.2f", v)
+		case decimal.Decimal:
+			// At least two decimals, more only for unit prices that have them
+			record[i] = strings.TrimRight(v.StringFixed(money.UnitScale), "0")
+			if _, fraction, _ := strings.Cut(record[i], "."); len(fraction) < 2 {
+				record[i] = v.StringFixed(2)
+			}
+		case nil:
+			record[i] = ""
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%exportBatchSize == 0 {
+		w.writer.Flush()
+	}
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// xlsxExportWriter uses excelize's stream writer, which spills rows to a
+// temporary file instead of holding the whole sheet in memory
+type xlsxExportWriter struct {
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	out    http.ResponseWriter
+	row    int
+}
+
+func (w *xlsxExportWriter) WriteRow(values []interface{}) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	// Amounts go in as number cells
+	for i, value := range values {
+		if amount, ok := value.(decimal.Decimal); ok {
+			values[i] = amount.InexactFloat64()
+		}
+	}
+	return w.stream.SetRow(cell, values)
+}
+
+func (w *xlsxExportWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
+
+// exportColumn is a column of an export; cost columns are hidden from users without products.cost.view
+type exportColumn struct {
+	Header string
+	Cost   bool
+}
+
+// columns returns export columns with the given headers
+func columns(headers ...string) []exportColumn {
+	result := make([]exportColumn, len(headers))
+	for i, header := range headers {
+		result[i] = exportColumn{Header: header}
+	}
+	return result
+}
+
+// costColumns returns export columns with the given headers that reveal purchase costs
+func costColumns(headers ...string) []exportColumn {
+	result := columns(headers...)
+	for i := range result {
+		result[i].Cost = true
+	}
+	return result
+}
+
+// columnFilterWriter drops the columns at the given indexes from every row
+type columnFilterWriter struct {
+	exportWriter
+	drop map[int]bool
+}
+
+func (w *columnFilterWriter) WriteRow(values []interface{}) error {
+	kept := make([]interface{}, 0, len(values))
+	for i, value := range values {
+		if !w.drop[i] {
+			kept = append(kept, value)
+		}
+	}
+	return w.exportWriter.WriteRow(kept)
+}
+
+// newExportWriter sets the download headers and returns a writer for the requested format.
+// Cost columns are removed for users who may not see costs.
+func newExportWriter(c *gin.Context, format, name string, headers []exportColumn) (exportWriter, error) {
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	var writer exportWriter
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		writer = &csvExportWriter{writer: csv.NewWriter(c.Writer)}
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		file := excelize.NewFile()
+		stream, err := file.NewStreamWriter("Sheet1")
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		writer = &xlsxExportWriter{file: file, stream: stream, out: c.Writer}
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	if !middleware.HasPermission(c, permissions.ProductsCost) {
+		drop := make(map[int]bool)
+		for i, header := range headers {
+			if header.Cost {
+				drop[i] = true
+			}
+		}
+		if len(drop) > 0 {
+			writer = &columnFilterWriter{exportWriter: writer, drop: drop}
+		}
+	}
+
+	row := make([]interface{}, len(headers))
+	for i, header := range headers {
+		row[i] = header.Header
+	}
+	if err := writer.WriteRow(row); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// exportFormat reads and validates the format query parameter
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export format. Use csv or xlsx"})
+		return "", false
+	}
+	return format, true
+}
+
+// exportDateRange applies optional start_date/end_date filters on the given column
+func exportDateRange(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, bool) {
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format"})
+			return nil, false
+		}
+		query = query.Where(column+" >= ?", start)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format"})
+			return nil, false
+		}
+		query = query.Where(column+" < ?", end.Add(24*time.Hour))
+	}
+	return query, true
+}
+
+// finishExport closes the writer and records streaming errors; once the body has
+// started the status code can no longer change, so errors are attached to the context
+func finishExport(c *gin.Context, writer exportWriter, err error) {
+	if err != nil {
+		c.Error(err)
+	}
+	if closeErr := writer.Close(); closeErr != nil {
+		c.Error(closeErr)
+	}
+}
+
+// ExportProducts exports products with per-supplier stock and prices
+func ExportProducts(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&models.Product{}).Preload("Suppliers.Supplier")
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	headers := slices.Concat(
+		columns("SKU", "Name", "Category", "Location", "Product Active", "Total Stock", "Supplier"),
+		costColumns("Supplier Cost"),
+		columns("Supplier Price", "Supplier Stock", "Min Stock", "Supplier Active",
+			"Lead Time (days)", "Min Order Qty", "Order Multiple", "Preferred"),
+	)
+	writer, err := newExportWriter(c, format, "products", headers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	var products []models.Product
+	result := query.FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, product := range products {
+			base := []interface{}{
+				product.SKU, product.Name, product.Category, product.Location,
+				product.IsActive, product.GetTotalStock(),
+			}
+			if len(product.Suppliers) == 0 {
+				if err := writer.WriteRow(append(base, "", nil, nil, nil, nil, nil, nil, nil, nil, nil)); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, supplier := range product.Suppliers {
+				row := append(append([]interface{}{}, base...),
+					supplier.Supplier.Name, supplier.Cost, supplier.Price,
+					supplier.Stock, supplier.MinStock, supplier.IsActive,
+					supplier.LeadTimeDays, supplier.MinOrderQty, supplier.OrderMultiple, supplier.IsPreferred,
+				)
+				if err := writer.WriteRow(row); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	finishExport(c, writer, result.Error)
+}
+
+// stockMovementFilters applies the stock movement list filters shared by the list and export endpoints
+func stockMovementFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if movementType := c.Query("type"); movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if reference := c.Query("reference"); reference != "" {
+		query = query.Where("reference = ?", reference)
+	}
+	return exportDateRange(c, query, "created_at")
+}
+
+// ExportStockMovements exports stock movement history using the list filters
+func ExportStockMovements(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	query, ok := stockMovementFilters(c, database.DB.Model(&models.StockMovement{}).Preload("Product").Preload("User"))
+	if !ok {
+		return
+	}
+
+	headers := columns("Date", "SKU", "Product", "Type", "Quantity", "Reference", "User", "Notes")
+	writer, err := newExportWriter(c, format, "stock_movements", headers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	var movements []models.StockMovement
+	result := query.FindInBatches(&movements, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, movement := range movements {
+			row := []interface{}{
+				movement.CreatedAt.Format("2006-01-02 15:04:05"),
+				movement.Product.SKU, movement.Product.Name,
+				movement.Type, movement.Quantity, movement.Reference,
+				movement.User.Name, movement.Notes,
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	finishExport(c, writer, result.Error)
+}
+
+// ExportPurchaseOrders exports purchase orders one row per item, or their payments with view=payments
+func ExportPurchaseOrders(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	query, ok := exportDateRange(c, database.DB.Model(&models.PurchaseOrder{}), "order_date")
+	if !ok {
+		return
+	}
+	if supplierID := c.Query("supplier_id"); supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	if paymentStatus := c.Query("payment_status"); paymentStatus != "" {
+		query = query.Where("payment_status = ?", paymentStatus)
+	}
+
+	if c.DefaultQuery("view", "items") == "payments" {
+		exportPurchasePayments(c, format, query)
+		return
+	}
+
+	headers := slices.Concat(
+		columns("PO Number", "Order Date", "Supplier", "Payment Method", "Payment Status", "Currency"),
+		costColumns("PO Total", "Amount Paid", "Amount Due"),
+		columns("Exchange Rate"),
+		costColumns("PO Total (Base)"),
+		columns("Due Date", "SKU", "Product", "Quantity Ordered", "Quantity Received"),
+		costColumns("Unit Cost", "Line Total"),
+	)
+	writer, err := newExportWriter(c, format, "purchase_orders", headers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	var orders []models.PurchaseOrder
+	result := query.Preload("Supplier").Preload("Items.Product").
+		FindInBatches(&orders, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, po := range orders {
+				dueDate := ""
+				if po.DueDate != nil {
+					dueDate = po.DueDate.Format("2006-01-02")
+				}
+				base := []interface{}{
+					po.PONumber, po.OrderDate.Format("2006-01-02"), po.Supplier.Name,
+					po.PaymentMethod, po.PaymentStatus, po.Currency, po.Total, po.AmountPaid, po.AmountDue,
+					po.ExchangeRate, po.TotalBase, dueDate,
+				}
+				for _, item := range po.Items {
+					row := append(append([]interface{}{}, base...),
+						item.Product.SKU, item.Product.Name, item.QuantityOrdered,
+						item.QuantityReceived, item.UnitCost, item.Total,
+					)
+					if err := writer.WriteRow(row); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+
+	finishExport(c, writer, result.Error)
+}
+
+// exportPurchasePayments exports the payment history of the filtered purchase orders
+func exportPurchasePayments(c *gin.Context, format string, orders *gorm.DB) {
+	headers := slices.Concat(
+		columns("PO Number", "Supplier", "Payment Date", "Currency"),
+		costColumns("Amount"),
+		columns("Exchange Rate"),
+		costColumns("Amount (Base)", "FX Gain/Loss"),
+		columns("Payment Method", "Payment Type", "Recorded By", "Notes"),
+	)
+	writer, err := newExportWriter(c, format, "purchase_payments", headers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	var payments []models.PurchasePayment
+	result := database.DB.Model(&models.PurchasePayment{}).
+		Preload("PurchaseOrder.Supplier").Preload("User").
+		Where("purchase_order_id IN (?)", orders.Select("id")).
+		FindInBatches(&payments, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, payment := range payments {
+				row := []interface{}{
+					payment.PurchaseOrder.PONumber, payment.PurchaseOrder.Supplier.Name,
+					payment.CreatedAt.Format("2006-01-02 15:04:05"), payment.PurchaseOrder.Currency, payment.Amount,
+					payment.ExchangeRate, payment.AmountBase, payment.FXGainLoss, payment.PaymentMethod, payment.PaymentType, payment.User.Name, payment.Notes,
+				}
+				if err := writer.WriteRow(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	finishExport(c, writer, result.Error)
+}
+
+// ExportSuppliers exports the supplier directory
+func ExportSuppliers(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&models.Supplier{})
+	if c.DefaultQuery("active", "true") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	headers := columns("ID", "Name", "Contact Person", "Email", "Phone", "Address", "Website", "Currency", "Active", "Products")
+	writer, err := newExportWriter(c, format, "suppliers", headers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	var suppliers []models.Supplier
+	result := query.FindInBatches(&suppliers, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		// Active products of the whole batch in one query
+		ids := make([]uint, len(suppliers))
+		for i, supplier := range suppliers {
+			ids[i] = supplier.ID
+		}
+		var counts []struct {
+			SupplierID   uint
+			ProductCount int64
+		}
+		if err := database.DB.Model(&models.ProductSupplier{}).
+			Select("supplier_id, COUNT(*) AS product_count").
+			Where("supplier_id IN ? AND is_active = ?", ids, true).
+			Group("supplier_id").Scan(&counts).Error; err != nil {
+			return err
+		}
+		productCounts := make(map[uint]int64, len(counts))
+		for _, count := range counts {
+			productCounts[count.SupplierID] = count.ProductCount
+		}
+
+		for _, supplier := range suppliers {
+			row := []interface{}{
+				supplier.ID, supplier.Name, supplier.ContactPerson, supplier.Email,
+				supplier.Phone, supplier.Address, supplier.Website, supplier.Currency, supplier.IsActive, productCounts[supplier.ID],
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	finishExport(c, writer, result.Error)
+}
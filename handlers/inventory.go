@@ -5,10 +5,14 @@ import (
 	"strconv"
 	"strings"
 
+	"inventory_system/audit"
 	"inventory_system/database"
 	"inventory_system/models"
+	"inventory_system/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 // CreateProduct creates a new product
@@ -57,6 +61,10 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionCreate, "product", product.ID, nil, product) {
+		return
+	}
+
 	// Commit transaction
 	tx.Commit()
 
@@ -152,6 +160,8 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(product)
+
 	// Update fields
 	product.Name = request.Name
 	product.SKU = request.SKU
@@ -163,12 +173,20 @@ func UpdateProduct(c *gin.Context) {
 		product.IsActive = *request.IsActive
 	}
 
-	result = database.DB.Save(&product)
-	if result.Error != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Save(&product).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionUpdate, "product", product.ID, before, product) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, product)
 }
 
@@ -180,17 +198,26 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Delete(&models.Product{}, id)
-	if result.Error != nil {
+	var product models.Product
+	if err := database.DB.First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Delete(&product).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	if !recordAudit(c, tx, audit.ActionDelete, "product", product.ID, product, nil) {
 		return
 	}
 
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
 
@@ -254,6 +281,8 @@ func AdjustSupplierStock(c *gin.Context) {
 		newQuantity = request.Quantity
 	}
 
+	before := audit.Snapshot(productSupplier)
+
 	// Update supplier stock
 	productSupplier.Stock = newQuantity
 	if err := tx.Save(&productSupplier).Error; err != nil {
@@ -278,6 +307,10 @@ func AdjustSupplierStock(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, "adjust_stock", "product_supplier", productSupplier.ID, before, productSupplier) {
+		return
+	}
+
 	tx.Commit()
 
 	// Load with supplier info
@@ -292,16 +325,10 @@ func AdjustSupplierStock(c *gin.Context) {
 // GetStockMovements returns stock movement history
 func GetStockMovements(c *gin.Context) {
 	var movements []models.StockMovement
-	query := database.DB.Preload("Product").Preload("User")
-
-	// Filter by product if specified
-	if productID := c.Query("product_id"); productID != "" {
-		query = query.Where("product_id = ?", productID)
-	}
-
-	// Filter by type if specified
-	if movementType := c.Query("type"); movementType != "" {
-		query = query.Where("type = ?", movementType)
+	// Filter by product, type, user, reference and date range if specified
+	query, ok := stockMovementFilters(c, database.DB.Model(&models.StockMovement{}).Preload("Product").Preload("User"))
+	if !ok {
+		return
 	}
 
 	// Pagination
@@ -395,17 +422,24 @@ func AddProductSupplier(c *gin.Context) {
 	}
 
 	var request struct {
-		SupplierID uint    `json:"supplier_id" binding:"required"`
-		Cost       float64 `json:"cost" binding:"required"`
-		Price      float64 `json:"price" binding:"required"`
-		Stock      int     `json:"stock"`
-		MinStock   int     `json:"min_stock"`
+		SupplierID    uint             `json:"supplier_id" binding:"required"`
+		Cost          *decimal.Decimal `json:"cost"`
+		Price         *decimal.Decimal `json:"price"`
+		Stock         int              `json:"stock"`
+		MinStock      int              `json:"min_stock"`
+		LeadTimeDays  int              `json:"lead_time_days" binding:"min=0"`
+		MinOrderQty   int              `json:"min_order_qty" binding:"min=0"`
+		OrderMultiple int              `json:"order_multiple" binding:"min=0"`
+		IsPreferred   bool             `json:"is_preferred"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validSupplierPricing(c, request.Cost, request.Price) {
+		return
+	}
 
 	// Check if product exists
 	var product models.Product
@@ -431,19 +465,36 @@ func AddProductSupplier(c *gin.Context) {
 
 	// Create new product-supplier relationship
 	productSupplier := models.ProductSupplier{
-		ProductID:  uint(productID),
-		SupplierID: request.SupplierID,
-		Cost:       request.Cost,
-		Price:      request.Price,
-		Stock:      request.Stock,
-		MinStock:   request.MinStock,
+		ProductID:     uint(productID),
+		SupplierID:    request.SupplierID,
+		Cost:          money.RoundUnit(*request.Cost),
+		Price:         money.RoundUnit(*request.Price),
+		Stock:         request.Stock,
+		MinStock:      request.MinStock,
+		LeadTimeDays:  request.LeadTimeDays,
+		MinOrderQty:   request.MinOrderQty,
+		OrderMultiple: max(request.OrderMultiple, 1),
+		IsPreferred:   request.IsPreferred,
 	}
 
-	if err := database.DB.Create(&productSupplier).Error; err != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&productSupplier).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add supplier to product"})
 		return
 	}
 
+	if !clearPreferredSupplier(c, tx, &productSupplier) {
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "product_supplier", productSupplier.ID, nil, productSupplier) {
+		return
+	}
+
+	tx.Commit()
+
 	// Load the relationship with supplier info
 	database.DB.Preload("Supplier").First(&productSupplier, productSupplier.ID)
 
@@ -465,17 +516,24 @@ func UpdateProductSupplier(c *gin.Context) {
 	}
 
 	var request struct {
-		Cost     float64 `json:"cost" binding:"required"`
-		Price    float64 `json:"price" binding:"required"`
-		Stock    int     `json:"stock"`
-		MinStock int     `json:"min_stock"`
-		IsActive bool    `json:"is_active"`
+		Cost          *decimal.Decimal `json:"cost"`
+		Price         *decimal.Decimal `json:"price"`
+		Stock         int              `json:"stock"`
+		MinStock      int              `json:"min_stock"`
+		LeadTimeDays  int              `json:"lead_time_days" binding:"min=0"`
+		MinOrderQty   int              `json:"min_order_qty" binding:"min=0"`
+		OrderMultiple int              `json:"order_multiple" binding:"min=0"`
+		IsPreferred   bool             `json:"is_preferred"`
+		IsActive      bool             `json:"is_active"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validSupplierPricing(c, request.Cost, request.Price) {
+		return
+	}
 
 	var productSupplier models.ProductSupplier
 	if err := database.DB.Where("product_id = ? AND supplier_id = ?", productID, supplierID).First(&productSupplier).Error; err != nil {
@@ -483,24 +541,73 @@ func UpdateProductSupplier(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(productSupplier)
+
 	// Update fields
-	productSupplier.Cost = request.Cost
-	productSupplier.Price = request.Price
+	productSupplier.Cost = money.RoundUnit(*request.Cost)
+	productSupplier.Price = money.RoundUnit(*request.Price)
 	productSupplier.Stock = request.Stock
 	productSupplier.MinStock = request.MinStock
+	productSupplier.LeadTimeDays = request.LeadTimeDays
+	productSupplier.MinOrderQty = request.MinOrderQty
+	productSupplier.OrderMultiple = max(request.OrderMultiple, 1)
+	productSupplier.IsPreferred = request.IsPreferred
 	productSupplier.IsActive = request.IsActive
 
-	if err := database.DB.Save(&productSupplier).Error; err != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Save(&productSupplier).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product-supplier relationship"})
 		return
 	}
 
+	if !clearPreferredSupplier(c, tx, &productSupplier) {
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionUpdate, "product_supplier", productSupplier.ID, before, productSupplier) {
+		return
+	}
+
+	tx.Commit()
+
 	// Load with supplier info
 	database.DB.Preload("Supplier").First(&productSupplier, productSupplier.ID)
 
 	c.JSON(http.StatusOK, productSupplier)
 }
 
+// validSupplierPricing checks that a supplier's cost and price are given and not negative,
+// responding with 400 and returning false when they aren't
+func validSupplierPricing(c *gin.Context, cost, price *decimal.Decimal) bool {
+	if cost == nil || price == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cost and price are required"})
+		return false
+	}
+	if cost.IsNegative() || price.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cost and price can't be negative"})
+		return false
+	}
+	return true
+}
+
+// clearPreferredSupplier makes productSupplier the only preferred supplier of its product when it
+// is preferred. On failure it rolls tx back, responds and returns false.
+func clearPreferredSupplier(c *gin.Context, tx *gorm.DB, productSupplier *models.ProductSupplier) bool {
+	if !productSupplier.IsPreferred {
+		return true
+	}
+	if err := tx.Model(&models.ProductSupplier{}).
+		Where("product_id = ? AND id <> ? AND is_preferred = ?", productSupplier.ProductID, productSupplier.ID, true).
+		Update("is_preferred", false).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferred supplier"})
+		return false
+	}
+	return true
+}
+
 // RemoveProductSupplier removes a supplier from a product
 func RemoveProductSupplier(c *gin.Context) {
 	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -515,17 +622,26 @@ func RemoveProductSupplier(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Where("product_id = ? AND supplier_id = ?", productID, supplierID).Delete(&models.ProductSupplier{})
-	if result.Error != nil {
+	var productSupplier models.ProductSupplier
+	if err := database.DB.Where("product_id = ? AND supplier_id = ?", productID, supplierID).First(&productSupplier).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product-supplier relationship not found"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Delete(&productSupplier).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove supplier from product"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product-supplier relationship not found"})
+	if !recordAudit(c, tx, audit.ActionDelete, "product_supplier", productSupplier.ID, productSupplier, nil) {
 		return
 	}
 
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{"message": "Supplier removed from product successfully"})
 }
 
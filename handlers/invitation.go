@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/mailer"
+	"inventory_system/middleware"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultInvitationHours is how long an invitation stays valid unless the admin chooses otherwise
+const defaultInvitationHours = 72
+
+// CreateInvitationRequest represents the request body for inviting a user
+type CreateInvitationRequest struct {
+	Email          string `json:"email" binding:"required,email"`
+	Role           string `json:"role" binding:"required"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// AcceptInvitationRequest represents the request body for accepting an invitation
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"` // Checked against the password policy
+}
+
+// newSecretToken returns a random token and the SHA-256 hash that is stored in its place
+func newSecretToken() (string, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(random)
+	return token, hashSecretToken(token), nil
+}
+
+// hashSecretToken hashes a token for lookup; tokens are random so a plain hash is enough
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateInvitation issues an invitation for an email address and role (admin only)
+func CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if !middleware.CanGrantRole(c, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't assign a role with permissions you don't have"})
+		return
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultInvitationHours
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var existingUser models.User
+	if err := database.DB.Where("email = ?", email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+
+	token, tokenHash, err := newSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	now := time.Now()
+	invitation := models.Invitation{
+		Email:       email,
+		Role:        req.Role,
+		TokenHash:   tokenHash,
+		InvitedByID: userID.(uint),
+		ExpiresAt:   now.Add(time.Duration(req.ExpiresInHours) * time.Hour),
+	}
+
+	tx := database.DB.Begin()
+
+	// Only the newest invitation for an address is usable
+	if err := tx.Model(&models.Invitation{}).
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).
+		Update("revoked_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke previous invitations"})
+		return
+	}
+
+	if err := tx.Create(&invitation).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "invitation", invitation.ID, nil, invitation) {
+		return
+	}
+
+	tx.Commit()
+
+	// Email the invitation when a relay is configured; otherwise the admin shares the token
+	profile := loadDocumentProfile()
+	emailErr := mailer.Send(mailer.Message{
+		To:      []string{email},
+		Subject: fmt.Sprintf("You're invited to %s", profile.CompanyName),
+		Body: strings.Join([]string{
+			"Hello,",
+			"",
+			fmt.Sprintf("You have been invited to join %s as %s.", profile.CompanyName, req.Role),
+			"Use this invitation code to set your name and password:",
+			"",
+			token,
+			"",
+			fmt.Sprintf("The invitation expires on %s.", invitation.ExpiresAt.Format("02 Jan 2006 15:04 MST")),
+		}, "\n"),
+	})
+
+	response := gin.H{
+		"message":    "Invitation created successfully",
+		"invitation": invitation,
+		"token":      token, // Only returned once; the database keeps a hash
+		"email_sent": emailErr == nil,
+	}
+	if emailErr != nil {
+		response["email_error"] = emailErr.Error()
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetInvitations lists invitations, optionally filtered by status (admin only)
+func GetInvitations(c *gin.Context) {
+	query := database.DB.Model(&models.Invitation{}).Preload("InvitedBy")
+
+	now := time.Now()
+	switch c.Query("status") {
+	case "":
+	case "pending":
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case "accepted":
+		query = query.Where("accepted_at IS NOT NULL")
+	case "revoked":
+		query = query.Where("revoked_at IS NOT NULL")
+	case "expired":
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use pending, accepted, revoked or expired"})
+		return
+	}
+	if email := c.Query("email"); email != "" {
+		query = query.Where("email = ?", strings.ToLower(email))
+	}
+
+	// Pagination
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var invitations []models.Invitation
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invitations": invitations,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// RevokeInvitation cancels an invitation that has not been accepted yet (admin only)
+func RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	var invitation models.Invitation
+	if err := database.DB.First(&invitation, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if invitation.AcceptedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation has already been accepted"})
+		return
+	}
+	if invitation.RevokedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation already revoked", "invitation": invitation})
+		return
+	}
+
+	before := audit.Snapshot(invitation)
+
+	now := time.Now()
+	invitation.RevokedAt = &now
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&invitation).Update("revoked_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	if !recordAudit(c, tx, "revoke", "invitation", invitation.ID, before, invitation) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully", "invitation": invitation})
+}
+
+// AcceptInvitation creates the invited user's account from a valid invitation token
+func AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var invitation models.Invitation
+	if err := database.DB.Where("token_hash = ?", hashSecretToken(strings.TrimSpace(req.Token))).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation token"})
+		return
+	}
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation is no longer valid"})
+		return
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation has expired"})
+		return
+	}
+
+	if err := validatePassword(req.Password, invitation.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	var existingUser models.User
+	if err := tx.Where("email = ?", invitation.Email).First(&existingUser).Error; err == nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+
+	user := models.User{
+		Email:    invitation.Email,
+		Password: string(hashedPassword),
+		Name:     req.Name,
+		Role:     invitation.Role,
+		IsActive: true,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// The conditional update makes the token single-use even under concurrent requests
+	now := time.Now()
+	result := tx.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Updates(map[string]interface{}{"accepted_at": now, "accepted_user_id": user.ID})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation is no longer valid"})
+		return
+	}
+
+	// The new user isn't signed in yet, so the entry has no actor
+	if !recordAudit(c, tx, "accept_invitation", "user", user.ID, nil, user) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Account created successfully", "user": user})
+}
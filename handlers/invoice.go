@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"inventory_system/database"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+)
+
+// receiptWidth is the paper width of 80mm thermal receipt printers
+const receiptWidth = 80.0
+
+// loadSaleDocument loads a sale with everything needed to print it
+func loadSaleDocument(c *gin.Context) (*models.Sale, []models.SalePayment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sale ID"})
+		return nil, nil, false
+	}
+
+	var sale models.Sale
+	if err := database.DB.Preload("Items.Product").Preload("User").First(&sale, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		return nil, nil, false
+	}
+
+	var payments []models.SalePayment
+	database.DB.Where("sale_id = ?", sale.ID).Order("created_at ASC").Find(&payments)
+
+	return &sale, payments, true
+}
+
+// GetSaleInvoicePDF renders an A4 invoice for a sale
+func GetSaleInvoicePDF(c *gin.Context) {
+	sale, payments, ok := loadSaleDocument(c)
+	if !ok {
+		return
+	}
+
+	data, err := renderSaleInvoice(loadDocumentProfile(), sale, payments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoice"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("invoice_%s.pdf", sale.SaleNumber), data)
+}
+
+// GetSaleReceiptPDF renders an 80mm thermal receipt for a sale
+func GetSaleReceiptPDF(c *gin.Context) {
+	sale, payments, ok := loadSaleDocument(c)
+	if !ok {
+		return
+	}
+
+	data, err := renderSaleReceipt(loadDocumentProfile(), sale, payments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate receipt"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("receipt_%s.pdf", sale.SaleNumber), data)
+}
+
+// renderSaleInvoice builds the A4 invoice document
+func renderSaleInvoice(profile models.CompanyProfile, sale *models.Sale, payments []models.SalePayment) ([]byte, error) {
+	currency := profile.Currency
+	doc := newPDFDocument(profile, "INVOICE", sale.SaleNumber, sale.CreatedAt)
+
+	customer := sale.CustomerName
+	if customer == "" {
+		customer = "Walk-in"
+	}
+	dueDate := ""
+	if sale.PaymentMethod == "credit" && sale.DueDate != nil {
+		dueDate = sale.DueDate.Format("02 Jan 2006")
+	}
+	status := sale.PaymentStatus
+	if sale.Status == "cancelled" {
+		status = "CANCELLED"
+	}
+
+	doc.keyValues([][2]string{
+		{"Bill To:", customer},
+		{"Date:", sale.CreatedAt.Format("02 Jan 2006 15:04")},
+		{"Cashier:", sale.User.Name},
+		{"Payment:", sale.PaymentMethod},
+		{"Due Date:", dueDate},
+		{"Status:", status},
+	})
+
+	rows := make([][]string, 0, len(sale.Items))
+	for i, item := range sale.Items {
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			item.Product.SKU,
+			item.Product.Name,
+			strconv.Itoa(item.Quantity),
+			formatMoney(currency, item.Price),
+			formatMoney(currency, item.Total),
+		})
+	}
+	doc.table(
+		[]string{"#", "SKU", "Description", "Qty", "Unit Price", "Amount"},
+		[]float64{10, 28, 62, 15, 32, 33},
+		[]string{"C", "L", "L", "R", "R", "R"},
+		rows,
+	)
+
+	totals := [][2]string{{"Subtotal", formatMoney(currency, sale.Subtotal)}}
+	if !sale.Discount.IsZero() {
+		totals = append(totals, [2]string{"Discount", formatMoney(currency, sale.Discount.Neg())})
+	}
+	if !sale.Tax.IsZero() {
+		totals = append(totals, [2]string{"Tax", formatMoney(currency, sale.Tax)})
+	}
+	totals = append(totals,
+		[2]string{"Total", formatMoney(currency, sale.Total)},
+		[2]string{"Paid", formatMoney(currency, sale.AmountPaid)},
+		[2]string{"Amount Due", formatMoney(currency, sale.AmountDue)},
+	)
+	doc.totals(totals)
+
+	if len(payments) > 0 {
+		paymentRows := make([][]string, 0, len(payments))
+		for _, payment := range payments {
+			paymentRows = append(paymentRows, []string{
+				payment.CreatedAt.Format("02 Jan 2006"),
+				payment.PaymentType,
+				payment.PaymentMethod,
+				formatMoney(currency, payment.Amount),
+				payment.Notes,
+			})
+		}
+		doc.heading("Payment History")
+		doc.table(
+			[]string{"Date", "Type", "Method", "Amount", "Notes"},
+			[]float64{28, 28, 26, 35, 63},
+			[]string{"L", "L", "L", "R", "L"},
+			paymentRows,
+		)
+	}
+
+	doc.section("Payment Details", profile.BankAccount)
+
+	return doc.bytes()
+}
+
+// cancelledBanner is the line printed across a cancelled sale's receipt, empty otherwise
+func cancelledBanner(sale *models.Sale) string {
+	if sale.Status == "cancelled" {
+		return "*** CANCELLED ***"
+	}
+	return ""
+}
+
+// singleByteRunes turns text translated to a single-byte code page into one rune per byte, the
+// form SplitText measures with the core fonts' width tables
+func singleByteRunes(text string) string {
+	runes := make([]rune, len(text))
+	for i := 0; i < len(text); i++ {
+		runes[i] = rune(text[i])
+	}
+	return string(runes)
+}
+
+// renderSaleReceipt builds a narrow single-page receipt sized to its content
+func renderSaleReceipt(profile models.CompanyProfile, sale *models.Sale, payments []models.SalePayment) ([]byte, error) {
+	currency := profile.Currency
+	const margin = 4.0
+	const line = 4.0
+	width := receiptWidth - 2*margin
+
+	// Lay the receipt out first so it prints as one continuous strip: free text such as the address,
+	// the customer name and the footer wraps onto as many lines as it needs
+	measure := fpdf.New("P", "mm", "A4", "")
+	measureTr := measure.UnicodeTranslatorFromDescriptor("")
+	wrapped := func(style string, size float64, text string) float64 {
+		if text == "" {
+			return 0
+		}
+		measure.SetFont("Courier", style, size)
+		return float64(len(measure.SplitText(singleByteRunes(measureTr(text)), width))) * line
+	}
+
+	height := 2 * margin
+	if profile.LogoBase64 != "" {
+		height += 22
+	}
+	height += wrapped("B", 10, profile.CompanyName) + wrapped("", 8, profile.CompanyAddress) +
+		wrapped("", 8, profile.CompanyPhone) + wrapped("", 8, prefixIfSet("Tax No: ", profile.TaxNumber))
+	height += wrapped("", 8, prefixIfSet("Customer: ", sale.CustomerName))
+	height += wrapped("B", 10, cancelledBanner(sale)) + wrapped("", 8, profile.InvoiceFooter)
+	lines := 4 + 2 + 2*len(sale.Items) + 3 + len(payments) // Rules, number and cashier, items, subtotal/total/payment
+	for _, shown := range []bool{!sale.Discount.IsZero(), !sale.Tax.IsZero(), sale.AmountDue.IsPositive(), sale.ChangeGiven.IsPositive()} {
+		if shown {
+			lines++
+		}
+	}
+	height += float64(lines) * line
+
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           fpdf.SizeType{Wd: receiptWidth, Ht: height},
+	})
+	pdf.SetCreationDate(sale.CreatedAt)
+	pdf.SetModificationDate(sale.CreatedAt)
+	pdf.SetTitle("Receipt "+sale.SaleNumber, true)
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	if data, imageType, ok := decodeLogo(profile.LogoBase64); ok {
+		options := fpdf.ImageOptions{ImageType: imageType}
+		pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(data))
+		if pdf.Ok() {
+			pdf.ImageOptions("logo", (receiptWidth-18)/2, margin, 0, 18, true, options, 0, "")
+			pdf.Ln(2)
+		} else {
+			pdf.ClearError()
+		}
+	}
+
+	center := func(style string, size float64, text string) {
+		if text == "" {
+			return
+		}
+		pdf.SetFont("Courier", style, size)
+		pdf.MultiCell(width, line, tr(text), "", "C", false)
+	}
+	pair := func(left, right string) {
+		pdf.CellFormat(width/2, line, tr(left), "", 0, "L", false, 0, "")
+		pdf.CellFormat(width/2, line, tr(right), "", 1, "R", false, 0, "")
+	}
+	rule := func() {
+		pdf.SetFont("Courier", "", 8)
+		pdf.CellFormat(width, line, "----------------------------------------", "", 1, "C", false, 0, "")
+	}
+
+	center("B", 10, profile.CompanyName)
+	center("", 8, profile.CompanyAddress)
+	center("", 8, profile.CompanyPhone)
+	center("", 8, prefixIfSet("Tax No: ", profile.TaxNumber))
+	rule()
+
+	pdf.SetFont("Courier", "", 8)
+	pair("No: "+sale.SaleNumber, sale.CreatedAt.Format("02/01/06 15:04"))
+	pair("Cashier: "+sale.User.Name, "")
+	if sale.CustomerName != "" {
+		pdf.MultiCell(width, line, tr("Customer: "+sale.CustomerName), "", "L", false)
+	}
+	rule()
+
+	pdf.SetFont("Courier", "", 8)
+	for _, item := range sale.Items {
+		pdf.CellFormat(width, line, tr(truncateToWidth(pdf, item.Product.Name, width)), "", 1, "L", false, 0, "")
+		pair(fmt.Sprintf("  %d x %s", item.Quantity, formatMoney(currency, item.Price)), formatMoney(currency, item.Total))
+	}
+	rule()
+
+	pdf.SetFont("Courier", "", 8)
+	pair("Subtotal", formatMoney(currency, sale.Subtotal))
+	if !sale.Discount.IsZero() {
+		pair("Discount", formatMoney(currency, sale.Discount.Neg()))
+	}
+	if !sale.Tax.IsZero() {
+		pair("Tax", formatMoney(currency, sale.Tax))
+	}
+	pdf.SetFont("Courier", "B", 9)
+	pair("TOTAL", formatMoney(currency, sale.Total))
+	pdf.SetFont("Courier", "", 8)
+	pair("Payment: "+sale.PaymentMethod, formatMoney(currency, sale.AmountPaid))
+	if sale.AmountDue.IsPositive() {
+		pair("Amount Due", formatMoney(currency, sale.AmountDue))
+	}
+	for _, payment := range payments {
+		if payment.PaymentType == "tender" {
+			// Tenders are shown as handed over; change is listed separately
+			pair("  "+payment.PaymentMethod, formatMoney(currency, decimal.Max(payment.Tendered, payment.Amount)))
+			continue
+		}
+		pair(fmt.Sprintf("  %s %s", payment.CreatedAt.Format("02/01"), payment.PaymentMethod), formatMoney(currency, payment.Amount))
+	}
+	if sale.ChangeGiven.IsPositive() {
+		pair("Change", formatMoney(currency, sale.ChangeGiven))
+	}
+	center("B", 10, cancelledBanner(sale))
+	rule()
+
+	center("", 8, profile.InvoiceFooter)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
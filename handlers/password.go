@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"inventory_system/database"
+	"inventory_system/mailer"
+	"inventory_system/middleware"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultPasswordMinLength is the minimum password length unless PASSWORD_MIN_LENGTH is set
+const defaultPasswordMinLength = 8
+
+// defaultPasswordResetMinutes is the reset token lifetime unless PASSWORD_RESET_TTL_MINUTES is set
+const defaultPasswordResetMinutes = 60
+
+// passwordResetCooldown limits how often reset emails are sent to one account
+const passwordResetCooldown = time.Minute
+
+// commonPasswords are rejected outright even when they satisfy the other rules
+var commonPasswords = map[string]bool{
+	"password1": true, "password123": true, "passw0rd": true, "qwerty123": true,
+	"abc12345": true, "abcd1234": true, "12345678a": true, "iloveyou1": true,
+	"welcome1": true, "welcome123": true, "admin123": true, "admin1234": true,
+	"letmein1": true, "changeme1": true, "inventory1": true, "1q2w3e4r": true,
+}
+
+// ChangePasswordRequest represents the request body for changing one's own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ForgotPasswordRequest represents the request body for requesting a reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the request body for setting a password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// passwordMinLength returns the configured minimum password length
+func passwordMinLength() int {
+	if value, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && value >= 6 {
+		return value
+	}
+	return defaultPasswordMinLength
+}
+
+// validatePassword checks a new password against the password policy
+func validatePassword(password, email string) error {
+	minLength := passwordMinLength()
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("password must be at least %d characters", minLength)
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return fmt.Errorf("password must be at most 72 bytes")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("password must contain both letters and digits")
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("password is too common")
+	}
+	if local, _, found := strings.Cut(strings.ToLower(email), "@"); found && len(local) >= 3 && strings.Contains(lower, local) {
+		return fmt.Errorf("password must not contain your email address")
+	}
+	return nil
+}
+
+// passwordResetTTL returns how long a reset token stays valid
+func passwordResetTTL() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES")); err == nil && value > 0 {
+		return time.Duration(value) * time.Minute
+	}
+	return defaultPasswordResetMinutes * time.Minute
+}
+
+// ChangePassword changes the current user's password and signs out their other sessions
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUserWithPassword(c, req.CurrentPassword)
+	if !ok {
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current password"})
+		return
+	}
+	if err := validatePassword(req.NewPassword, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	sessionID := c.GetUint("session_id")
+
+	tx := database.DB.Begin()
+
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password":            string(hashedPassword),
+		"password_changed_at": time.Now(),
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// Every other session is signed out; the one making the change stays signed in
+	if err := tx.Model(&models.UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", user.ID, sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if !recordAudit(c, tx, "change_password", "user", user.ID, nil, nil) {
+		return
+	}
+
+	tx.Commit()
+	middleware.LogActivity(c, user.ID, "password_changed", "auth", user.ID, "Changed password")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully. Other sessions have been signed out"})
+}
+
+// ForgotPassword emails a password reset token. The response is the same whether or not the
+// address belongs to an account, so it can't be used to discover users.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for that email, a password reset link has been sent"}
+
+	var user models.User
+	if err := database.DB.Where("email = ? AND is_active = ? AND is_service_account = ?", strings.TrimSpace(req.Email), true, false).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var recent int64
+	database.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetCooldown)).
+		Count(&recent)
+	if recent > 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, tokenHash, err := newSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+
+	now := time.Now()
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(passwordResetTTL()),
+		IPAddress: c.ClientIP(),
+	}
+
+	tx := database.DB.Begin()
+
+	// Only the newest reset token works
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+	if err := tx.Create(&resetToken).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+
+	tx.Commit()
+
+	lines := []string{
+		fmt.Sprintf("Hello %s,", user.Name),
+		"",
+		"We received a request to reset your password.",
+	}
+	if baseURL := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/"); baseURL != "" {
+		lines = append(lines, "Open this link to choose a new password:", "", baseURL+"/reset-password.html?token="+token)
+	} else {
+		lines = append(lines, "Use this reset code to choose a new password:", "", token)
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("The link expires on %s and can be used once.", resetToken.ExpiresAt.Format("02 Jan 2006 15:04 MST")),
+		"If you didn't ask for this, you can ignore this email.",
+	)
+
+	profile := loadDocumentProfile()
+	if err := mailer.Send(mailer.Message{
+		To:      []string{user.Email},
+		Subject: fmt.Sprintf("Reset your %s password", profile.CompanyName),
+		Body:    strings.Join(lines, "\n"),
+	}); err != nil {
+		middleware.LogActivity(c, user.ID, "password_reset_email_failed", "auth", user.ID, err.Error())
+	} else {
+		middleware.LogActivity(c, user.ID, "password_reset_requested", "auth", user.ID, "Password reset requested from "+c.ClientIP())
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword sets a new password using a reset token and signs out every session
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var resetToken models.PasswordResetToken
+	if err := database.DB.Where("token_hash = ?", hashSecretToken(strings.TrimSpace(req.Token))).First(&resetToken).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND is_active = ?", resetToken.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err := validatePassword(req.NewPassword, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	tx := database.DB.Begin()
+
+	// The conditional update makes the token single-use even under concurrent requests
+	result := tx.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", resetToken.ID).
+		Update("used_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	// Proving control of the mailbox also clears any lockout
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"password":              string(hashedPassword),
+		"password_changed_at":   now,
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := revokeUserSessions(tx, user.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	// Nobody is signed in for a reset, so the entry has no actor
+	if !recordAudit(c, tx, "reset_password", "user", user.ID, nil, nil) {
+		return
+	}
+
+	tx.Commit()
+	middleware.LogActivity(c, user.ID, "password_reset", "auth", user.ID, "Reset password with an emailed token")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully. Please sign in with your new password"})
+}
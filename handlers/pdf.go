@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+)
+
+// loadDocumentProfile returns the active company profile, or defaults when none is configured
+func loadDocumentProfile() models.CompanyProfile {
+	var profile models.CompanyProfile
+	if err := database.DB.Where("is_active = ?", true).First(&profile).Error; err != nil {
+		return models.CompanyProfile{
+			CompanyName:   "INVENTORY SYSTEM",
+			InvoiceFooter: "Thank you for your business!",
+			Currency:      "IDR",
+		}
+	}
+	if profile.Currency == "" {
+		profile.Currency = "IDR"
+	}
+	return profile
+}
+
+// formatMoney formats an amount with thousands separators in the minor unit of its currency
+func formatMoney(currency string, amount decimal.Decimal) string {
+	scale := money.Scale(currency)
+	digits := money.Round(currency, amount).Abs().StringFixed(scale)
+	whole, fraction, _ := strings.Cut(digits, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	if fraction != "" {
+		grouped.WriteString("." + fraction)
+	}
+
+	symbol := currency
+	if currency == "" || currency == "IDR" {
+		symbol = "Rp"
+	}
+
+	result := fmt.Sprintf("%s %s", symbol, grouped.String())
+	if amount.Round(scale).IsNegative() {
+		result = "-" + result
+	}
+	return result
+}
+
+// decodeLogo turns the stored base64 logo (optionally a data URL) into image bytes and type
+func decodeLogo(logo string) ([]byte, string, bool) {
+	if logo == "" {
+		return nil, "", false
+	}
+
+	imageType := ""
+	if strings.HasPrefix(logo, "data:") {
+		comma := strings.Index(logo, ",")
+		if comma < 0 {
+			return nil, "", false
+		}
+		header := logo[:comma]
+		logo = logo[comma+1:]
+		switch {
+		case strings.Contains(header, "image/png"):
+			imageType = "PNG"
+		case strings.Contains(header, "image/jpeg"), strings.Contains(header, "image/jpg"):
+			imageType = "JPG"
+		case strings.Contains(header, "image/gif"):
+			imageType = "GIF"
+		default:
+			return nil, "", false
+		}
+	}
+
+	data, err := base64.StdEncoding.DecodeString(logo)
+	if err != nil {
+		return nil, "", false
+	}
+
+	if imageType == "" {
+		switch {
+		case bytes.HasPrefix(data, []byte("\x89PNG")):
+			imageType = "PNG"
+		case bytes.HasPrefix(data, []byte("\xff\xd8")):
+			imageType = "JPG"
+		case bytes.HasPrefix(data, []byte("GIF8")):
+			imageType = "GIF"
+		default:
+			return nil, "", false
+		}
+	}
+
+	return data, imageType, true
+}
+
+// pdfDocument is an A4 business document with company branding in the header and footer
+type pdfDocument struct {
+	pdf     *fpdf.Fpdf
+	tr      func(string) string
+	profile models.CompanyProfile
+}
+
+// newPDFDocument starts an A4 document titled e.g. "INVOICE" with its number and date
+func newPDFDocument(profile models.CompanyProfile, title, number string, date time.Time) *pdfDocument {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// Fixed dates keep regenerated documents byte-identical for archiving
+	pdf.SetCreationDate(date)
+	pdf.SetModificationDate(date)
+	pdf.SetTitle(fmt.Sprintf("%s %s", title, number), true)
+	pdf.SetAuthor(profile.CompanyName, true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 25)
+	pdf.AliasNbPages("")
+
+	doc := &pdfDocument{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor(""), profile: profile}
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-20)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(100, 100, 100)
+		if profile.InvoiceFooter != "" {
+			pdf.CellFormat(0, 4, doc.tr(profile.InvoiceFooter), "", 1, "C", false, 0, "")
+		}
+		pdf.CellFormat(0, 4, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	pdf.AddPage()
+	doc.header(title, number, date)
+	return doc
+}
+
+// header draws the logo, company details and document title block
+func (d *pdfDocument) header(title, number string, date time.Time) {
+	pdf := d.pdf
+	left, top, _, _ := pdf.GetMargins()
+	textX := left
+
+	if data, imageType, ok := decodeLogo(d.profile.LogoBase64); ok {
+		options := fpdf.ImageOptions{ImageType: imageType}
+		pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(data))
+		if pdf.Ok() {
+			pdf.ImageOptions("logo", left, top, 0, 20, false, options, 0, "")
+			textX = left + 35
+		} else {
+			// A broken logo should not prevent the document from rendering
+			pdf.ClearError()
+		}
+	}
+
+	pdf.SetXY(textX, top)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(100, 7, d.tr(d.profile.CompanyName), "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range []string{
+		d.profile.CompanyAddress,
+		joinNonEmpty(" | ", d.profile.CompanyPhone, d.profile.CompanyEmail),
+		d.profile.CompanyWebsite,
+		prefixIfSet("Tax No: ", d.profile.TaxNumber),
+		prefixIfSet("License: ", d.profile.BusinessLicense),
+	} {
+		if line != "" {
+			pdf.MultiCell(100, 4.5, d.tr(line), "", "L", false)
+			pdf.SetX(textX)
+		}
+	}
+	bottom := pdf.GetY()
+
+	pageWidth, _ := pdf.GetPageSize()
+	pdf.SetXY(pageWidth-75, top)
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(60, 9, d.tr(title), "", 2, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(60, 5, d.tr(number), "", 2, "R", false, 0, "")
+	pdf.CellFormat(60, 5, date.Format("02 Jan 2006"), "", 2, "R", false, 0, "")
+
+	if pdf.GetY() > bottom {
+		bottom = pdf.GetY()
+	}
+	if bottom < top+22 {
+		bottom = top + 22
+	}
+	pdf.SetY(bottom + 4)
+	pdf.Line(left, pdf.GetY(), pageWidth-left, pdf.GetY())
+	pdf.Ln(5)
+}
+
+// keyValues prints label/value pairs in two columns, e.g. customer and due date
+func (d *pdfDocument) keyValues(pairs [][2]string) {
+	pdf := d.pdf
+	for _, pair := range pairs {
+		if pair[1] == "" {
+			continue
+		}
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(35, 5, d.tr(pair[0]), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(0, 5, d.tr(pair[1]), "", "L", false)
+	}
+	pdf.Ln(3)
+}
+
+// table prints a bordered table; aligns uses fpdf alignment letters per column
+func (d *pdfDocument) table(headers []string, widths []float64, aligns []string, rows [][]string) {
+	pdf := d.pdf
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(54, 96, 146)
+	pdf.SetTextColor(255, 255, 255)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 7, d.tr(header), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(0, 0, 0)
+	for _, row := range rows {
+		for i, value := range row {
+			pdf.CellFormat(widths[i], 6, d.tr(truncateToWidth(pdf, value, widths[i]-2)), "1", 0, aligns[i], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(3)
+}
+
+// lineItems prints the numbered item table of a purchase document: #, SKU, description, quantity,
+// unit cost and amount. Without showCosts the unit cost and amount columns are left out.
+func (d *pdfDocument) lineItems(rows [][]string, showCosts bool) {
+	if showCosts {
+		d.table(
+			[]string{"#", "SKU", "Description", "Qty", "Unit Cost", "Amount"},
+			[]float64{10, 28, 62, 15, 32, 33},
+			[]string{"C", "L", "L", "R", "R", "R"},
+			rows,
+		)
+		return
+	}
+	trimmed := make([][]string, len(rows))
+	for i, row := range rows {
+		trimmed[i] = row[:4]
+	}
+	d.table(
+		[]string{"#", "SKU", "Description", "Qty"},
+		[]float64{10, 28, 127, 15},
+		[]string{"C", "L", "L", "R"},
+		trimmed,
+	)
+}
+
+// totals prints right-aligned label/amount rows; the last row is emphasised
+func (d *pdfDocument) totals(rows [][2]string) {
+	pdf := d.pdf
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	for i, row := range rows {
+		style := ""
+		if i == len(rows)-1 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.SetX(pageWidth - right - 90)
+		pdf.CellFormat(50, 6, d.tr(row[0]), "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 6, d.tr(row[1]), "", 1, "R", false, 0, "")
+	}
+	pdf.SetX(left)
+	pdf.Ln(3)
+}
+
+// heading prints a section title
+func (d *pdfDocument) heading(title string) {
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.CellFormat(0, 6, d.tr(title), "", 1, "L", false, 0, "")
+}
+
+// section prints a heading followed by free text, skipping empty sections
+func (d *pdfDocument) section(title, text string) {
+	if text == "" {
+		return
+	}
+	pdf := d.pdf
+	d.heading(title)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.MultiCell(0, 5, d.tr(text), "", "L", false)
+	pdf.Ln(3)
+}
+
+// bytes renders the document
+func (d *pdfDocument) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writePDF sends a rendered document, inline by default or as an attachment with download=true
+func writePDF(c *gin.Context, filename string, data []byte) {
+	disposition := "inline"
+	if c.Query("download") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%s", disposition, filename))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// truncateToWidth shortens text with an ellipsis so it fits a table cell
+func truncateToWidth(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// joinNonEmpty joins the non-empty values with sep
+func joinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, value := range values {
+		if value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// prefixIfSet returns prefix+value, or "" when value is empty
+func prefixIfSet(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
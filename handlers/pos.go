@@ -8,31 +8,38 @@ import (
 	"strings"
 	"time"
 
+	"inventory_system/audit"
 	"inventory_system/database"
 	"inventory_system/models"
+	"inventory_system/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
 )
 
 // SaleRequest represents a POS sale request
 type SaleRequest struct {
 	CustomerName  string            `json:"customer_name"`
-	PaymentMethod string            `json:"payment_method" binding:"required"`
-	PaymentDays   int               `json:"payment_days"`  // Number of days for payment due
-	DownPayment   float64           `json:"down_payment"`
+	PaymentMethod string            `json:"payment_method"` // cash, card, transfer or credit; optional when tenders are given
+	PaymentDays   int               `json:"payment_days"`   // Number of days for payment due
+	DownPayment   decimal.Decimal   `json:"down_payment"`
+	Tenders       []TenderRequest   `json:"tenders" binding:"omitempty,dive"` // Split payment; the down payment for credit sales
 	Items         []SaleItemRequest `json:"items" binding:"required,min=1"`
-	Discount      float64           `json:"discount"`
-	Tax           float64           `json:"tax"`
+	Discount      decimal.Decimal   `json:"discount"`
+	Tax           decimal.Decimal   `json:"tax"`
+	// Manager credentials for price/cost overrides beyond what the cashier may do alone
+	OverrideApproval *PriceOverrideApproval `json:"override_approval"`
 }
 
 // SaleItemRequest represents an item in a sale
 type SaleItemRequest struct {
-	ProductID  uint    `json:"product_id" binding:"required"`
-	SupplierID *uint   `json:"supplier_id"` // Optional supplier selection
-	Quantity   int     `json:"quantity" binding:"required,min=1"`
-	Price      *float64 `json:"price"`      // Optional price override
-	Cost       *float64 `json:"cost"`       // Optional cost override
+	ProductID  uint             `json:"product_id" binding:"required"`
+	SupplierID *uint            `json:"supplier_id"` // Optional supplier selection
+	Quantity   int              `json:"quantity" binding:"required,min=1"`
+	Price      *decimal.Decimal `json:"price"` // Optional price override
+	Cost       *decimal.Decimal `json:"cost"`  // Optional cost override
 }
 
 // CreateSale processes a new sale transaction
@@ -42,14 +49,58 @@ func CreateSale(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validateSaleRequest(c, &request) {
+		return
+	}
 
-	// Validate payment method
-	validPaymentMethods := []string{"cash", "card", "transfer", "credit"}
-	if !slices.Contains(validPaymentMethods, request.PaymentMethod) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method"})
+	// Start transaction
+	tx := database.DB.Begin()
+
+	sale, ok := createSaleTx(c, tx, request, 0)
+	if !ok {
 		return
 	}
 
+	tx.Commit()
+
+	// Load complete sale data with items and products
+	var completeSale models.Sale
+	database.DB.Preload("Items.Product").Preload("Items.OverrideApprovedBy").Preload("User").Preload("Payments").First(&completeSale, sale.ID)
+
+	c.JSON(http.StatusCreated, completeSale)
+}
+
+// validateSaleRequest checks payment details and fills in defaults, responding with 400 when the request is invalid
+func validateSaleRequest(c *gin.Context, request *SaleRequest) bool {
+	// Validate payment method; with tenders the method follows from them unless the sale is on credit
+	if request.PaymentMethod == "" || request.PaymentMethod == "split" {
+		if len(request.Tenders) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payment_method or tenders is required"})
+			return false
+		}
+		request.PaymentMethod = ""
+	} else {
+		validPaymentMethods := []string{"cash", "card", "transfer", "credit"}
+		if !slices.Contains(validPaymentMethods, request.PaymentMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method"})
+			return false
+		}
+	}
+	if err := validateTenders(request.Tenders); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if request.Discount.IsNegative() || request.Tax.IsNegative() || request.DownPayment.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Discount, tax and down payment can't be negative"})
+		return false
+	}
+	for _, item := range request.Items {
+		if (item.Price != nil && item.Price.IsNegative()) || (item.Cost != nil && item.Cost.IsNegative()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Price and cost of product %d can't be negative", item.ProductID)})
+			return false
+		}
+	}
+
 	// Set default payment days if not provided
 	if request.PaymentDays == 0 {
 		if request.PaymentMethod == "credit" {
@@ -61,18 +112,36 @@ func CreateSale(c *gin.Context) {
 	// Validate payment days
 	if request.PaymentDays < 0 || request.PaymentDays > 365 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment days must be between 0 and 365"})
-		return
+		return false
 	}
+	return true
+}
 
+// createSaleTx rings up a validated sale inside tx: it deducts stock, records the tenders and writes
+// the audit entry. Stock reserved by parked carts is not available, except for the cart's own
+// reservation when cartID is set. On failure it rolls tx back, responds and returns false.
+func createSaleTx(c *gin.Context, tx *gorm.DB, request SaleRequest, cartID uint) (*models.Sale, bool) {
 	// Get user ID from context
 	userID, _ := c.Get("user_id")
 
-	// Start transaction
-	tx := database.DB.Begin()
+	// Link the sale to the cashier's open shift
+	shift := openShiftFor(tx, userID.(uint))
+	if shift == nil && shiftRequired() {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Open a shift before ringing up sales"})
+		return nil, false
+	}
+	var shiftID *uint
+	if shift != nil {
+		shiftID = &shift.ID
+	}
 
 	// Generate sale number
 	saleNumber := generateSaleNumber()
 
+	// Approver for price overrides, resolved on the first line that needs one
+	var overrideApprover *models.User
+
 	// Calculate due date based on payment days
 	var dueDate *time.Time
 	var paymentStatus string
@@ -88,6 +157,9 @@ func CreateSale(c *gin.Context) {
 		dueDate = &calculatedDueDate
 	}
 
+	// Amounts are exact and rounded to the base currency
+	currency := baseCurrency()
+
 	// Create sale record
 	sale := models.Sale{
 		SaleNumber:    saleNumber,
@@ -96,14 +168,14 @@ func CreateSale(c *gin.Context) {
 		PaymentMethod: request.PaymentMethod,
 		PaymentDays:   request.PaymentDays,
 		PaymentStatus: paymentStatus,
-		DownPayment:   request.DownPayment,
+		DownPayment:   money.Round(currency, request.DownPayment),
 		DueDate:       dueDate,
 		Status:        "completed",
-		Discount:      request.Discount,
-		Tax:           request.Tax,
+		Discount:      money.Round(currency, request.Discount),
+		Tax:           money.Round(currency, request.Tax),
+		ShiftID:       shiftID,
 	}
 
-	var subtotal float64
 	var saleItems []models.SaleItem
 
 	// Process each item
@@ -112,10 +184,11 @@ func CreateSale(c *gin.Context) {
 		if err := tx.Preload("Suppliers.Supplier").First(&product, itemReq.ProductID).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product with ID %d not found", itemReq.ProductID)})
-			return
+			return nil, false
 		}
 
-		var usePrice, useCost float64
+		var usePrice, useCost decimal.Decimal
+		var listPrice, listCost decimal.Decimal
 		var supplierName string
 
 		if itemReq.SupplierID != nil {
@@ -134,28 +207,32 @@ func CreateSale(c *gin.Context) {
 			if selectedSupplier == nil {
 				tx.Rollback()
 				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Selected supplier ID %d not found or inactive for product %s", *itemReq.SupplierID, product.Name)})
-				return
+				return nil, false
 			}
 
-			// Check stock availability from selected supplier
-			if selectedSupplier.Stock < itemReq.Quantity {
+			// Check stock availability from selected supplier, less what parked carts hold
+			available := selectedSupplier.Stock - reservedStock(tx, product.ID, &selectedSupplier.ID, cartID, 0)
+			if available < itemReq.Quantity {
 				tx.Rollback()
 				c.JSON(http.StatusBadRequest, gin.H{
 					"error": fmt.Sprintf("Insufficient stock for product %s from selected supplier. Available: %d, Requested: %d",
-						product.Name, selectedSupplier.Stock, itemReq.Quantity),
+						product.Name, available, itemReq.Quantity),
 				})
-				return
+				return nil, false
 			}
 
+			listPrice = selectedSupplier.Price
+			listCost = selectedSupplier.Cost
+
 			// Use supplier's price and cost (or override if provided)
 			if itemReq.Price != nil {
-				usePrice = *itemReq.Price
+				usePrice = money.RoundUnit(*itemReq.Price)
 			} else {
 				usePrice = selectedSupplier.Price
 			}
 
 			if itemReq.Cost != nil {
-				useCost = *itemReq.Cost
+				useCost = money.RoundUnit(*itemReq.Cost)
 			} else {
 				useCost = selectedSupplier.Cost
 			}
@@ -169,16 +246,18 @@ func CreateSale(c *gin.Context) {
 					if err := tx.Save(&product.Suppliers[i]).Error; err != nil {
 						tx.Rollback()
 						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
-						return
+						return nil, false
 					}
 					break
 				}
 			}
 		} else {
 			// Legacy mode - use lowest price supplier (FIFO approach)
-			totalStock := product.GetTotalStock()
+			totalStock := product.GetTotalStock() - reservedStock(tx, product.ID, nil, cartID, 0)
 			usePrice = product.GetLowestPrice()
 			useCost = product.GetLowestCost()
+			listPrice = usePrice
+			listCost = useCost
 
 			// Check stock availability
 			if totalStock < itemReq.Quantity {
@@ -187,7 +266,7 @@ func CreateSale(c *gin.Context) {
 					"error": fmt.Sprintf("Insufficient stock for product %s. Available: %d, Requested: %d",
 						product.Name, totalStock, itemReq.Quantity),
 				})
-				return
+				return nil, false
 			}
 
 			// Update stock from suppliers (FIFO approach - use cheapest supplier first)
@@ -209,24 +288,52 @@ func CreateSale(c *gin.Context) {
 					if err := tx.Save(&product.Suppliers[i]).Error; err != nil {
 						tx.Rollback()
 						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
-						return
+						return nil, false
+					}
+				}
+			}
+		}
+
+		// Apply the price override policy
+		overridden, approvalReason := checkPriceOverride(listPrice, listCost, usePrice, useCost)
+		var approvedByID *uint
+		if overridden {
+			if canApprovePriceOverride(c) {
+				// Managers and admins approve their own overrides
+				id := userID.(uint)
+				approvedByID = &id
+			} else if approvalReason != "" {
+				if overrideApprover == nil {
+					approver, err := resolveOverrideApprover(tx, request.OverrideApproval)
+					if err != nil {
+						tx.Rollback()
+						c.JSON(http.StatusForbidden, gin.H{
+							"error": fmt.Sprintf("Manager approval required for %s on product %s: %v", approvalReason, product.Name, err),
+						})
+						return nil, false
 					}
+					overrideApprover = approver
 				}
+				approvedByID = &overrideApprover.ID
 			}
 		}
 
 		// Create sale item
-		itemTotal := float64(itemReq.Quantity) * usePrice
+		itemTotal := money.LineTotal(currency, itemReq.Quantity, usePrice)
 		saleItem := models.SaleItem{
-			ProductID: product.ID,
-			Quantity:  itemReq.Quantity,
-			Price:     usePrice,
-			Cost:      useCost,
-			Total:     itemTotal,
+			ProductID:            product.ID,
+			Quantity:             itemReq.Quantity,
+			Price:                usePrice,
+			Cost:                 useCost,
+			Total:                itemTotal,
+			ListPrice:            listPrice,
+			ListCost:             listCost,
+			PriceOverridden:      overridden,
+			OverrideApprovedByID: approvedByID,
+			OverrideReason:       approvalReason,
 		}
 
 		saleItems = append(saleItems, saleItem)
-		subtotal += itemTotal
 
 		// Create stock movement record
 		notes := "Sale transaction"
@@ -246,39 +353,23 @@ func CreateSale(c *gin.Context) {
 		if err := tx.Create(&movement).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
-			return
+			return nil, false
 		}
 	}
 
-	// Calculate total
-	sale.Subtotal = subtotal
-	sale.Total = subtotal + request.Tax - request.Discount
-
-	// Set payment amounts based on payment days
-	if request.PaymentDays == 0 || request.PaymentMethod != "credit" {
-		sale.AmountPaid = sale.Total
-		sale.AmountDue = 0
-		now := time.Now()
-		sale.PaidDate = &now
-	} else {
-		// For credit sales, set downpayment as amount paid
-		sale.AmountPaid = request.DownPayment
-		sale.AmountDue = sale.Total - request.DownPayment
-		
-		// If downpayment covers the full amount, mark as paid
-		if sale.AmountDue <= 0 {
-			sale.PaymentStatus = "paid"
-			now := time.Now()
-			sale.PaidDate = &now
-			sale.AmountDue = 0
-		}
+	// Work out the totals, tenders and amounts paid and due
+	payments, err := settleSale(&sale, saleItems, request, currency)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
 	}
 
 	// Save sale
 	if err := tx.Create(&sale).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sale"})
-		return
+		return nil, false
 	}
 
 	// Save sale items
@@ -289,34 +380,84 @@ func CreateSale(c *gin.Context) {
 	if err := tx.Create(&saleItems).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sale items"})
-		return
+		return nil, false
 	}
 
-	// Record downpayment if it's a credit sale with downpayment
-	if request.PaymentMethod == "credit" && request.DownPayment > 0 {
-		salePayment := models.SalePayment{
-			SaleID:        sale.ID,
-			UserID:        userID.(uint),
-			Amount:        request.DownPayment,
-			PaymentMethod: request.PaymentMethod,
-			PaymentType:   "downpayment",
-			Notes:         "Initial downpayment for credit sale",
-		}
-		
+	// Record each tender; for a credit sale they are the down payment
+	for _, salePayment := range payments {
+		salePayment.SaleID = sale.ID
 		if err := tx.Create(&salePayment).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record downpayment"})
-			return
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+			return nil, false
 		}
+		sale.Payments = append(sale.Payments, salePayment)
 	}
 
-	tx.Commit()
+	sale.Items = saleItems
+	if !recordAudit(c, tx, audit.ActionCreate, "sale", sale.ID, nil, sale) {
+		return nil, false
+	}
+	return &sale, true
+}
 
-	// Load complete sale data with items and products
-	var completeSale models.Sale
-	database.DB.Preload("Items.Product").Preload("User").First(&completeSale, sale.ID)
+// settleSale works out a sale's subtotal and total from its items, then the tenders, change and
+// amounts paid and due. It returns the payments to record, one per tender; for a credit sale they
+// are the down payment.
+func settleSale(sale *models.Sale, items []models.SaleItem, request SaleRequest, currency string) ([]models.SalePayment, error) {
+	sale.Subtotal = decimal.Zero
+	for _, item := range items {
+		sale.Subtotal = sale.Subtotal.Add(item.Total)
+	}
+	if sale.Discount.GreaterThan(sale.Subtotal) {
+		return nil, fmt.Errorf("discount can't exceed the sale subtotal")
+	}
+	sale.Total = documentTotal(sale.Subtotal, sale.Discount, sale.Tax)
 
-	c.JSON(http.StatusCreated, completeSale)
+	tenders, change, err := resolveSaleTenders(request, currency, sale.Total)
+	if err != nil {
+		return nil, err
+	}
+	sale.PaymentMethod = salePaymentMethod(request, tenders)
+	sale.ChangeGiven = change
+
+	now := time.Now()
+	if request.PaymentDays == 0 || request.PaymentMethod != "credit" {
+		sale.AmountPaid, sale.AmountDue = splitPayment(sale.Total, sale.Total)
+		sale.PaidDate = &now
+	} else {
+		// For credit sales, the down payment tenders are the amount paid
+		downPayment := decimal.Zero
+		for _, tender := range tenders {
+			downPayment = downPayment.Add(tender.Amount)
+		}
+		sale.DownPayment = downPayment
+		sale.AmountPaid, sale.AmountDue = splitPayment(sale.Total, downPayment)
+
+		// If downpayment covers the full amount, mark as paid
+		if !sale.AmountDue.IsPositive() {
+			sale.PaymentStatus = "paid"
+			sale.PaidDate = &now
+		}
+	}
+
+	payments := make([]models.SalePayment, 0, len(tenders))
+	for _, tender := range tenders {
+		payment := models.SalePayment{
+			UserID:        sale.UserID,
+			Amount:        tender.Amount,
+			Tendered:      tender.Tendered,
+			PaymentMethod: tender.Method,
+			PaymentType:   "tender",
+			ShiftID:       sale.ShiftID,
+		}
+		if request.PaymentMethod == "credit" {
+			payment.PaymentType = "downpayment"
+			payment.Notes = "Initial downpayment for credit sale"
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
 }
 
 // GetSales returns all sales with optional filtering
@@ -356,11 +497,11 @@ func GetSales(c *gin.Context) {
 		countQuery = countQuery.Where("user_id = ?", userID)
 	}
 
-	// Filter by payment method
+	// Filter by payment method, including split sales that used it as one of their tenders
 	if paymentMethod := c.Query("payment_method"); paymentMethod != "" {
 		filters["payment_method"] = paymentMethod
-		query = query.Where("payment_method = ?", paymentMethod)
-		countQuery = countQuery.Where("payment_method = ?", paymentMethod)
+		query = filterSalesByTender(query, paymentMethod)
+		countQuery = filterSalesByTender(countQuery, paymentMethod)
 	}
 
 	// Filter by payment status
@@ -406,7 +547,7 @@ func GetSale(c *gin.Context) {
 	}
 
 	var sale models.Sale
-	result := database.DB.Preload("Items.Product").Preload("User").First(&sale, id)
+	result := database.DB.Preload("Items.Product").Preload("Items.OverrideApprovedBy").Preload("User").Preload("Payments").First(&sale, id)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
 		return
@@ -432,30 +573,26 @@ func GetSalesReport(c *gin.Context) {
 		Count(&totalSales)
 
 	// Total revenue
-	var totalRevenue float64
+	var totalRevenue decimal.Decimal
 	database.DB.Model(&models.Sale{}).
 		Where("created_at >= ? AND created_at < ?", parsedStartDate, parsedEndDate).
 		Select("COALESCE(SUM(total), 0)").
 		Scan(&totalRevenue)
 
-	// Sales by payment method
-	var paymentMethodStats []struct {
-		PaymentMethod string  `json:"payment_method"`
-		Count         int64   `json:"count"`
-		Total         float64 `json:"total"`
+	// Revenue by tender; a split sale counts towards each of its tenders
+	paymentMethodStats, err := revenueByTender(database.DB, database.DB.Model(&models.Sale{}).
+		Where("created_at >= ? AND created_at < ?", parsedStartDate, parsedEndDate))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate revenue by tender"})
+		return
 	}
-	database.DB.Model(&models.Sale{}).
-		Select("payment_method, COUNT(*) as count, COALESCE(SUM(total), 0) as total").
-		Where("created_at >= ? AND created_at < ?", parsedStartDate, parsedEndDate).
-		Group("payment_method").
-		Scan(&paymentMethodStats)
 
 	// Top selling products
 	var topProducts []struct {
-		ProductID   uint    `json:"product_id"`
-		ProductName string  `json:"product_name"`
-		TotalSold   int64   `json:"total_sold"`
-		Revenue     float64 `json:"revenue"`
+		ProductID   uint            `json:"product_id"`
+		ProductName string          `json:"product_name"`
+		TotalSold   int64           `json:"total_sold"`
+		Revenue     decimal.Decimal `json:"revenue"`
 	}
 	database.DB.Table("sale_items si").
 		Select("si.product_id, p.name as product_name, SUM(si.quantity) as total_sold, SUM(si.total) as revenue").
@@ -611,6 +748,8 @@ func VoidSale(c *gin.Context) {
 		}
 	}
 
+	before := audit.Snapshot(sale)
+
 	// Update sale status
 	sale.Status = "cancelled"
 	if err := tx.Save(&sale).Error; err != nil {
@@ -619,6 +758,10 @@ func VoidSale(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, "void", "sale", sale.ID, before, sale) {
+		return
+	}
+
 	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{"message": "Sale voided successfully", "sale": sale})
@@ -688,11 +831,29 @@ func DeleteSale(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionDelete, "sale", sale.ID, sale, nil) {
+		return
+	}
+
 	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{"message": "Sale deleted successfully"})
 }
 
+// updateSalePaymentStatus marks a sale paid once nothing is due, otherwise pending or overdue
+func updateSalePaymentStatus(sale *models.Sale) {
+	if !sale.AmountDue.IsPositive() {
+		sale.PaymentStatus = "paid"
+		now := time.Now()
+		sale.PaidDate = &now
+		sale.AmountDue = decimal.Zero
+	} else if sale.DueDate != nil && time.Now().After(*sale.DueDate) {
+		sale.PaymentStatus = "overdue"
+	} else {
+		sale.PaymentStatus = "pending"
+	}
+}
+
 // RecordSalePayment records a payment for a sale
 func RecordSalePayment(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -702,15 +863,19 @@ func RecordSalePayment(c *gin.Context) {
 	}
 
 	var request struct {
-		Amount        float64 `json:"amount" binding:"required,min=0"`
-		PaymentMethod string  `json:"payment_method" binding:"required"`
-		Notes         string  `json:"notes"`
+		Amount        decimal.Decimal `json:"amount"`
+		PaymentMethod string          `json:"payment_method" binding:"required"`
+		Notes         string          `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !request.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount must be greater than 0"})
+		return
+	}
 
 	// Validate payment method
 	validPaymentMethods := []string{"cash", "card", "transfer", "credit"}
@@ -730,30 +895,18 @@ func RecordSalePayment(c *gin.Context) {
 	}
 
 	// Check if payment amount is valid
-	if request.Amount > sale.AmountDue {
+	amount := money.Round(baseCurrency(), request.Amount)
+	if amount.GreaterThan(sale.AmountDue) {
 		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount exceeds amount due"})
 		return
 	}
 
-	// Update payment amounts
-	sale.AmountPaid += request.Amount
-	sale.AmountDue -= request.Amount
+	before := audit.Snapshot(sale)
 
-	// Update payment status
-	if sale.AmountDue <= 0.01 { // Allow for small rounding differences
-		sale.PaymentStatus = "paid"
-		now := time.Now()
-		sale.PaidDate = &now
-		sale.AmountDue = 0 // Ensure it's exactly 0
-	} else {
-		// Check if overdue
-		if sale.DueDate != nil && time.Now().After(*sale.DueDate) {
-			sale.PaymentStatus = "overdue"
-		} else {
-			sale.PaymentStatus = "pending"
-		}
-	}
+	// Update payment amounts
+	sale.AmountPaid, sale.AmountDue = applyPayment(sale.AmountPaid, sale.AmountDue, amount)
+	updateSalePaymentStatus(&sale)
 
 	if err := tx.Save(&sale).Error; err != nil {
 		tx.Rollback()
@@ -761,16 +914,19 @@ func RecordSalePayment(c *gin.Context) {
 		return
 	}
 
-	// Record payment in SalePayment table
+	// Record payment in SalePayment table, in the shift of whoever took it
 	userID, _ := c.Get("user_id")
 	salePayment := models.SalePayment{
 		SaleID:        sale.ID,
 		UserID:        userID.(uint),
-		Amount:        request.Amount,
+		Amount:        amount,
 		PaymentMethod: request.PaymentMethod,
 		PaymentType:   "payment",
 		Notes:         request.Notes,
 	}
+	if shift := openShiftFor(tx, userID.(uint)); shift != nil {
+		salePayment.ShiftID = &shift.ID
+	}
 
 	if err := tx.Create(&salePayment).Error; err != nil {
 		tx.Rollback()
@@ -778,6 +934,10 @@ func RecordSalePayment(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, "payment", "sale", sale.ID, before, sale) {
+		return
+	}
+
 	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{
@@ -874,7 +1034,7 @@ func GetSalesSummary(c *gin.Context) {
 	db := database.GetDB()
 
 	// Calculate total sales amount
-	var totalSales float64
+	var totalSales decimal.Decimal
 	db.Model(&models.Sale{}).
 		Where("created_at BETWEEN ? AND ?", start, end).
 		Select("COALESCE(SUM(total), 0)").
@@ -887,25 +1047,33 @@ func GetSalesSummary(c *gin.Context) {
 		Count(&totalTransactions)
 
 	// Calculate pending payments (credit sales with amount due > 0)
-	var pendingPayments float64
+	var pendingPayments decimal.Decimal
 	db.Model(&models.Sale{}).
 		Where("created_at BETWEEN ? AND ? AND payment_method = ? AND amount_due > ?", start, end, "credit", 0).
 		Select("COALESCE(SUM(amount_due), 0)").
 		Scan(&pendingPayments)
 
 	// Calculate overdue payments (credit sales past due date with amount due > 0)
-	var overduePayments float64
+	var overduePayments decimal.Decimal
 	db.Model(&models.Sale{}).
 		Where("created_at BETWEEN ? AND ? AND payment_method = ? AND amount_due > ? AND due_date < ?", 
 			start, end, "credit", 0, time.Now()).
 		Select("COALESCE(SUM(amount_due), 0)").
 		Scan(&overduePayments)
 
+	// Revenue by tender
+	revenueByMethod, err := revenueByTender(db, db.Model(&models.Sale{}).Where("created_at BETWEEN ? AND ?", start, end))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate revenue by tender"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"total_sales":       totalSales,
 		"total_transactions": totalTransactions,
 		"pending_payments":   pendingPayments,
 		"overdue_payments":   overduePayments,
+		"revenue_by_tender":  revenueByMethod,
 		"period": gin.H{
 			"start_date": startDate,
 			"end_date":   endDate,
@@ -945,10 +1113,10 @@ func ExportSales(c *gin.Context) {
 	db := database.GetDB()
 
 	// Build query with filters
-	query := db.Preload("User").Preload("Items.Product").Where("created_at BETWEEN ? AND ?", start, end)
+	query := db.Preload("User").Preload("Items.Product").Preload("Payments").Where("created_at BETWEEN ? AND ?", start, end)
 
 	if paymentMethod != "" {
-		query = query.Where("payment_method = ?", paymentMethod)
+		query = filterSalesByTender(query, paymentMethod)
 	}
 
 	if paymentStatus != "" {
@@ -969,13 +1137,14 @@ func ExportSales(c *gin.Context) {
 	}
 
 	// Create CSV content
-	csvContent := "Sale Number,Customer Name,Date,Payment Method,Payment Status,Total,Amount Due,Cashier,Items\n"
+	csvContent := "Sale Number,Customer Name,Date,Payment Method,Tenders,Payment Status,Total,Change,Amount Due,Cashier,Items\n"
 
+	currency := baseCurrency()
 	for _, sale := range sales {
 		// Determine payment status
 		paymentStatusStr := "paid"
 		if sale.PaymentMethod == "credit" {
-			if sale.AmountDue > 0 {
+			if sale.AmountDue.IsPositive() {
 				if sale.DueDate != nil && sale.DueDate.Before(time.Now()) {
 					paymentStatusStr = "overdue"
 				} else {
@@ -995,7 +1164,7 @@ func ExportSales(c *gin.Context) {
 				if item.Product.ID != 0 {
 					productName = item.Product.Name
 				}
-				itemsSummary += fmt.Sprintf("%s (Qty: %d, Price: Rp%.2f)", productName, item.Quantity, item.Price)
+				itemsSummary += fmt.Sprintf("%s (Qty: %d, Price: Rp%s)", productName, item.Quantity, money.Format(currency, item.Price))
 			}
 		}
 
@@ -1013,14 +1182,16 @@ func ExportSales(c *gin.Context) {
 
 		itemsSummary = escapeCSV(itemsSummary)
 
-		csvContent += fmt.Sprintf("%s,%s,%s,%s,%s,%.2f,%.2f,%s,%s\n",
+		csvContent += fmt.Sprintf("%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s\n",
 			escapeCSV(sale.SaleNumber),
 			customerName,
 			sale.CreatedAt.Format("2006-01-02 15:04:05"),
 			escapeCSV(sale.PaymentMethod),
+			escapeCSV(formatTenders(sale)),
 			paymentStatusStr,
-			sale.Total,
-			sale.AmountDue,
+			money.Format(currency, sale.Total),
+			money.Format(currency, sale.ChangeGiven),
+			money.Format(currency, sale.AmountDue),
 			cashierName,
 			itemsSummary,
 		)
@@ -1077,10 +1248,10 @@ func ExportSalesExcel(c *gin.Context) {
 	db := database.GetDB()
 
 	// Build query with filters
-	query := db.Preload("User").Preload("Items.Product").Where("created_at BETWEEN ? AND ?", start, end)
+	query := db.Preload("User").Preload("Items.Product").Preload("Payments").Where("created_at BETWEEN ? AND ?", start, end)
 
 	if paymentMethod != "" {
-		query = query.Where("payment_method = ?", paymentMethod)
+		query = filterSalesByTender(query, paymentMethod)
 	}
 
 	if paymentStatus != "" {
@@ -1121,8 +1292,8 @@ func ExportSalesExcel(c *gin.Context) {
 
 	// Define headers
 	headers := []string{
-		"Sale Number", "Customer Name", "Date", "Payment Method", 
-		"Payment Status", "Total", "Amount Due", "Cashier", "Items Count", "Items Detail",
+		"Sale Number", "Customer Name", "Date", "Payment Method", "Tenders",
+		"Payment Status", "Total", "Change", "Amount Due", "Cashier", "Items Count", "Items Detail",
 	}
 
 	// Create header style
@@ -1177,13 +1348,14 @@ func ExportSalesExcel(c *gin.Context) {
 	}
 
 	// Add data rows
+	currency := baseCurrency()
 	for i, sale := range sales {
 		row := i + 2 // Start from row 2 (after headers)
 		
 		// Determine payment status
 		paymentStatusStr := "Paid"
 		if sale.PaymentMethod == "credit" {
-			if sale.AmountDue > 0 {
+			if sale.AmountDue.IsPositive() {
 				if sale.DueDate != nil && sale.DueDate.Before(time.Now()) {
 					paymentStatusStr = "Overdue"
 				} else {
@@ -1204,7 +1376,7 @@ func ExportSalesExcel(c *gin.Context) {
 				if item.Product.ID != 0 {
 					productName = item.Product.Name
 				}
-				itemsSummary += fmt.Sprintf("%s (Qty: %d, Price: Rp%.2f)", productName, item.Quantity, item.Price)
+				itemsSummary += fmt.Sprintf("%s (Qty: %d, Price: Rp%s)", productName, item.Quantity, money.Format(currency, item.Price))
 			}
 		}
 
@@ -1224,9 +1396,11 @@ func ExportSalesExcel(c *gin.Context) {
 			customerName,
 			sale.CreatedAt.Format("2006-01-02 15:04:05"),
 			sale.PaymentMethod,
+			formatTenders(sale),
 			paymentStatusStr,
-			sale.Total,
-			sale.AmountDue,
+			sale.Total.InexactFloat64(),
+			sale.ChangeGiven.InexactFloat64(),
+			sale.AmountDue.InexactFloat64(),
 			cashierName,
 			itemsCount,
 			itemsSummary,
@@ -1240,13 +1414,14 @@ func ExportSalesExcel(c *gin.Context) {
 	}
 
 	// Auto-fit columns
-	cols := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	cols := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L"}
 	for _, col := range cols {
 		f.SetColWidth(sheetName, col, col, 15)
 	}
 	
-	// Make the Items Detail column wider
-	f.SetColWidth(sheetName, "J", "J", 50)
+	// Make the Tenders and Items Detail columns wider
+	f.SetColWidth(sheetName, "E", "E", 30)
+	f.SetColWidth(sheetName, "L", "L", 50)
 
 	// Add summary information at the top
 	f.InsertRows(sheetName, 1, 3)
@@ -1274,6 +1449,42 @@ func ExportSalesExcel(c *gin.Context) {
 		f.SetCellStyle(sheetName, cell, cell, headerStyle)
 	}
 
+	// Revenue by tender on its own sheet; a split sale counts towards each of its tenders
+	var tenderTotals []TenderTotal
+	for _, sale := range sales {
+		for _, tender := range saleTenders(sale) {
+			found := false
+			for i := range tenderTotals {
+				if tenderTotals[i].PaymentMethod == tender.PaymentMethod {
+					tenderTotals[i].Count++
+					tenderTotals[i].Total = tenderTotals[i].Total.Add(tender.Total)
+					found = true
+					break
+				}
+			}
+			if !found {
+				tenderTotals = append(tenderTotals, tender)
+			}
+		}
+	}
+	tenderSheet := "By Tender"
+	if _, err := f.NewSheet(tenderSheet); err == nil {
+		for i, header := range []string{"Tender", "Sales", "Revenue"} {
+			cell := fmt.Sprintf("%c1", 'A'+i)
+			f.SetCellValue(tenderSheet, cell, header)
+			f.SetCellStyle(tenderSheet, cell, cell, headerStyle)
+		}
+		for i, tender := range tenderTotals {
+			row := i + 2
+			for j, value := range []interface{}{tender.PaymentMethod, tender.Count, tender.Total.InexactFloat64()} {
+				cell := fmt.Sprintf("%c%d", 'A'+j, row)
+				f.SetCellValue(tenderSheet, cell, value)
+				f.SetCellStyle(tenderSheet, cell, cell, dataStyle)
+			}
+		}
+		f.SetColWidth(tenderSheet, "A", "C", 15)
+	}
+
 	// Generate filename
 	filename := fmt.Sprintf("sales_report_%s.xlsx", time.Now().Format("2006-01-02"))
 	
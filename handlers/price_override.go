@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// defaultPriceOverrideLimit is the percentage an employee may move a price away
+// from the list price without manager approval
+const defaultPriceOverrideLimit = 10.0
+
+// PriceOverrideApproval carries the manager credentials authorising an override
+type PriceOverrideApproval struct {
+	ApproverEmail string `json:"approver_email" binding:"required,email"`
+	PIN           string `json:"pin" binding:"required"`
+}
+
+// priceOverrideLimit returns the allowed override percentage from PRICE_OVERRIDE_LIMIT_PERCENT
+func priceOverrideLimit() float64 {
+	if value := os.Getenv("PRICE_OVERRIDE_LIMIT_PERCENT"); value != "" {
+		if limit, err := strconv.ParseFloat(value, 64); err == nil && limit >= 0 {
+			return limit
+		}
+	}
+	return defaultPriceOverrideLimit
+}
+
+// checkPriceOverride reports whether a sale line deviates from the list values and,
+// if so, the reason it needs manager approval (empty when the employee may proceed)
+func checkPriceOverride(listPrice, listCost, price, cost decimal.Decimal) (overridden bool, approvalReason string) {
+	priceChanged := !price.Equal(listPrice)
+	costChanged := !cost.Equal(listCost)
+	if !priceChanged && !costChanged {
+		return false, ""
+	}
+
+	if costChanged {
+		return true, "cost override"
+	}
+
+	if price.LessThan(cost) {
+		return true, "price below cost"
+	}
+
+	limit := priceOverrideLimit()
+	if !listPrice.IsPositive() {
+		return true, "override of an unpriced item"
+	}
+	deviation := price.Sub(listPrice).Abs().Div(listPrice).Mul(decimal.NewFromInt(100)).InexactFloat64()
+	if deviation > limit {
+		return true, fmt.Sprintf("price deviates %.1f%% from list (limit %.1f%%)", deviation, limit)
+	}
+
+	return true, ""
+}
+
+// canApprovePriceOverride reports whether the authenticated user may approve their own price
+// overrides; with an API key, the key must carry the scope too
+func canApprovePriceOverride(c *gin.Context) bool {
+	return middleware.HasPermission(c, permissions.SalesOverride)
+}
+
+// resolveOverrideApprover verifies the approval credentials and returns the approving manager
+func resolveOverrideApprover(tx *gorm.DB, approval *PriceOverrideApproval) (*models.User, error) {
+	if approval == nil {
+		return nil, fmt.Errorf("manager approval required")
+	}
+
+	var approver models.User
+	if err := tx.Where("email = ? AND is_active = ?", approval.ApproverEmail, true).First(&approver).Error; err != nil {
+		return nil, fmt.Errorf("invalid approval credentials")
+	}
+
+	if !middleware.RoleHasPermission(approver.Role, permissions.SalesOverride) || approver.ApprovalPIN == "" {
+		return nil, fmt.Errorf("invalid approval credentials")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(approver.ApprovalPIN), []byte(approval.PIN)); err != nil {
+		return nil, fmt.Errorf("invalid approval credentials")
+	}
+
+	return &approver, nil
+}
@@ -0,0 +1,635 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// maxImportFileSize limits the size of uploaded import files (10 MB)
+const maxImportFileSize = 10 << 20
+
+// importFields lists the product fields an import file can be mapped to
+var importFields = []string{
+	"sku", "name", "description", "category", "location",
+	"supplier_id", "supplier_name", "cost", "price", "stock", "min_stock",
+}
+
+// ImportRowError describes a validation error on a single import row
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// importRow holds the mapped values of one data row
+type importRow struct {
+	line   int
+	values map[string]string
+}
+
+// importContext carries shared state while processing an import file
+type importContext struct {
+	c                 *gin.Context
+	tx                *gorm.DB
+	job               *models.ProductImportJob
+	userID            uint
+	defaultSupplierID uint
+	suppliers         map[string]*models.Supplier
+	seenSKUs          map[string]int
+}
+
+// ImportProducts creates or updates products and their supplier rows from a CSV or XLSX file
+func ImportProducts(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is too large (max 10 MB)"})
+		return
+	}
+
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(header.Filename), "."))
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file format. Use .csv or .xlsx"})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+
+	// Optional column mapping: {"sku": "Item Code", "price": "Retail Price", ...}
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid column mapping: " + err.Error()})
+			return
+		}
+	}
+	for field := range mapping {
+		if !slices.Contains(importFields, field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown import field in mapping: %s", field)})
+			return
+		}
+	}
+
+	var defaultSupplierID uint
+	if value := c.PostForm("supplier_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+			return
+		}
+		var supplier models.Supplier
+		if err := database.DB.Where("id = ? AND is_active = ?", parsed, true).First(&supplier).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+		defaultSupplierID = supplier.ID
+	}
+
+	records, err := readImportRecords(file, format, c.PostForm("sheet"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import file: " + err.Error()})
+		return
+	}
+
+	rows, err := mapImportRows(records, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	job := models.ProductImportJob{
+		UserID:    userID.(uint),
+		FileName:  header.Filename,
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    "pending",
+		TotalRows: len(rows),
+	}
+	if err := database.DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import job"})
+		return
+	}
+
+	// Every row runs inside one transaction so a dry run exercises exactly the
+	// same checks as a real import and a failed import leaves nothing behind
+	tx := database.DB.Begin()
+	ctx := &importContext{
+		c:                 c,
+		tx:                tx,
+		job:               &job,
+		userID:            userID.(uint),
+		defaultSupplierID: defaultSupplierID,
+		suppliers:         make(map[string]*models.Supplier),
+		seenSKUs:          make(map[string]int),
+	}
+
+	var rowErrors []ImportRowError
+	var failure error
+	for _, row := range rows {
+		created, errs, err := ctx.importRow(row)
+		if err != nil {
+			failure = fmt.Errorf("row %d: %w", row.line, err)
+			break
+		}
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+		if created {
+			job.CreatedCount++
+		} else {
+			job.UpdatedCount++
+		}
+	}
+
+	switch {
+	case failure != nil:
+		tx.Rollback()
+		job.Status = "failed"
+		job.Message = "Failed to import " + failure.Error()
+	case len(rowErrors) > 0:
+		tx.Rollback()
+		job.Status = "failed"
+		job.Message = "Import has validation errors; no changes were saved"
+		if dryRun {
+			job.Status = "validated"
+			job.Message = "Dry run found validation errors"
+		}
+	case dryRun:
+		tx.Rollback()
+		job.Status = "validated"
+		job.Message = "Dry run completed; no changes were saved"
+	default:
+		if err := tx.Commit().Error; err != nil {
+			job.Status = "failed"
+			job.Message = "Failed to save import: " + err.Error()
+		} else {
+			job.Status = "completed"
+			job.Message = "Import completed successfully"
+		}
+	}
+
+	if rowErrors == nil {
+		rowErrors = []ImportRowError{}
+	}
+	errorsJSON, _ := json.Marshal(rowErrors)
+	job.Errors = string(errorsJSON)
+	job.ErrorCount = len(rowErrors)
+	now := time.Now()
+	job.CompletedAt = &now
+	database.DB.Save(&job)
+
+	status := http.StatusOK
+	if failure != nil {
+		status = http.StatusInternalServerError
+	} else if job.Status == "failed" {
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.JSON(status, gin.H{
+		"success": job.Status != "failed",
+		"data":    job,
+		"errors":  rowErrors,
+	})
+}
+
+// importRow validates one row and applies it inside the import transaction. The error is a database
+// failure, after which the transaction is aborted and no further row can be imported.
+func (ctx *importContext) importRow(row importRow) (bool, []ImportRowError, error) {
+	var errs []ImportRowError
+	addError := func(field, message string) {
+		errs = append(errs, ImportRowError{Row: row.line, Field: field, Message: message})
+	}
+
+	sku := row.values["sku"]
+	if sku == "" {
+		addError("sku", "SKU is required")
+		return false, errs, nil
+	}
+	if previous, exists := ctx.seenSKUs[sku]; exists {
+		addError("sku", fmt.Sprintf("Duplicate SKU, already used on row %d", previous))
+		return false, errs, nil
+	}
+	ctx.seenSKUs[sku] = row.line
+
+	cost := parseImportAmount(row, "cost", addError)
+	price := parseImportAmount(row, "price", addError)
+	stock := parseImportInt(row, "stock", addError)
+	minStock := parseImportInt(row, "min_stock", addError)
+
+	var product models.Product
+	result := ctx.tx.Unscoped().Where("sku = ?", sku).First(&product)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return false, nil, result.Error
+	}
+	isNew := result.Error != nil
+	if !isNew && product.DeletedAt.Valid {
+		addError("sku", "SKU belongs to a deleted product")
+	}
+	if isNew && row.values["name"] == "" {
+		addError("name", "Name is required for new products")
+	}
+
+	supplier, problem, err := ctx.resolveSupplier(row)
+	if err != nil {
+		return false, nil, err
+	}
+	if problem != "" {
+		addError("supplier", problem)
+	}
+	hasSupplierValues := cost != nil || price != nil || stock != nil || minStock != nil
+	if supplier == nil && problem == "" && hasSupplierValues {
+		addError("supplier", "A supplier is required for cost, price and stock columns")
+	}
+
+	var productSupplier models.ProductSupplier
+	linkExists := false
+	if !isNew && supplier != nil {
+		err := ctx.tx.Where("product_id = ? AND supplier_id = ?", product.ID, supplier.ID).First(&productSupplier).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil, err
+		}
+		linkExists = err == nil
+	}
+	if supplier != nil && !linkExists {
+		if cost == nil {
+			addError("cost", "Cost is required when linking a new supplier")
+		}
+		if price == nil {
+			addError("price", "Price is required when linking a new supplier")
+		}
+	}
+
+	if len(errs) > 0 {
+		return false, errs, nil
+	}
+
+	// Create or update the product itself
+	if isNew {
+		product = models.Product{
+			Name:        row.values["name"],
+			SKU:         sku,
+			Description: row.values["description"],
+			Category:    row.values["category"],
+			Location:    row.values["location"],
+			IsActive:    true,
+		}
+		if err := ctx.tx.Create(&product).Error; err != nil {
+			return false, nil, fmt.Errorf("creating product: %w", err)
+		}
+		if err := audit.Record(ctx.tx, ctx.c, audit.ActionCreate, "product", product.ID, nil, product); err != nil {
+			return false, nil, fmt.Errorf("writing audit log: %w", err)
+		}
+	} else {
+		before := audit.Snapshot(product)
+		for field, target := range map[string]*string{
+			"name":        &product.Name,
+			"description": &product.Description,
+			"category":    &product.Category,
+			"location":    &product.Location,
+		} {
+			if value := row.values[field]; value != "" {
+				*target = value
+			}
+		}
+		if err := ctx.tx.Save(&product).Error; err != nil {
+			return false, nil, fmt.Errorf("updating product: %w", err)
+		}
+		if err := audit.Record(ctx.tx, ctx.c, audit.ActionUpdate, "product", product.ID, before, product); err != nil {
+			return false, nil, fmt.Errorf("writing audit log: %w", err)
+		}
+	}
+
+	if supplier == nil {
+		return isNew, nil, nil
+	}
+
+	reference := fmt.Sprintf("Import #%d", ctx.job.ID)
+
+	if !linkExists {
+		productSupplier = models.ProductSupplier{
+			ProductID:  product.ID,
+			SupplierID: supplier.ID,
+			Cost:       *cost,
+			Price:      *price,
+			MinStock:   10,
+			IsActive:   true,
+		}
+		if stock != nil {
+			productSupplier.Stock = *stock
+		}
+		if minStock != nil {
+			productSupplier.MinStock = *minStock
+		}
+		if err := ctx.tx.Create(&productSupplier).Error; err != nil {
+			return false, nil, fmt.Errorf("linking supplier: %w", err)
+		}
+		if err := audit.Record(ctx.tx, ctx.c, audit.ActionCreate, "product_supplier", productSupplier.ID, nil, productSupplier); err != nil {
+			return false, nil, fmt.Errorf("writing audit log: %w", err)
+		}
+
+		if productSupplier.Stock > 0 {
+			if err := ctx.recordMovement(product.ID, "in", productSupplier.Stock, reference, supplier.Name); err != nil {
+				return false, nil, err
+			}
+		}
+		return isNew, nil, nil
+	}
+
+	before := audit.Snapshot(productSupplier)
+	previousStock := productSupplier.Stock
+	if cost != nil {
+		productSupplier.Cost = *cost
+	}
+	if price != nil {
+		productSupplier.Price = *price
+	}
+	if stock != nil {
+		productSupplier.Stock = *stock
+	}
+	if minStock != nil {
+		productSupplier.MinStock = *minStock
+	}
+	if err := ctx.tx.Save(&productSupplier).Error; err != nil {
+		return false, nil, fmt.Errorf("updating supplier pricing: %w", err)
+	}
+	if err := audit.Record(ctx.tx, ctx.c, audit.ActionUpdate, "product_supplier", productSupplier.ID, before, productSupplier); err != nil {
+		return false, nil, fmt.Errorf("writing audit log: %w", err)
+	}
+
+	if productSupplier.Stock != previousStock {
+		if err := ctx.recordMovement(product.ID, "adjustment", productSupplier.Stock, reference, supplier.Name); err != nil {
+			return false, nil, err
+		}
+	}
+
+	return isNew, nil, nil
+}
+
+// resolveSupplier finds the supplier for a row from its supplier_id or supplier_name
+// column, falling back to the supplier chosen for the whole import. The message is a
+// problem with the row; the error is a database failure.
+func (ctx *importContext) resolveSupplier(row importRow) (*models.Supplier, string, error) {
+	key := ""
+	query := ctx.tx.Where("is_active = ?", true)
+
+	switch {
+	case row.values["supplier_id"] != "":
+		id, err := strconv.ParseUint(row.values["supplier_id"], 10, 32)
+		if err != nil {
+			return nil, fmt.Sprintf("invalid supplier ID %q", row.values["supplier_id"]), nil
+		}
+		key = "id:" + row.values["supplier_id"]
+		query = query.Where("id = ?", id)
+	case row.values["supplier_name"] != "":
+		key = "name:" + strings.ToLower(row.values["supplier_name"])
+		query = query.Where("LOWER(name) = ?", strings.ToLower(row.values["supplier_name"]))
+	case ctx.defaultSupplierID != 0:
+		key = fmt.Sprintf("id:%d", ctx.defaultSupplierID)
+		query = query.Where("id = ?", ctx.defaultSupplierID)
+	default:
+		return nil, "", nil
+	}
+
+	if supplier, cached := ctx.suppliers[key]; cached {
+		if supplier == nil {
+			return nil, "supplier not found", nil
+		}
+		return supplier, "", nil
+	}
+
+	var supplier models.Supplier
+	if err := query.First(&supplier).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", err
+		}
+		ctx.suppliers[key] = nil
+		return nil, "supplier not found", nil
+	}
+	ctx.suppliers[key] = &supplier
+	return &supplier, "", nil
+}
+
+// recordMovement writes the stock movement for an imported stock level
+func (ctx *importContext) recordMovement(productID uint, movementType string, quantity int, reference, supplierName string) error {
+	movement := models.StockMovement{
+		ProductID: productID,
+		UserID:    ctx.userID,
+		Type:      movementType,
+		Quantity:  quantity,
+		Reference: reference,
+		Notes:     fmt.Sprintf("Product import - Supplier: %s", supplierName),
+	}
+	if err := ctx.tx.Create(&movement).Error; err != nil {
+		return fmt.Errorf("recording stock movement: %w", err)
+	}
+	return nil
+}
+
+// parseImportAmount parses an optional non-negative price or cost column, kept to UnitScale decimals
+func parseImportAmount(row importRow, field string, addError func(field, message string)) *decimal.Decimal {
+	raw := row.values[field]
+	if raw == "" {
+		return nil
+	}
+	value, err := decimal.NewFromString(strings.ReplaceAll(raw, ",", ""))
+	if err != nil || value.IsNegative() {
+		addError(field, fmt.Sprintf("Invalid %s value %q", field, raw))
+		return nil
+	}
+	value = money.RoundUnit(value)
+	return &value
+}
+
+// parseImportInt parses an optional non-negative whole number column
+func parseImportInt(row importRow, field string, addError func(field, message string)) *int {
+	raw := row.values[field]
+	if raw == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		// Spreadsheets often store whole numbers as "12.0"
+		if f, ferr := strconv.ParseFloat(raw, 64); ferr == nil && f == float64(int(f)) {
+			value, err = int(f), nil
+		}
+	}
+	if err != nil || value < 0 {
+		addError(field, fmt.Sprintf("Invalid %s value %q", field, raw))
+		return nil
+	}
+	return &value
+}
+
+// readImportRecords reads all rows of a CSV file or an XLSX sheet
+func readImportRecords(file io.Reader, format, sheet string) ([][]string, error) {
+	if format == "csv" {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		return records, nil
+	}
+
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		sheet = sheets[0]
+	}
+	return f.GetRows(sheet)
+}
+
+// mapImportRows resolves the header row against the column mapping and returns
+// the data rows keyed by import field
+func mapImportRows(records [][]string, mapping map[string]string) ([]importRow, error) {
+	if len(records) < 2 {
+		return nil, fmt.Errorf("import file has no data rows")
+	}
+
+	headers := records[0]
+	columns := make(map[string]int)
+	for _, field := range importFields {
+		wanted := field
+		if header, ok := mapping[field]; ok {
+			wanted = header
+		}
+		for i, header := range headers {
+			if strings.TrimSpace(header) == wanted || normalizeImportHeader(header) == normalizeImportHeader(wanted) {
+				columns[field] = i
+				break
+			}
+		}
+		if _, ok := mapping[field]; ok {
+			if _, found := columns[field]; !found {
+				return nil, fmt.Errorf("mapped column %q for %s not found in file", mapping[field], field)
+			}
+		}
+	}
+
+	if _, ok := columns["sku"]; !ok {
+		return nil, fmt.Errorf("import file must have a SKU column")
+	}
+
+	var rows []importRow
+	for i, record := range records[1:] {
+		values := make(map[string]string)
+		empty := true
+		for field, index := range columns {
+			if index < len(record) {
+				value := strings.TrimSpace(record[index])
+				values[field] = value
+				if value != "" {
+					empty = false
+				}
+			}
+		}
+		if empty {
+			continue
+		}
+		// Row numbers match the spreadsheet, counting the header as row 1
+		rows = append(rows, importRow{line: i + 2, values: values})
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("import file has no data rows")
+	}
+	return rows, nil
+}
+
+// normalizeImportHeader lowercases a header and turns spaces and dashes into underscores
+func normalizeImportHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(header)
+}
+
+// GetProductImportJobs returns the history of product imports
+func GetProductImportJobs(c *gin.Context) {
+	var jobs []models.ProductImportJob
+	query := database.DB.Model(&models.ProductImportJob{}).Preload("User")
+
+	// Pagination
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	result := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&jobs)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch import jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  jobs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetProductImportJob returns a single import job with its row errors
+func GetProductImportJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID"})
+		return
+	}
+
+	var job models.ProductImportJob
+	if err := database.DB.Preload("User").First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+
+	var rowErrors []ImportRowError
+	if job.Errors != "" {
+		json.Unmarshal([]byte(job.Errors), &rowErrors)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":    job,
+		"errors": rowErrors,
+	})
+}
@@ -7,10 +7,13 @@ import (
 	"strconv"
 	"time"
 
+	"inventory_system/audit"
 	"inventory_system/database"
 	"inventory_system/models"
+	"inventory_system/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -19,21 +22,25 @@ type CreatePurchaseOrderRequest struct {
 	SupplierID    uint                      `json:"supplier_id" binding:"required"`
 	PaymentMethod string                    `json:"payment_method"`
 	PaymentDays   int                       `json:"payment_days"` // Number of days for payment due
-	DownPayment   float64                   `json:"down_payment"`
+	DownPayment   decimal.Decimal           `json:"down_payment"`
+	Currency      string                    `json:"currency"`      // Defaults to the supplier's currency
+	ExchangeRate  decimal.Decimal           `json:"exchange_rate"` // Base currency per unit of Currency, defaults to the rate on the order date
 	Notes         string                    `json:"notes"`
 	OrderDate     string                    `json:"order_date" binding:"required"`
+	ExpectedDate  string                    `json:"expected_date"` // YYYY-MM-DD, delivery date promised by the supplier
+	ReceivedDate  string                    `json:"received_date"` // YYYY-MM-DD, defaults to today as the items are received with the order
 	Items         []CreatePurchaseOrderItem `json:"items" binding:"required,min=1"`
 }
 
 // CreatePurchaseOrderItem represents an item in the purchase order request
 type CreatePurchaseOrderItem struct {
-	SKU               string  `json:"sku" binding:"required"`
-	ProductName       string  `json:"product_name"`
-	Category          string  `json:"category"`
-	Description       string  `json:"description"`
-	Quantity          int     `json:"quantity" binding:"required,min=1"`
-	UnitCost          float64 `json:"unit_cost" binding:"required,min=0"`
-	ProductSupplierID *uint   `json:"product_supplier_id"` // Link to specific supplier for existing products
+	SKU               string          `json:"sku" binding:"required"`
+	ProductName       string          `json:"product_name"`
+	Category          string          `json:"category"`
+	Description       string          `json:"description"`
+	Quantity          int             `json:"quantity" binding:"required,min=1"`
+	UnitCost          decimal.Decimal `json:"unit_cost"`
+	ProductSupplierID *uint           `json:"product_supplier_id"` // Link to specific supplier for existing products
 }
 
 // CreatePurchaseOrder creates a new purchase order with SKU-based product handling
@@ -48,12 +55,38 @@ func CreatePurchaseOrder(c *gin.Context) {
 	fmt.Printf("Received purchase order request: %+v\n", req)
 
 	// Get user ID from context
-	userID, exists := c.Get("user_id")
-	if !exists {
+	if _, exists := c.Get("user_id"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
+	// Start transaction
+	tx := database.DB.Begin()
+
+	po, ok := createPurchaseOrderTx(c, tx, req, true)
+	if !ok {
+		return
+	}
+
+	// Commit transaction
+	tx.Commit()
+
+	// Load complete purchase order with relationships
+	var completePO models.PurchaseOrder
+	database.DB.Preload("User").Preload("Supplier").Preload("Items.Product").Preload("Items.ProductSupplier.Supplier").First(&completePO, po.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    completePO,
+	})
+}
+
+// createPurchaseOrderTx creates a purchase order in tx. With receive its items are received into
+// stock right away; otherwise the order waits for ReceivePurchaseOrder. On failure it rolls tx back,
+// responds and returns false.
+func createPurchaseOrderTx(c *gin.Context, tx *gorm.DB, req CreatePurchaseOrderRequest, receive bool) (*models.PurchaseOrder, bool) {
+	userID := c.GetUint("user_id")
+
 	// Set default payment method and days if not provided
 	if req.PaymentMethod == "" {
 		req.PaymentMethod = "cash"
@@ -68,21 +101,58 @@ func CreatePurchaseOrder(c *gin.Context) {
 	// Validate payment method
 	validPaymentMethods := []string{"cash", "transfer", "credit", "qris"}
 	if !slices.Contains(validPaymentMethods, req.PaymentMethod) {
+		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method"})
-		return
+		return nil, false
 	}
 
 	// Validate payment days
 	if req.PaymentDays < 0 || req.PaymentDays > 365 {
+		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment days must be between 0 and 365"})
-		return
+		return nil, false
 	}
 
 	// Parse dates
 	orderDate, err := time.Parse("2006-01-02", req.OrderDate)
 	if err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order date format. Use YYYY-MM-DD"})
-		return
+		return nil, false
+	}
+
+	// Delivery dates feed the supplier scorecards
+	var expectedDate *time.Time
+	if req.ExpectedDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.ExpectedDate)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expected date format. Use YYYY-MM-DD"})
+			return nil, false
+		}
+		expectedDate = &parsed
+	}
+	receivedDate := time.Now()
+	if req.ReceivedDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.ReceivedDate)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid received date format. Use YYYY-MM-DD"})
+			return nil, false
+		}
+		receivedDate = parsed
+	}
+	if receivedDate.Before(orderDate) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Received date can't be before the order date"})
+		return nil, false
+	}
+	for _, item := range req.Items {
+		if item.UnitCost.IsNegative() {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unit cost of %s can't be negative", item.SKU)})
+			return nil, false
+		}
 	}
 
 	// Generate PO number
@@ -103,14 +173,11 @@ func CreatePurchaseOrder(c *gin.Context) {
 		paidDate = &orderDate // Set paid date to order date for cash/transfer
 	}
 
-	// Start transaction
-	tx := database.DB.Begin()
-
 	// Create purchase order
 	po := models.PurchaseOrder{
 		PONumber:      poNumber,
 		SupplierID:    req.SupplierID,
-		UserID:        userID.(uint),
+		UserID:        userID,
 		PaymentMethod: req.PaymentMethod,
 		PaymentDays:   req.PaymentDays,
 		PaymentStatus: paymentStatus,
@@ -118,13 +185,17 @@ func CreatePurchaseOrder(c *gin.Context) {
 		DueDate:       dueDate,
 		Notes:         req.Notes,
 		OrderDate:     orderDate,
+		ExpectedDate:  expectedDate,
+	}
+	if receive {
+		po.ReceivedDate = &receivedDate
 	}
 
 	if err := tx.Create(&po).Error; err != nil {
 		tx.Rollback()
 		fmt.Printf("Failed to create purchase order: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create purchase order: %v", err)})
-		return
+		return nil, false
 	}
 
 	// Load supplier to get supplier name
@@ -133,58 +204,106 @@ func CreatePurchaseOrder(c *gin.Context) {
 		tx.Rollback()
 		fmt.Printf("Failed to find supplier with ID %d: %v\n", po.SupplierID, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid supplier ID %d: %v", po.SupplierID, err)})
-		return
+		return nil, false
 	}
 
-	var totalAmount float64
+	// Amounts are in the supplier's currency and converted to the base currency at the order date's rate
+	base := baseCurrency()
+	po.Currency = base
+	if supplier.Currency != "" {
+		po.Currency = supplier.Currency
+	}
+	if req.Currency != "" {
+		if po.Currency, err = normalizeCurrency(req.Currency); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Currency must be a three-letter ISO code"})
+			return nil, false
+		}
+	}
+	if req.ExchangeRate.IsNegative() {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exchange rate must be greater than 0"})
+		return nil, false
+	}
+	po.ExchangeRate = money.RoundRate(req.ExchangeRate)
+	if po.Currency == base {
+		po.ExchangeRate = decimal.NewFromInt(1)
+	} else if po.ExchangeRate.IsZero() {
+		if po.ExchangeRate, err = lookupExchangeRate(tx, po.Currency, base, orderDate); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Enter an exchange rate or pass exchange_rate: %v", err)})
+			return nil, false
+		}
+	}
+
+	var poItems []models.PurchaseOrderItem
+	var leadTime int
 
 	// Process each item
 	for i, item := range req.Items {
 		fmt.Printf("Processing item %d: %+v\n", i+1, item)
 
-		// Find or create product by SKU
-		product, productSupplier, err := findOrCreateProductWithSupplier(tx, item, req.SupplierID)
+		// Find or create product by SKU, keeping the supplier's cost in the base currency
+		unitCost := money.RoundUnit(item.UnitCost)
+		baseCost := money.RoundUnit(unitCost.Mul(po.ExchangeRate))
+		product, productSupplier, err := findOrCreateProductWithSupplier(tx, item, baseCost, req.SupplierID)
 		if err != nil {
 			tx.Rollback()
 			fmt.Printf("Failed to process product with SKU %s: %v\n", item.SKU, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to process product with SKU %s: %v", item.SKU, err)})
-			return
+			return nil, false
+		}
+
+		// Respect the supplier's minimum order quantity and pack size
+		if productSupplier != nil {
+			if err := validateOrderQuantity(productSupplier, item.Quantity); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid quantity %d for SKU %s: %v", item.Quantity, item.SKU, err)})
+				return nil, false
+			}
+			leadTime = max(leadTime, productSupplier.LeadTimeDays)
 		}
 
-		// Update supplier-specific stock
+		// Update supplier-specific stock, and the cost when the order isn't received yet
 		if productSupplier != nil {
-			productSupplier.Stock += item.Quantity
+			if receive {
+				productSupplier.Stock += item.Quantity
+			}
 			if err := tx.Save(productSupplier).Error; err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
-				return
+				return nil, false
 			}
 		}
 
 		// Create stock movement record
-		stockMovement := models.StockMovement{
-			ProductID: product.ID,
-			UserID:    userID.(uint),
-			Type:      "in",
-			Quantity:  item.Quantity,
-			Reference: po.PONumber,
-			Notes:     fmt.Sprintf("Purchase Order %s - %s", po.PONumber, supplier.Name),
-		}
-		if err := tx.Create(&stockMovement).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock movement record"})
-			return
+		if receive {
+			stockMovement := models.StockMovement{
+				ProductID: product.ID,
+				UserID:    userID,
+				Type:      "in",
+				Quantity:  item.Quantity,
+				Reference: po.PONumber,
+				Notes:     fmt.Sprintf("Purchase Order %s - %s", po.PONumber, supplier.Name),
+			}
+			if err := tx.Create(&stockMovement).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock movement record"})
+				return nil, false
+			}
 		}
 
 		// Create purchase order item
-		total := float64(item.Quantity) * item.UnitCost
+		total := money.LineTotal(po.Currency, item.Quantity, unitCost)
 		poItem := models.PurchaseOrderItem{
-			PurchaseOrderID:  po.ID,
-			ProductID:        product.ID,
-			QuantityOrdered:  item.Quantity,
-			QuantityReceived: item.Quantity, // Mark as received since we're updating inventory
-			UnitCost:         item.UnitCost,
-			Total:            total,
+			PurchaseOrderID: po.ID,
+			ProductID:       product.ID,
+			QuantityOrdered: item.Quantity,
+			UnitCost:        unitCost,
+			Total:           total,
+		}
+		if receive {
+			poItem.QuantityReceived = item.Quantity // Mark as received since we're updating inventory
 		}
 
 		// Link to specific product-supplier relationship if available
@@ -195,85 +314,91 @@ func CreatePurchaseOrder(c *gin.Context) {
 		if err := tx.Create(&poItem).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase order item"})
-			return
+			return nil, false
 		}
 
-		totalAmount += total
+		poItems = append(poItems, poItem)
+	}
+
+	// Without a promised date, expect delivery after the longest supplier lead time
+	if po.ExpectedDate == nil && leadTime > 0 {
+		expected := orderDate.AddDate(0, 0, leadTime)
+		po.ExpectedDate = &expected
 	}
 
 	// Update purchase order total and amount due
-	po.Total = totalAmount
-	po.DownPayment = req.DownPayment
-
-	// Calculate amounts based on payment method
-	if req.PaymentMethod == "credit" {
-		// For credit payments, handle downpayment
-		if req.DownPayment > 0 {
-			// Validate downpayment doesn't exceed total
-			if req.DownPayment > totalAmount {
-				tx.Rollback()
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Downpayment cannot exceed total amount"})
-				return
-			}
-			po.AmountPaid = req.DownPayment
-			po.AmountDue = totalAmount - req.DownPayment
-
-			// If downpayment covers the full amount, mark as paid
-			if po.AmountDue <= 0.01 {
-				po.PaymentStatus = "paid"
-				now := time.Now()
-				po.PaidDate = &now
-			}
-		} else {
-			// Credit with no downpayment - nothing paid yet
-			po.AmountPaid = 0
-			po.AmountDue = totalAmount
-		}
-	} else {
-		// For cash or transfer, mark as paid immediately
-		po.AmountDue = 0
-		po.AmountPaid = totalAmount
+	if err := settlePurchaseOrder(&po, poItems, req.PaymentMethod, req.DownPayment); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
 	}
+	po.TotalBase = toBase(po.Total, po.ExchangeRate)
 
 	if err := tx.Save(&po).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order total"})
-		return
+		return nil, false
 	}
 
 	// Record downpayment in payment history if applicable
-	if req.PaymentMethod == "credit" && req.DownPayment > 0 {
+	if req.PaymentMethod == "credit" && po.DownPayment.IsPositive() {
 		payment := models.PurchasePayment{
 			PurchaseOrderID: po.ID,
-			UserID:          userID.(uint),
-			Amount:          req.DownPayment,
+			UserID:          userID,
+			Amount:          po.DownPayment,
 			PaymentMethod:   req.PaymentMethod,
 			PaymentType:     "downpayment",
 			Notes:           fmt.Sprintf("Down payment for PO %s", po.PONumber),
 		}
+		atOrderRate(&payment, &po)
 
 		if err := tx.Create(&payment).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record downpayment history"})
-			return
+			return nil, false
 		}
 	}
 
-	// Commit transaction
-	tx.Commit()
+	var createdPO models.PurchaseOrder
+	tx.Preload("Items").First(&createdPO, po.ID)
+	if !recordAudit(c, tx, audit.ActionCreate, "purchase_order", po.ID, nil, createdPO) {
+		return nil, false
+	}
 
-	// Load complete purchase order with relationships
-	var completePO models.PurchaseOrder
-	database.DB.Preload("User").Preload("Supplier").Preload("Items.Product").Preload("Items.ProductSupplier.Supplier").First(&completePO, po.ID)
+	return &po, true
+}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"success": true,
-		"data":    completePO,
-	})
+// settlePurchaseOrder works out a purchase order's total from its items and what is paid and due.
+// Credit orders have paid their down payment, which can't exceed the total; others are paid in full.
+func settlePurchaseOrder(po *models.PurchaseOrder, items []models.PurchaseOrderItem, paymentMethod string, downPayment decimal.Decimal) error {
+	po.Total = decimal.Zero
+	for _, item := range items {
+		po.Total = po.Total.Add(item.Total)
+	}
+	po.DownPayment = money.Round(po.Currency, downPayment)
+
+	if paymentMethod != "credit" {
+		// For cash or transfer, mark as paid immediately
+		po.AmountPaid, po.AmountDue = splitPayment(po.Total, po.Total)
+		return nil
+	}
+	if po.DownPayment.GreaterThan(po.Total) {
+		return fmt.Errorf("down payment can't exceed the total amount")
+	}
+	po.AmountPaid, po.AmountDue = splitPayment(po.Total, po.DownPayment)
+
+	// If downpayment covers the full amount, mark as paid
+	if po.DownPayment.IsPositive() && !po.AmountDue.IsPositive() {
+		po.PaymentStatus = "paid"
+		now := time.Now()
+		po.PaidDate = &now
+	}
+	return nil
 }
 
-// findOrCreateProductWithSupplier finds an existing product by SKU or creates a new one, and handles supplier relationship
-func findOrCreateProductWithSupplier(tx *gorm.DB, item CreatePurchaseOrderItem, supplierID uint) (*models.Product, *models.ProductSupplier, error) {
+// findOrCreateProductWithSupplier finds an existing product by SKU or creates a new one, and handles supplier relationship.
+// unitCost is the item's cost in the base currency, kept as the supplier's cost.
+func findOrCreateProductWithSupplier(tx *gorm.DB, item CreatePurchaseOrderItem, unitCost decimal.Decimal, supplierID uint) (*models.Product, *models.ProductSupplier, error) {
 	var product models.Product
 
 	// Try to find existing product by SKU
@@ -308,14 +433,14 @@ func findOrCreateProductWithSupplier(tx *gorm.DB, item CreatePurchaseOrderItem,
 	// Check if product-supplier relationship exists
 	result = tx.Where("product_id = ? AND supplier_id = ?", product.ID, supplierID).First(&productSupplier)
 	if result.Error != nil {
-		// Create new product-supplier relationship
+		// Create new product-supplier relationship, priced at a default markup of 20%
 		productSupplier = models.ProductSupplier{
 			ProductID:  product.ID,
 			SupplierID: supplierID,
-			Cost:       item.UnitCost,
-			Price:      item.UnitCost * 1.2, // Default markup of 20%
-			Stock:      0,                   // Will be updated by caller
-			MinStock:   10,                  // Default minimum stock
+			Cost:       unitCost,
+			Price:      money.RoundUnit(unitCost.Mul(decimal.NewFromFloat(1.2))),
+			Stock:      0,  // Will be updated by caller
+			MinStock:   10, // Default minimum stock
 			IsActive:   true,
 		}
 
@@ -324,8 +449,8 @@ func findOrCreateProductWithSupplier(tx *gorm.DB, item CreatePurchaseOrderItem,
 		}
 	} else {
 		// Update cost if different (in case prices changed)
-		if productSupplier.Cost != item.UnitCost {
-			productSupplier.Cost = item.UnitCost
+		if !productSupplier.Cost.Equal(unitCost) {
+			productSupplier.Cost = unitCost
 		}
 	}
 
@@ -351,6 +476,10 @@ func GetPurchaseOrders(c *gin.Context) {
 	var purchaseOrders []models.PurchaseOrder
 	query := database.DB.Model(&models.PurchaseOrder{}).Preload("User").Preload("Supplier")
 
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
 	// Pagination
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
@@ -400,9 +529,10 @@ func RecordPurchasePayment(c *gin.Context) {
 	}
 
 	var request struct {
-		Amount        float64 `json:"amount" binding:"required,min=0"`
-		PaymentMethod string  `json:"payment_method" binding:"required"`
-		Notes         string  `json:"notes"`
+		Amount        decimal.Decimal `json:"amount"`
+		PaymentMethod string          `json:"payment_method" binding:"required"`
+		ExchangeRate  decimal.Decimal `json:"exchange_rate"` // Base currency per unit of the order's currency, defaults to today's rate
+		Notes         string          `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -410,6 +540,15 @@ func RecordPurchasePayment(c *gin.Context) {
 		return
 	}
 
+	if !request.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount must be greater than 0"})
+		return
+	}
+	if request.ExchangeRate.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exchange rate must be greater than 0"})
+		return
+	}
+
 	// Validate payment method
 	validPaymentMethods := []string{"cash", "card", "transfer", "check", "wire"}
 	if !slices.Contains(validPaymentMethods, request.PaymentMethod) {
@@ -428,30 +567,36 @@ func RecordPurchasePayment(c *gin.Context) {
 	}
 
 	// Check if payment amount is valid
-	if request.Amount > po.AmountDue {
+	amount := money.Round(po.Currency, request.Amount)
+	if amount.GreaterThan(po.AmountDue) {
 		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount exceeds amount due"})
 		return
 	}
 
+	// Foreign currency payments settle at the day's rate; the difference to the order's rate is a
+	// realised exchange gain or loss
+	paymentRate := po.ExchangeRate
+	if base := baseCurrency(); po.Currency != base {
+		paymentRate = money.RoundRate(request.ExchangeRate)
+		if paymentRate.IsZero() {
+			if paymentRate, err = lookupExchangeRate(tx, po.Currency, base, time.Now()); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Enter an exchange rate or pass exchange_rate: %v", err)})
+				return
+			}
+		}
+	}
+	amountBase := toBase(amount, paymentRate)
+	fxGainLoss := toBase(amount, po.ExchangeRate).Sub(amountBase)
+
+	before := audit.Snapshot(po)
+
 	// Update payment amounts
-	po.AmountPaid += request.Amount
-	po.AmountDue -= request.Amount
+	po.AmountPaid, po.AmountDue = applyPayment(po.AmountPaid, po.AmountDue, amount)
 
 	// Update payment status
-	if po.AmountDue <= 0.01 { // Allow for small rounding differences
-		po.PaymentStatus = "paid"
-		now := time.Now()
-		po.PaidDate = &now
-		po.AmountDue = 0 // Ensure it's exactly 0
-	} else {
-		// Check if overdue
-		if po.DueDate != nil && time.Now().After(*po.DueDate) {
-			po.PaymentStatus = "overdue"
-		} else {
-			po.PaymentStatus = "pending"
-		}
-	}
+	updatePurchasePaymentStatus(&po)
 
 	if err := tx.Save(&po).Error; err != nil {
 		tx.Rollback()
@@ -466,9 +611,12 @@ func RecordPurchasePayment(c *gin.Context) {
 	payment := models.PurchasePayment{
 		PurchaseOrderID: po.ID,
 		UserID:          userID.(uint),
-		Amount:          request.Amount,
+		Amount:          amount,
 		PaymentMethod:   request.PaymentMethod,
 		PaymentType:     "payment",
+		ExchangeRate:    paymentRate,
+		AmountBase:      amountBase,
+		FXGainLoss:      fxGainLoss,
 		Notes:           request.Notes,
 	}
 
@@ -478,11 +626,17 @@ func RecordPurchasePayment(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, "payment", "purchase_order", po.ID, before, po) {
+		return
+	}
+
 	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":        "Payment recorded successfully",
 		"purchase_order": po,
+		"payment":        payment,
+		"fx_gain_loss":   fxGainLoss,
 	})
 }
 
@@ -543,6 +697,9 @@ func DeletePurchaseOrder(c *gin.Context) {
 	// Reverse stock movements for each item (only if status is not cancelled)
 	if po.PaymentStatus != "cancelled" {
 		for _, item := range po.Items {
+			if item.QuantityReceived == item.QuantityReturned {
+				continue // Nothing of this line is in stock
+			}
 			var product models.Product
 			if err := tx.First(&product, item.ProductID).Error; err != nil {
 				// If product doesn't exist anymore, skip stock restoration but continue with deletion
@@ -553,8 +710,8 @@ func DeletePurchaseOrder(c *gin.Context) {
 			if item.ProductSupplierID != nil {
 				var productSupplier models.ProductSupplier
 				if err := tx.First(&productSupplier, *item.ProductSupplierID).Error; err == nil {
-					// Reduce supplier stock by the received quantity
-					newStock := productSupplier.Stock - item.QuantityReceived
+					// Reduce supplier stock by the received quantity still on hand
+					newStock := productSupplier.Stock - (item.QuantityReceived - item.QuantityReturned)
 					if newStock < 0 {
 						newStock = 0 // Don't allow negative stock
 					}
@@ -573,7 +730,7 @@ func DeletePurchaseOrder(c *gin.Context) {
 				ProductID: product.ID,
 				UserID:    userID.(uint),
 				Type:      "out",
-				Quantity:  item.QuantityReceived,
+				Quantity:  item.QuantityReceived - item.QuantityReturned,
 				Reference: po.PONumber,
 				Notes:     "Purchase order deleted - stock reversed",
 			}
@@ -600,11 +757,140 @@ func DeletePurchaseOrder(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionDelete, "purchase_order", po.ID, po, nil) {
+		return
+	}
+
 	tx.Commit()
 
 	c.JSON(http.StatusOK, gin.H{"message": "Purchase order deleted successfully"})
 }
 
+// ReceivePurchaseOrderRequest represents the request body for receiving a purchase order
+type ReceivePurchaseOrderRequest struct {
+	ReceivedDate string `json:"received_date"` // YYYY-MM-DD, defaults to today
+}
+
+// ReceivePurchaseOrder receives the outstanding quantity of every line of an ordered purchase
+// order into supplier stock
+func ReceivePurchaseOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var req ReceivePurchaseOrderRequest
+	// The body is optional; an empty request receives as of today
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	receivedDate := time.Now()
+	if req.ReceivedDate != "" {
+		if receivedDate, err = time.Parse("2006-01-02", req.ReceivedDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid received date format. Use YYYY-MM-DD"})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	tx := database.DB.Begin()
+
+	var po models.PurchaseOrder
+	if err := tx.Preload("Items").Preload("Supplier").First(&po, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+	if po.PaymentStatus == "cancelled" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cancelled purchase orders can't be received"})
+		return
+	}
+	if receivedDate.Before(po.OrderDate) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Received date can't be before the order date"})
+		return
+	}
+
+	before := po
+	received := 0
+	for _, item := range po.Items {
+		outstanding := item.QuantityOrdered - item.QuantityReceived
+		if outstanding <= 0 {
+			continue
+		}
+
+		// Only receive the line if nobody received it in the meantime
+		result := tx.Model(&models.PurchaseOrderItem{}).
+			Where("id = ? AND quantity_received = ?", item.ID, item.QuantityReceived).
+			Update("quantity_received", item.QuantityOrdered)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to receive purchase order item"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Purchase order was received concurrently, please retry"})
+			return
+		}
+
+		if item.ProductSupplierID != nil {
+			if err := tx.Model(&models.ProductSupplier{}).Where("id = ?", *item.ProductSupplierID).
+				Update("stock", gorm.Expr("stock + ?", outstanding)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
+				return
+			}
+		}
+
+		stockMovement := models.StockMovement{
+			ProductID: item.ProductID,
+			UserID:    userID,
+			Type:      "in",
+			Quantity:  outstanding,
+			Reference: po.PONumber,
+			Notes:     fmt.Sprintf("Purchase Order %s - %s", po.PONumber, po.Supplier.Name),
+		}
+		if err := tx.Create(&stockMovement).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock movement record"})
+			return
+		}
+		received++
+	}
+	if received == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Purchase order has already been received"})
+		return
+	}
+
+	if err := tx.Model(&po).Update("received_date", receivedDate).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order"})
+		return
+	}
+
+	var after models.PurchaseOrder
+	tx.Preload("Items").First(&after, po.ID)
+	if !recordAudit(c, tx, "receive", "purchase_order", po.ID, before, after) {
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to receive purchase order"})
+		return
+	}
+
+	database.DB.Preload("Supplier").Preload("User").Preload("Items.Product").First(&after, po.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase order received", "purchase_order": after})
+}
+
 // GetPurchasePaymentHistory returns payment history for a specific purchase order
 func GetPurchasePaymentHistory(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -633,9 +919,10 @@ func GetPurchasePaymentHistory(c *gin.Context) {
 	}
 
 	// Calculate summary
-	var totalPaid float64
+	totalPaid, fxGainLoss := decimal.Zero, decimal.Zero
 	for _, payment := range payments {
-		totalPaid += payment.Amount
+		totalPaid = totalPaid.Add(payment.Amount)
+		fxGainLoss = fxGainLoss.Add(payment.FXGainLoss)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -644,6 +931,9 @@ func GetPurchasePaymentHistory(c *gin.Context) {
 			"po_number":      po.PONumber,
 			"supplier":       po.Supplier,
 			"total":          po.Total,
+			"currency":       po.Currency,
+			"exchange_rate":  po.ExchangeRate,
+			"total_base":     po.TotalBase,
 			"amount_paid":    po.AmountPaid,
 			"amount_due":     po.AmountDue,
 			"payment_status": po.PaymentStatus,
@@ -655,18 +945,19 @@ func GetPurchasePaymentHistory(c *gin.Context) {
 			"total_payments": len(payments),
 			"total_paid":     totalPaid,
 			"remaining_due":  po.AmountDue,
+			"fx_gain_loss":   fxGainLoss, // Realised in the base currency
 		},
 	})
 }
 
 // UpdatePurchaseOrderRequest represents the request body for updating a purchase order
 type UpdatePurchaseOrderRequest struct {
-	SupplierID    uint    `json:"supplier_id"`
-	PaymentMethod string  `json:"payment_method"`
-	PaymentDays   int     `json:"payment_days"` // Number of days for payment due
-	Notes         string  `json:"notes"`
-	Status        string  `json:"status"`
-	DownPayment   float64 `json:"down_payment"`
+	SupplierID    uint            `json:"supplier_id"`
+	PaymentMethod string          `json:"payment_method"`
+	PaymentDays   int             `json:"payment_days"` // Number of days for payment due
+	Notes         string          `json:"notes"`
+	Status        string          `json:"status"`
+	DownPayment   decimal.Decimal `json:"down_payment"`
 }
 
 // GetPurchaseOrdersSummary returns summary statistics for purchase orders
@@ -704,33 +995,35 @@ func GetPurchaseOrdersSummary(c *gin.Context) {
 		Where("created_at BETWEEN ? AND ?", start, end).
 		Count(&totalOrders)
 
-	// Calculate total amount
-	var totalAmount float64
+	// Calculate total amount, in the base currency
+	var totalAmount decimal.Decimal
 	db.Model(&models.PurchaseOrder{}).
 		Where("created_at BETWEEN ? AND ?", start, end).
-		Select("COALESCE(SUM(total), 0)").
+		Select("COALESCE(SUM(total_base), 0)").
 		Scan(&totalAmount)
 
 	// Calculate pending payments (credit orders with amount due > 0)
-	var pendingAmount float64
+	var pendingAmount decimal.Decimal
 	db.Model(&models.PurchaseOrder{}).
 		Where("created_at BETWEEN ? AND ? AND payment_method = ? AND amount_due > ?", start, end, "credit", 0).
-		Select("COALESCE(SUM(amount_due), 0)").
+		Select("COALESCE(SUM(amount_due * exchange_rate), 0)").
 		Scan(&pendingAmount)
 
 	// Calculate overdue payments (credit orders past due date with amount due > 0)
-	var overdueAmount float64
+	var overdueAmount decimal.Decimal
 	db.Model(&models.PurchaseOrder{}).
 		Where("created_at BETWEEN ? AND ? AND payment_method = ? AND amount_due > ? AND due_date < ?",
 			start, end, "credit", 0, time.Now()).
-		Select("COALESCE(SUM(amount_due), 0)").
+		Select("COALESCE(SUM(amount_due * exchange_rate), 0)").
 		Scan(&overdueAmount)
 
+	base := baseCurrency()
 	c.JSON(http.StatusOK, gin.H{
 		"total_orders":   totalOrders,
 		"total_amount":   totalAmount,
-		"pending_amount": pendingAmount,
-		"overdue_amount": overdueAmount,
+		"pending_amount": money.Round(base, pendingAmount),
+		"overdue_amount": money.Round(base, overdueAmount),
+		"base_currency":  base,
 		"period": gin.H{
 			"start_date": startDate,
 			"end_date":   endDate,
@@ -770,6 +1063,8 @@ func UpdatePurchaseOrder(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(po)
+
 	// Store original values for comparison
 	originalDownPayment := po.DownPayment
 	originalPaymentDays := po.PaymentDays
@@ -804,26 +1099,28 @@ func UpdatePurchaseOrder(c *gin.Context) {
 	}
 
 	// Handle downpayment changes for credit orders
-	if req.PaymentMethod == "credit" && req.DownPayment != originalDownPayment {
+	downPayment := money.Round(po.Currency, req.DownPayment)
+	if req.PaymentMethod == "credit" && !downPayment.Equal(originalDownPayment) {
 		// Validate downpayment doesn't exceed total
-		if req.DownPayment > po.Total {
+		if downPayment.GreaterThan(po.Total) {
 			tx.Rollback()
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Downpayment cannot exceed total amount"})
 			return
 		}
 
 		// Calculate the difference
-		paymentDifference := req.DownPayment - originalDownPayment
+		paymentDifference := downPayment.Sub(originalDownPayment)
 
 		// Update amounts
-		po.DownPayment = req.DownPayment
-		po.AmountPaid += paymentDifference
-		po.AmountDue -= paymentDifference
+		po.DownPayment = downPayment
+		po.AmountPaid = po.AmountPaid.Add(paymentDifference)
+		po.AmountDue = po.AmountDue.Sub(paymentDifference)
 
 		// Record payment history for the adjustment
-		if paymentDifference != 0 {
+		if !paymentDifference.IsZero() {
 			paymentType := "adjustment"
-			notes := fmt.Sprintf("Downpayment adjusted from Rp%.2f to Rp%.2f", originalDownPayment, req.DownPayment)
+			notes := fmt.Sprintf("Downpayment adjusted from Rp%s to Rp%s",
+				money.Format(po.Currency, originalDownPayment), money.Format(po.Currency, downPayment))
 
 			payment := models.PurchasePayment{
 				PurchaseOrderID: po.ID,
@@ -833,6 +1130,7 @@ func UpdatePurchaseOrder(c *gin.Context) {
 				PaymentType:     paymentType,
 				Notes:           notes,
 			}
+			atOrderRate(&payment, &po)
 
 			if err := tx.Create(&payment).Error; err != nil {
 				tx.Rollback()
@@ -842,11 +1140,11 @@ func UpdatePurchaseOrder(c *gin.Context) {
 		}
 
 		// Update payment status if needed
-		if po.AmountDue <= 0.01 {
+		if !po.AmountDue.IsPositive() {
 			po.PaymentStatus = "paid"
 			now := time.Now()
 			po.PaidDate = &now
-			po.AmountDue = 0
+			po.AmountDue = decimal.Zero
 		} else {
 			po.PaymentStatus = "pending"
 		}
@@ -867,6 +1165,10 @@ func UpdatePurchaseOrder(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionUpdate, "purchase_order", po.ID, before, po) {
+		return
+	}
+
 	// Commit transaction
 	tx.Commit()
 
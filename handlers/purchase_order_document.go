@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/mailer"
+	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendPurchaseOrderRequest represents the request body for emailing a purchase order
+type SendPurchaseOrderRequest struct {
+	Email   string `json:"email"`   // Overrides the supplier's email address
+	Message string `json:"message"` // Replaces the default email body
+}
+
+// loadPurchaseOrderDocument loads a purchase order with everything needed to print it
+func loadPurchaseOrderDocument(c *gin.Context) (*models.PurchaseOrder, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return nil, false
+	}
+
+	var po models.PurchaseOrder
+	if err := database.DB.Preload("User").Preload("Supplier").Preload("Items.Product").First(&po, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return nil, false
+	}
+
+	return &po, true
+}
+
+// GetPurchaseOrderPDF renders a purchase order as an A4 document
+func GetPurchaseOrderPDF(c *gin.Context) {
+	po, ok := loadPurchaseOrderDocument(c)
+	if !ok {
+		return
+	}
+
+	data, err := renderPurchaseOrder(loadDocumentProfile(), po, middleware.HasPermission(c, permissions.ProductsCost))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate purchase order document"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("purchase_order_%s.pdf", po.PONumber), data)
+}
+
+// SendPurchaseOrder emails the purchase order document to the supplier and marks it as sent. Only
+// draft or sent orders that are neither cancelled nor received can be sent. The supplier's copy
+// shows unit costs, so sending needs permission to view costs.
+func SendPurchaseOrder(c *gin.Context) {
+	if !middleware.HasPermission(c, permissions.ProductsCost) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You need permission to view costs to send a purchase order"})
+		return
+	}
+
+	var req SendPurchaseOrderRequest
+	// The body is optional; an empty request sends to the supplier's address
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	po, ok := loadPurchaseOrderDocument(c)
+	if !ok {
+		return
+	}
+	if err := purchaseOrderSendable(po); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Can't send: %v", err)})
+		return
+	}
+
+	recipient := strings.TrimSpace(req.Email)
+	if recipient == "" {
+		recipient = strings.TrimSpace(po.Supplier.Email)
+	}
+	if recipient == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Supplier has no email address"})
+		return
+	}
+	if _, err := mail.ParseAddress(recipient); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	profile := loadDocumentProfile()
+	data, err := renderPurchaseOrder(profile, po, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate purchase order document"})
+		return
+	}
+
+	subject := fmt.Sprintf("Purchase Order %s from %s", po.PONumber, profile.CompanyName)
+	body := req.Message
+	if body == "" {
+		body = purchaseOrderEmailBody(profile, po)
+	}
+
+	before := audit.Snapshot(gin.H{"status": po.Status, "sent_at": po.SentAt})
+	previousStatus, previousSentAt := po.Status, po.SentAt
+
+	// Stored to the microsecond, so the failure path below can tell whether sent_at is still ours
+	now := time.Now().Truncate(time.Microsecond)
+	po.Status = "sent"
+	po.SentAt = &now
+
+	// Mark the order sent and commit before emailing, so it can't be cancelled or received while the
+	// email goes out and no transaction is held open waiting on the mail server
+	tx := database.DB.Begin()
+	result := tx.Model(po).Where("status IN ? AND payment_status <> ? AND received_date IS NULL", []string{"draft", "sent"}, "cancelled").
+		Select("status", "sent_at").Updates(po)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order status"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Purchase order was changed by someone else"})
+		return
+	}
+	if !recordAudit(c, tx, "send", "purchase_order", po.ID, before, gin.H{"status": po.Status, "sent_at": po.SentAt, "recipient": recipient}) {
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order status"})
+		return
+	}
+
+	sendErr := mailer.Send(mailer.Message{
+		To:      []string{recipient},
+		ReplyTo: profile.CompanyEmail,
+		Subject: subject,
+		Body:    body,
+		Attachments: []mailer.Attachment{{
+			Filename:    fmt.Sprintf("purchase_order_%s.pdf", po.PONumber),
+			ContentType: "application/pdf",
+			Data:        data,
+		}},
+	})
+
+	sendLog := models.PurchaseOrderSendLog{
+		PurchaseOrderID: po.ID,
+		UserID:          c.GetUint("user_id"),
+		Recipient:       recipient,
+		Subject:         subject,
+		Status:          "sent",
+	}
+	if sendErr != nil {
+		// Nothing was sent, so the order gets its status back unless it has changed since, and the
+		// failed attempt is logged
+		sendLog.Status = "failed"
+		sendLog.Error = sendErr.Error()
+
+		tx := database.DB.Begin()
+		result := tx.Model(&models.PurchaseOrder{}).Where("id = ? AND status = ? AND sent_at = ?", po.ID, "sent", now).
+			Updates(map[string]interface{}{"status": previousStatus, "sent_at": previousSentAt})
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Purchase order could not be sent and its status could not be restored"})
+			return
+		}
+		if result.RowsAffected > 0 {
+			if !recordAudit(c, tx, "send_failed", "purchase_order", po.ID, gin.H{"status": po.Status, "sent_at": po.SentAt},
+				gin.H{"status": previousStatus, "sent_at": previousSentAt, "error": sendLog.Error}) {
+				return
+			}
+			po.Status, po.SentAt = previousStatus, previousSentAt
+		}
+		if err := tx.Create(&sendLog).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record send log"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record send log"})
+			return
+		}
+
+		if errors.Is(sendErr, mailer.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured on this server"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to send purchase order: %v", sendErr)})
+		return
+	}
+
+	if err := database.DB.Create(&sendLog).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Purchase order was emailed but its send log could not be recorded"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Purchase order sent to supplier",
+		"purchase_order": po,
+		"send_log":       sendLog,
+	})
+}
+
+// purchaseOrderSendable reports why a purchase order can't be sent to its supplier, if it can't
+func purchaseOrderSendable(po *models.PurchaseOrder) error {
+	switch {
+	case po.PaymentStatus == "cancelled":
+		return fmt.Errorf("purchase order is cancelled")
+	case po.ReceivedDate != nil:
+		return fmt.Errorf("purchase order has already been received")
+	case po.Status != "draft" && po.Status != "sent":
+		return fmt.Errorf("purchase order is %s", po.Status)
+	}
+	return nil
+}
+
+// GetPurchaseOrderSendLogs returns the email history of a purchase order
+func GetPurchaseOrderSendLogs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var logs []models.PurchaseOrderSendLog
+	if err := database.DB.Preload("User").Where("purchase_order_id = ?", id).Order("created_at DESC").Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch send history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"send_logs": logs})
+}
+
+// purchaseOrderEmailBody is the default plain-text email accompanying the PO document
+func purchaseOrderEmailBody(profile models.CompanyProfile, po *models.PurchaseOrder) string {
+	greeting := "Hello"
+	if po.Supplier.ContactPerson != "" {
+		greeting = "Dear " + po.Supplier.ContactPerson
+	}
+
+	lines := []string{
+		greeting + ",",
+		"",
+		fmt.Sprintf("Please find attached purchase order %s dated %s for a total of %s.",
+			po.PONumber, po.OrderDate.Format("02 Jan 2006"), formatMoney(po.Currency, po.Total)),
+		"Kindly confirm receipt and the expected delivery date.",
+		"",
+		"Regards,",
+		profile.CompanyName,
+	}
+	if contact := joinNonEmpty(" | ", profile.CompanyPhone, profile.CompanyEmail); contact != "" {
+		lines = append(lines, contact)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderPurchaseOrder builds the A4 purchase order document. Unit costs and line amounts are only
+// printed with showCosts.
+func renderPurchaseOrder(profile models.CompanyProfile, po *models.PurchaseOrder, showCosts bool) ([]byte, error) {
+	currency := po.Currency
+	doc := newPDFDocument(profile, "PURCHASE ORDER", po.PONumber, po.OrderDate)
+
+	terms := po.PaymentMethod
+	if po.PaymentDays > 0 && po.PaymentMethod != "cash" {
+		terms = fmt.Sprintf("%s (%d days)", po.PaymentMethod, po.PaymentDays)
+	}
+	dueDate := ""
+	if po.DueDate != nil {
+		dueDate = po.DueDate.Format("02 Jan 2006")
+	}
+
+	doc.keyValues([][2]string{
+		{"Supplier:", po.Supplier.Name},
+		{"Attention:", po.Supplier.ContactPerson},
+		{"Address:", po.Supplier.Address},
+		{"Contact:", joinNonEmpty(" | ", po.Supplier.Phone, po.Supplier.Email)},
+		{"Order Date:", po.OrderDate.Format("02 Jan 2006")},
+		{"Ordered By:", po.User.Name},
+		{"Payment Terms:", terms},
+		{"Payment Due:", dueDate},
+	})
+
+	rows := make([][]string, 0, len(po.Items))
+	for i, item := range po.Items {
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			item.Product.SKU,
+			item.Product.Name,
+			strconv.Itoa(item.QuantityOrdered),
+			formatMoney(currency, item.UnitCost),
+			formatMoney(currency, item.Total),
+		})
+	}
+	doc.lineItems(rows, showCosts)
+
+	var totals [][2]string
+	if po.DownPayment.IsPositive() {
+		totals = append(totals, [2]string{"Down Payment", formatMoney(currency, po.DownPayment)})
+	}
+	totals = append(totals, [2]string{"Total", formatMoney(currency, po.Total)})
+	doc.totals(totals)
+
+	doc.section("Notes", po.Notes)
+
+	return doc.bytes()
+}
@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// PurchaseReturnRequest represents the request body for returning goods to a supplier
+type PurchaseReturnRequest struct {
+	PurchaseOrderID uint                        `json:"purchase_order_id" binding:"required"`
+	Reason          string                      `json:"reason"`
+	HoldCredit      bool                        `json:"hold_credit"` // Keep the whole credit for future orders instead of reducing this order's amount due
+	Items           []PurchaseReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// PurchaseReturnItemRequest is the quantity of one purchase order line to send back
+type PurchaseReturnItemRequest struct {
+	PurchaseOrderItemID uint `json:"purchase_order_item_id" binding:"required"`
+	Quantity            int  `json:"quantity" binding:"required,min=1"`
+}
+
+// ApplySupplierCreditRequest represents the request body for settling a purchase order with supplier credit
+type ApplySupplierCreditRequest struct {
+	CreditNoteID uint             `json:"credit_note_id" binding:"required"`
+	Amount       *decimal.Decimal `json:"amount"` // Defaults to as much as the credit and the amount due allow
+}
+
+// loadPurchaseReturn loads the purchase return in the :id parameter with its lines and credit note
+func loadPurchaseReturn(c *gin.Context) (*models.PurchaseReturn, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase return ID"})
+		return nil, false
+	}
+
+	var purchaseReturn models.PurchaseReturn
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("Supplier").Preload("User").Preload("PurchaseOrder").
+		Preload("CreditNote.Applications", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&purchaseReturn, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase return not found"})
+		return nil, false
+	}
+	return &purchaseReturn, true
+}
+
+// updatePurchasePaymentStatus derives the payment status of a purchase order from what is still due
+func updatePurchasePaymentStatus(po *models.PurchaseOrder) {
+	if !po.AmountDue.IsPositive() {
+		po.PaymentStatus = "paid"
+		now := time.Now()
+		po.PaidDate = &now
+		po.AmountDue = decimal.Zero
+	} else if po.DueDate != nil && time.Now().After(*po.DueDate) {
+		po.PaymentStatus = "overdue"
+	} else {
+		po.PaymentStatus = "pending"
+	}
+}
+
+// applySupplierCredit settles amount of a purchase order from a credit note, recording it in the
+// order's payment history. The note and order are updated in place with SQL expressions, so credit
+// or payments recorded by concurrent requests aren't lost. On failure it rolls tx back, responds and
+// returns false.
+func applySupplierCredit(c *gin.Context, tx *gorm.DB, note *models.SupplierCreditNote, po *models.PurchaseOrder, amount decimal.Decimal) bool {
+	// Another request may have used the same credit in the meantime
+	result := tx.Model(&models.SupplierCreditNote{}).
+		Where("id = ? AND balance >= ?", note.ID, amount).
+		Updates(map[string]interface{}{
+			"balance": gorm.Expr("balance - ?", amount),
+			"status":  gorm.Expr("CASE WHEN balance - ? > 0 THEN 'open' ELSE 'applied' END", amount),
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credit note"})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Credit note was changed by someone else"})
+		return false
+	}
+
+	// Or paid the order in the meantime
+	now := time.Now()
+	result = tx.Model(&models.PurchaseOrder{}).
+		Where("id = ? AND amount_due >= ?", po.ID, amount).
+		Updates(map[string]interface{}{
+			"amount_paid": gorm.Expr("amount_paid + ?", amount),
+			"amount_due":  gorm.Expr("amount_due - ?", amount),
+			"payment_status": gorm.Expr("CASE WHEN amount_due - ? <= 0 THEN 'paid' WHEN due_date IS NOT NULL AND due_date < ? THEN 'overdue' ELSE 'pending' END",
+				amount, now),
+			"paid_date": gorm.Expr("CASE WHEN amount_due - ? <= 0 THEN ? ELSE paid_date END", amount, now),
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order payment"})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Purchase order was changed by someone else"})
+		return false
+	}
+
+	// Read back what the updates left
+	var updatedNote models.SupplierCreditNote
+	var updatedPO models.PurchaseOrder
+	if err := tx.Select("balance", "status").First(&updatedNote, note.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credit note"})
+		return false
+	}
+	if err := tx.Select("amount_paid", "amount_due", "payment_status", "paid_date").First(&updatedPO, po.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order payment"})
+		return false
+	}
+	note.Balance, note.Status = updatedNote.Balance, updatedNote.Status
+	po.AmountPaid, po.AmountDue = updatedPO.AmountPaid, updatedPO.AmountDue
+	po.PaymentStatus, po.PaidDate = updatedPO.PaymentStatus, updatedPO.PaidDate
+
+	payment := models.PurchasePayment{
+		PurchaseOrderID: po.ID,
+		UserID:          c.GetUint("user_id"),
+		Amount:          amount,
+		PaymentMethod:   "credit_note",
+		PaymentType:     "credit_note",
+		Notes:           fmt.Sprintf("Credit note %s", note.CreditNumber),
+	}
+	atOrderRate(&payment, po)
+	if err := tx.Create(&payment).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment history"})
+		return false
+	}
+
+	application := models.SupplierCreditApplication{
+		CreditNoteID:    note.ID,
+		PurchaseOrderID: po.ID,
+		UserID:          c.GetUint("user_id"),
+		Amount:          amount,
+	}
+	if err := tx.Create(&application).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record credit application"})
+		return false
+	}
+	note.Applications = append(note.Applications, application)
+	return true
+}
+
+// GetPurchaseReturns lists purchase returns with optional supplier, purchase order and date filters
+func GetPurchaseReturns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.PurchaseReturn{})
+
+	if supplierID := c.Query("supplier_id"); supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	if poID := c.Query("purchase_order_id"); poID != "" {
+		query = query.Where("purchase_order_id = ?", poID)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("created_at <= ?", endDate+" 23:59:59")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var returns []models.PurchaseReturn
+	if err := query.Preload("Supplier").Preload("User").Preload("Items.Product").Preload("CreditNote").
+		Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"purchase_returns": returns,
+		"total":            total,
+		"page":             page,
+		"limit":            limit,
+	})
+}
+
+// GetPurchaseReturn returns a purchase return with its lines and credit note
+func GetPurchaseReturn(c *gin.Context) {
+	purchaseReturn, ok := loadPurchaseReturn(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, purchaseReturn)
+}
+
+// CreatePurchaseReturn sends received goods back to the supplier. The stock comes off the
+// supplier's product stock and a credit note for the returned value first reduces what is still
+// due on the purchase order; whatever is left is held as credit for future orders.
+func CreatePurchaseReturn(c *gin.Context) {
+	var req PurchaseReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	returning := make(map[uint]int)
+	for _, line := range req.Items {
+		returning[line.PurchaseOrderItemID] += line.Quantity
+	}
+
+	tx := database.DB.Begin()
+
+	var po models.PurchaseOrder
+	if err := tx.Preload("Supplier").Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").First(&po, req.PurchaseOrderID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+
+	onOrder := make(map[uint]bool)
+	for _, item := range po.Items {
+		onOrder[item.ID] = true
+	}
+	for id := range returning {
+		if !onOrder[id] {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d is not on this purchase order", id)})
+			return
+		}
+	}
+
+	purchaseReturn := models.PurchaseReturn{
+		ReturnNumber:    nextDocumentNumber(tx, &models.PurchaseReturn{}, "return_number", "PR"),
+		PurchaseOrderID: po.ID,
+		SupplierID:      po.SupplierID,
+		UserID:          c.GetUint("user_id"),
+		Reason:          strings.TrimSpace(req.Reason),
+	}
+
+	total := decimal.Zero
+	for _, item := range po.Items {
+		quantity := returning[item.ID]
+		if quantity == 0 {
+			continue
+		}
+		if returnable := item.QuantityReceived - item.QuantityReturned; quantity > returnable {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Only %d of %s can be returned, requested %d", returnable, item.Product.Name, quantity),
+			})
+			return
+		}
+
+		// Goods go back out of the stock they were received into
+		var productSupplier models.ProductSupplier
+		query := tx.Where("product_id = ? AND supplier_id = ?", item.ProductID, po.SupplierID)
+		if item.ProductSupplierID != nil {
+			query = tx.Where("id = ?", *item.ProductSupplierID)
+		}
+		if err := query.First(&productSupplier).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Supplier stock for product %s no longer exists", item.Product.Name)})
+			return
+		}
+
+		available := productSupplier.Stock - reservedStock(tx, item.ProductID, &productSupplier.ID, 0, 0)
+		if available < quantity {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Insufficient stock for product %s to return. Available: %d, Requested: %d", item.Product.Name, available, quantity),
+			})
+			return
+		}
+
+		// Take the stock in the update itself, so concurrent sales can't drive it negative
+		result := tx.Model(&models.ProductSupplier{}).
+			Where("id = ? AND stock - ? >= 0", productSupplier.ID, quantity).
+			Update("stock", gorm.Expr("stock - ?", quantity))
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Supplier stock was changed by someone else"})
+			return
+		}
+
+		result = tx.Model(&models.PurchaseOrderItem{}).
+			Where("id = ? AND quantity_returned + ? <= quantity_received", item.ID, quantity).
+			Update("quantity_returned", gorm.Expr("quantity_returned + ?", quantity))
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order item"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Purchase order was changed by someone else"})
+			return
+		}
+
+		movement := models.StockMovement{
+			ProductID: item.ProductID,
+			UserID:    purchaseReturn.UserID,
+			Type:      "out",
+			Quantity:  quantity,
+			Reference: purchaseReturn.ReturnNumber,
+			Notes:     fmt.Sprintf("Returned to %s - Purchase Order %s", po.Supplier.Name, po.PONumber),
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
+			return
+		}
+
+		line := models.PurchaseReturnItem{
+			PurchaseOrderItemID: item.ID,
+			ProductID:           item.ProductID,
+			ProductSupplierID:   &productSupplier.ID,
+			Quantity:            quantity,
+			UnitCost:            item.UnitCost,
+			Total:               money.LineTotal(po.Currency, quantity, item.UnitCost),
+		}
+		purchaseReturn.Items = append(purchaseReturn.Items, line)
+		total = total.Add(line.Total)
+	}
+	purchaseReturn.Total = total
+
+	if err := tx.Create(&purchaseReturn).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase return"})
+		return
+	}
+
+	note := models.SupplierCreditNote{
+		CreditNumber:     nextDocumentNumber(tx, &models.SupplierCreditNote{}, "credit_number", "CN"),
+		SupplierID:       po.SupplierID,
+		PurchaseReturnID: &purchaseReturn.ID,
+		Amount:           purchaseReturn.Total,
+		Balance:          purchaseReturn.Total,
+		Currency:         po.Currency,
+		Status:           "open",
+		Notes:            fmt.Sprintf("Return %s of purchase order %s", purchaseReturn.ReturnNumber, po.PONumber),
+	}
+	if !note.Balance.IsPositive() {
+		note.Status = "applied"
+	}
+	if err := tx.Create(&note).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credit note"})
+		return
+	}
+
+	if !req.HoldCredit && po.AmountDue.IsPositive() && note.Balance.IsPositive() {
+		before := audit.Snapshot(po)
+		if !applySupplierCredit(c, tx, &note, &po, decimal.Min(note.Balance, po.AmountDue)) {
+			return
+		}
+		if !recordAudit(c, tx, "apply_credit", "purchase_order", po.ID, before, po) {
+			return
+		}
+	}
+	purchaseReturn.CreditNote = &note
+
+	if !recordAudit(c, tx, audit.ActionCreate, "purchase_return", purchaseReturn.ID, nil, purchaseReturn) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Purchase return created successfully", "purchase_return": purchaseReturn})
+}
+
+// GetSupplierCreditNotes lists a supplier's credit notes and the credit still available to apply
+func GetSupplierCreditNotes(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	query := database.DB.Where("supplier_id = ?", supplier.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var notes []models.SupplierCreditNote
+	if err := query.Preload("Applications").Order("created_at DESC").Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credit notes"})
+		return
+	}
+
+	var available decimal.Decimal
+	database.DB.Model(&models.SupplierCreditNote{}).
+		Where("supplier_id = ? AND status = ?", supplier.ID, "open").
+		Select("COALESCE(SUM(balance), 0)").Scan(&available)
+
+	c.JSON(http.StatusOK, gin.H{
+		"supplier_id":      supplier.ID,
+		"credit_notes":     notes,
+		"available_credit": available,
+	})
+}
+
+// ApplySupplierCredit settles what is due on a purchase order from one of the supplier's credit notes
+func ApplySupplierCredit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var req ApplySupplierCreditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	var po models.PurchaseOrder
+	if err := tx.First(&po, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+
+	var note models.SupplierCreditNote
+	if err := tx.First(&note, req.CreditNoteID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credit note not found"})
+		return
+	}
+	if note.SupplierID != po.SupplierID {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Credit note belongs to a different supplier"})
+		return
+	}
+	if note.Currency != po.Currency {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Credit note is in %s but the purchase order is in %s", note.Currency, po.Currency)})
+		return
+	}
+	if note.Status != "open" || !note.Balance.IsPositive() {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Credit note has no balance left"})
+		return
+	}
+	if !po.AmountDue.IsPositive() {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Purchase order has nothing left to pay"})
+		return
+	}
+
+	amount := decimal.Min(note.Balance, po.AmountDue)
+	if req.Amount != nil {
+		if !req.Amount.IsPositive() {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than 0"})
+			return
+		}
+		amount = money.Round(po.Currency, *req.Amount)
+		if amount.GreaterThan(note.Balance) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount exceeds the credit note balance"})
+			return
+		}
+		if amount.GreaterThan(po.AmountDue) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount exceeds amount due"})
+			return
+		}
+	}
+
+	before := audit.Snapshot(po)
+	if !applySupplierCredit(c, tx, &note, &po, amount) {
+		return
+	}
+	if !recordAudit(c, tx, "apply_credit", "purchase_order", po.ID, before, po) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Credit applied successfully",
+		"purchase_order": po,
+		"credit_note":    note,
+	})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCreditNotePDF renders the supplier credit note issued for a purchase return, to send with the goods
+func GetCreditNotePDF(c *gin.Context) {
+	purchaseReturn, ok := loadPurchaseReturn(c)
+	if !ok {
+		return
+	}
+	if purchaseReturn.CreditNote == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credit note not found"})
+		return
+	}
+
+	data, err := renderCreditNote(loadDocumentProfile(), purchaseReturn, middleware.HasPermission(c, permissions.ProductsCost))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate credit note"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("credit_note_%s.pdf", purchaseReturn.CreditNote.CreditNumber), data)
+}
+
+// renderCreditNote builds the A4 credit note listing the returned goods, at their purchase cost with showCosts
+func renderCreditNote(profile models.CompanyProfile, purchaseReturn *models.PurchaseReturn, showCosts bool) ([]byte, error) {
+	note := purchaseReturn.CreditNote
+	currency := note.Currency
+	doc := newPDFDocument(profile, "CREDIT NOTE", note.CreditNumber, note.CreatedAt)
+
+	poNumber := ""
+	if purchaseReturn.PurchaseOrder != nil {
+		poNumber = purchaseReturn.PurchaseOrder.PONumber
+	}
+	doc.keyValues([][2]string{
+		{"Supplier:", purchaseReturn.Supplier.Name},
+		{"Contact:", joinNonEmpty(" | ", purchaseReturn.Supplier.Phone, purchaseReturn.Supplier.Email)},
+		{"Return No:", purchaseReturn.ReturnNumber},
+		{"PO No:", poNumber},
+		{"Date:", purchaseReturn.CreatedAt.Format("02 Jan 2006")},
+		{"Returned By:", purchaseReturn.User.Name},
+	})
+
+	rows := make([][]string, 0, len(purchaseReturn.Items))
+	for i, item := range purchaseReturn.Items {
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			item.Product.SKU,
+			item.Product.Name,
+			strconv.Itoa(item.Quantity),
+			formatMoney(currency, item.UnitCost),
+			formatMoney(currency, item.Total),
+		})
+	}
+	doc.lineItems(rows, showCosts)
+
+	doc.totals([][2]string{
+		{"Credit", formatMoney(currency, note.Amount)},
+		{"Applied", formatMoney(currency, note.Amount.Sub(note.Balance))},
+		{"Balance", formatMoney(currency, note.Balance)},
+	})
+
+	doc.section("Reason", purchaseReturn.Reason)
+
+	return doc.bytes()
+}
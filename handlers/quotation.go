@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// defaultQuotationValidDays is how long a quotation holds when neither the request nor
+// QUOTATION_VALID_DAYS says otherwise
+const defaultQuotationValidDays = 30
+
+// QuotationRequest represents the request body for creating or updating a quotation
+type QuotationRequest struct {
+	CustomerName  string                 `json:"customer_name" binding:"required"`
+	CustomerEmail string                 `json:"customer_email" binding:"omitempty,email"`
+	CustomerPhone string                 `json:"customer_phone"`
+	ValidUntil    string                 `json:"valid_until"` // YYYY-MM-DD; defaults to QUOTATION_VALID_DAYS from today
+	Discount      decimal.Decimal        `json:"discount"`
+	Tax           decimal.Decimal        `json:"tax"`
+	Notes         string                 `json:"notes"`
+	Items         []QuotationItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// QuotationItemRequest represents a quoted line
+type QuotationItemRequest struct {
+	ProductID  uint             `json:"product_id" binding:"required"`
+	SupplierID *uint            `json:"supplier_id"` // Optional supplier selection
+	Quantity   int              `json:"quantity" binding:"required,min=1"`
+	Price      *decimal.Decimal `json:"price"` // Defaults to the list price
+}
+
+// ConvertQuotationRequest is how the customer pays for an accepted quotation; see SaleRequest
+type ConvertQuotationRequest struct {
+	PaymentMethod    string                 `json:"payment_method"`
+	PaymentDays      int                    `json:"payment_days"`
+	DownPayment      decimal.Decimal        `json:"down_payment"`
+	Tenders          []TenderRequest        `json:"tenders" binding:"omitempty,dive"`
+	OverrideApproval *PriceOverrideApproval `json:"override_approval"`
+}
+
+// quotationValidDays returns how many days a new quotation holds by default
+func quotationValidDays() int {
+	if value, err := strconv.Atoi(os.Getenv("QUOTATION_VALID_DAYS")); err == nil && value > 0 {
+		return value
+	}
+	return defaultQuotationValidDays
+}
+
+// startOfToday is midnight today in local time; quotations are valid through their last day
+func startOfToday() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// expireQuotations marks draft and sent quotations past their validity as expired
+func expireQuotations(db *gorm.DB) {
+	db.Model(&models.Quotation{}).
+		Where("status IN ? AND valid_until < ?", []string{"draft", "sent"}, startOfToday()).
+		Update("status", "expired")
+}
+
+// generateQuoteNumber numbers quotations per day, e.g. QT-20240131-0007
+func generateQuoteNumber(db *gorm.DB) string {
+	return nextDocumentNumber(db, &models.Quotation{}, "quote_number", "QT")
+}
+
+// nextDocumentNumber numbers documents per day as CODE-YYYYMMDD-NNNN, continuing from the highest
+// number of the day stored in column
+func nextDocumentNumber(db *gorm.DB, model interface{}, column, code string) string {
+	prefix := code + "-" + startOfToday().Format("20060102") + "-"
+
+	var numbers []string
+	next := 1
+	err := db.Model(model).Where(column+" LIKE ?", prefix+"%").Order(column+" DESC").Limit(1).Pluck(column, &numbers).Error
+	if err == nil && len(numbers) > 0 {
+		if n, err := strconv.Atoi(strings.TrimPrefix(numbers[0], prefix)); err == nil {
+			next = n + 1
+		}
+	}
+	return fmt.Sprintf("%s%04d", prefix, next)
+}
+
+// buildQuotation fills in a quotation's validity, lines and totals from a request. Lines without a
+// price are quoted at the list price, the same price a sale would use.
+func buildQuotation(db *gorm.DB, quotation *models.Quotation, req QuotationRequest) (int, error) {
+	validUntil := startOfToday().AddDate(0, 0, quotationValidDays())
+	if req.ValidUntil != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", req.ValidUntil, time.Local)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid valid_until, expected YYYY-MM-DD")
+		}
+		if parsed.Before(startOfToday()) {
+			return http.StatusBadRequest, fmt.Errorf("valid_until can't be in the past")
+		}
+		validUntil = parsed
+	}
+
+	if req.Discount.IsNegative() || req.Tax.IsNegative() {
+		return http.StatusBadRequest, fmt.Errorf("discount and tax can't be negative")
+	}
+
+	quotation.CustomerName = strings.TrimSpace(req.CustomerName)
+	quotation.CustomerEmail = strings.TrimSpace(req.CustomerEmail)
+	quotation.CustomerPhone = strings.TrimSpace(req.CustomerPhone)
+	quotation.ValidUntil = validUntil
+	currency := baseCurrency()
+	quotation.Discount = money.Round(currency, req.Discount)
+	quotation.Tax = money.Round(currency, req.Tax)
+	quotation.Notes = req.Notes
+	quotation.Items = nil
+
+	subtotal := decimal.Zero
+	for _, itemReq := range req.Items {
+		var product models.Product
+		if err := db.Preload("Suppliers").First(&product, itemReq.ProductID).Error; err != nil {
+			return http.StatusNotFound, fmt.Errorf("product with ID %d not found", itemReq.ProductID)
+		}
+
+		price := product.GetLowestPrice()
+		if itemReq.SupplierID != nil {
+			productSupplier := findProductSupplier(db, product.ID, *itemReq.SupplierID)
+			if productSupplier == nil {
+				return http.StatusBadRequest, fmt.Errorf("selected supplier ID %d not found or inactive for product %s", *itemReq.SupplierID, product.Name)
+			}
+			price = productSupplier.Price
+		}
+		if itemReq.Price != nil {
+			if itemReq.Price.IsNegative() {
+				return http.StatusBadRequest, fmt.Errorf("price of %s can't be negative", product.Name)
+			}
+			price = *itemReq.Price
+		}
+		price = money.RoundUnit(price)
+
+		item := models.QuotationItem{
+			ProductID:  product.ID,
+			SupplierID: itemReq.SupplierID,
+			Quantity:   itemReq.Quantity,
+			Price:      price,
+			Total:      money.LineTotal(currency, itemReq.Quantity, price),
+		}
+		quotation.Items = append(quotation.Items, item)
+		subtotal = subtotal.Add(item.Total)
+	}
+
+	quotation.Subtotal = subtotal
+	quotation.Total = documentTotal(subtotal, quotation.Discount, quotation.Tax)
+	if quotation.Total.IsNegative() {
+		return http.StatusBadRequest, fmt.Errorf("discount can't exceed the quotation subtotal")
+	}
+	return 0, nil
+}
+
+// loadQuotation loads the quotation in the :id parameter with its lines
+func loadQuotation(c *gin.Context) (*models.Quotation, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quotation ID"})
+		return nil, false
+	}
+
+	expireQuotations(database.DB)
+
+	var quotation models.Quotation
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("User").Preload("Sale").First(&quotation, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return nil, false
+	}
+	return &quotation, true
+}
+
+// GetQuotations lists quotations with optional status, customer and date filters
+func GetQuotations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	expireQuotations(database.DB)
+
+	query := database.DB.Model(&models.Quotation{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if customer := c.Query("customer"); customer != "" {
+		query = query.Where("customer_name ILIKE ?", "%"+customer+"%")
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("created_at <= ?", endDate+" 23:59:59")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var quotations []models.Quotation
+	if err := query.Preload("User").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&quotations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quotations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quotations": quotations,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetQuotation returns a quotation with its lines and, once converted, its sale
+func GetQuotation(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, quotation)
+}
+
+// CreateQuotation creates a draft quotation
+func CreateQuotation(c *gin.Context) {
+	var req QuotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quotation := models.Quotation{
+		UserID: c.GetUint("user_id"),
+		Status: "draft",
+	}
+	if status, err := buildQuotation(database.DB, &quotation, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	quotation.QuoteNumber = generateQuoteNumber(tx)
+	if err := tx.Create(&quotation).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create quotation"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "quotation", quotation.ID, nil, quotation) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Quotation created successfully", "quotation": quotation})
+}
+
+// UpdateQuotation replaces the details and lines of a draft quotation
+func UpdateQuotation(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+
+	var req QuotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if quotation.Status != "draft" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft quotations can be edited"})
+		return
+	}
+
+	before := audit.Snapshot(quotation)
+	if status, err := buildQuotation(database.DB, quotation, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Where("quotation_id = ?", quotation.ID).Delete(&models.QuotationItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quotation items"})
+		return
+	}
+	if err := tx.Omit("Items", "User", "Sale").Save(quotation).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quotation"})
+		return
+	}
+	for i := range quotation.Items {
+		quotation.Items[i].QuotationID = quotation.ID
+	}
+	if err := tx.Create(&quotation.Items).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quotation items"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionUpdate, "quotation", quotation.ID, before, quotation) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quotation updated successfully", "quotation": quotation})
+}
+
+// DeleteQuotation deletes a draft quotation
+func DeleteQuotation(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+	if quotation.Status != "draft" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft quotations can be deleted"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Where("quotation_id = ?", quotation.ID).Delete(&models.QuotationItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quotation"})
+		return
+	}
+	if err := tx.Delete(&models.Quotation{}, quotation.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quotation"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionDelete, "quotation", quotation.ID, quotation, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quotation deleted successfully"})
+}
+
+// setQuotationStatus moves a quotation from one of the from statuses to status, with the extra
+// column updates given; details are only added to the audit entry. The status condition stops two
+// requests acting on the same quotation.
+func setQuotationStatus(c *gin.Context, quotation *models.Quotation, action, status string, from []string, updates map[string]interface{}, details gin.H) bool {
+	before := audit.Snapshot(gin.H{"status": quotation.Status})
+
+	updates["status"] = status
+
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.Quotation{}).Where("id = ? AND status IN ?", quotation.ID, from).Updates(updates)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quotation"})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Quotation was changed by someone else"})
+		return false
+	}
+
+	after := gin.H{}
+	for key, value := range updates {
+		after[key] = value
+	}
+	for key, value := range details {
+		after[key] = value
+	}
+	if !recordAudit(c, tx, action, "quotation", quotation.ID, before, after) {
+		return false
+	}
+
+	tx.Commit()
+
+	quotation.Status = status
+	return true
+}
+
+// AcceptQuotation records that the customer accepted a quotation that is still valid
+func AcceptQuotation(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+	if quotation.Status != "draft" && quotation.Status != "sent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quotation is %s and can't be accepted", quotation.Status)})
+		return
+	}
+
+	now := time.Now()
+	if !setQuotationStatus(c, quotation, "accept", "accepted", []string{"draft", "sent"}, map[string]interface{}{"accepted_at": now}, nil) {
+		return
+	}
+	quotation.AcceptedAt = &now
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quotation accepted", "quotation": quotation})
+}
+
+// RejectQuotation records that the customer declined a quotation
+func RejectQuotation(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+	if quotation.Status != "draft" && quotation.Status != "sent" && quotation.Status != "accepted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quotation is %s and can't be rejected", quotation.Status)})
+		return
+	}
+
+	if !setQuotationStatus(c, quotation, "reject", "rejected", []string{"draft", "sent", "accepted"}, map[string]interface{}{}, nil) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quotation rejected", "quotation": quotation})
+}
+
+// ConvertQuotation turns an accepted quotation into a sale at the quoted prices. The sale goes
+// through the same stock, price override and payment checks as CreateSale.
+func ConvertQuotation(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+
+	var req ConvertQuotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if quotation.Status != "accepted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only accepted quotations can be converted into a sale"})
+		return
+	}
+
+	request := SaleRequest{
+		CustomerName:     quotation.CustomerName,
+		PaymentMethod:    req.PaymentMethod,
+		PaymentDays:      req.PaymentDays,
+		DownPayment:      req.DownPayment,
+		Tenders:          req.Tenders,
+		Discount:         quotation.Discount,
+		Tax:              quotation.Tax,
+		OverrideApproval: req.OverrideApproval,
+	}
+	for _, item := range quotation.Items {
+		price := item.Price
+		request.Items = append(request.Items, SaleItemRequest{
+			ProductID:  item.ProductID,
+			SupplierID: item.SupplierID,
+			Quantity:   item.Quantity,
+			Price:      &price,
+		})
+	}
+	if !validateSaleRequest(c, &request) {
+		return
+	}
+
+	before := audit.Snapshot(gin.H{"status": quotation.Status, "sale_id": quotation.SaleID})
+
+	tx := database.DB.Begin()
+
+	// Claim the quotation first so it can't become two sales
+	result := tx.Model(&models.Quotation{}).Where("id = ? AND status = ?", quotation.ID, "accepted").Update("status", "converted")
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert quotation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Quotation was changed by someone else"})
+		return
+	}
+
+	sale, ok := createSaleTx(c, tx, request, 0)
+	if !ok {
+		return
+	}
+
+	if err := tx.Model(&models.Quotation{}).Where("id = ?", quotation.ID).Update("sale_id", sale.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert quotation"})
+		return
+	}
+
+	if !recordAudit(c, tx, "convert", "quotation", quotation.ID, before, gin.H{"status": "converted", "sale_id": sale.ID}) {
+		return
+	}
+
+	tx.Commit()
+
+	var completeSale models.Sale
+	database.DB.Preload("Items.Product").Preload("Items.OverrideApprovedBy").Preload("User").Preload("Payments").First(&completeSale, sale.ID)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Quotation converted into a sale", "quotation_id": quotation.ID, "sale": completeSale})
+}
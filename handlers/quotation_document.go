@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/mailer"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendQuotationRequest represents the request body for sending a quotation
+type SendQuotationRequest struct {
+	Email   string `json:"email"`   // Overrides the customer's email address
+	Message string `json:"message"` // Replaces the default email body
+	Manual  bool   `json:"manual"`  // Mark as sent without emailing, e.g. when handed over in person
+}
+
+// GetQuotationPDF renders a quotation as an A4 document
+func GetQuotationPDF(c *gin.Context) {
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+
+	data, err := renderQuotation(loadDocumentProfile(), quotation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate quotation document"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("quotation_%s.pdf", quotation.QuoteNumber), data)
+}
+
+// SendQuotation emails the quotation document to the customer, or with manual set only records
+// that it was handed over, and marks it as sent
+func SendQuotation(c *gin.Context) {
+	var req SendQuotationRequest
+	// The body is optional; an empty request emails the customer's address
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	quotation, ok := loadQuotation(c)
+	if !ok {
+		return
+	}
+	if quotation.Status != "draft" && quotation.Status != "sent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quotation is %s and can't be sent", quotation.Status)})
+		return
+	}
+
+	recipient := ""
+	if !req.Manual {
+		recipient = strings.TrimSpace(req.Email)
+		if recipient == "" {
+			recipient = quotation.CustomerEmail
+		}
+		if recipient == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Customer has no email address; send with manual set to mark it as handed over"})
+			return
+		}
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+			return
+		}
+
+		profile := loadDocumentProfile()
+		data, err := renderQuotation(profile, quotation)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate quotation document"})
+			return
+		}
+
+		body := req.Message
+		if body == "" {
+			body = quotationEmailBody(profile, quotation)
+		}
+
+		sendErr := mailer.Send(mailer.Message{
+			To:      []string{recipient},
+			ReplyTo: profile.CompanyEmail,
+			Subject: fmt.Sprintf("Quotation %s from %s", quotation.QuoteNumber, profile.CompanyName),
+			Body:    body,
+			Attachments: []mailer.Attachment{{
+				Filename:    fmt.Sprintf("quotation_%s.pdf", quotation.QuoteNumber),
+				ContentType: "application/pdf",
+				Data:        data,
+			}},
+		})
+		if errors.Is(sendErr, mailer.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured on this server"})
+			return
+		}
+		if sendErr != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to send quotation: %v", sendErr)})
+			return
+		}
+	}
+
+	now := time.Now()
+	if !setQuotationStatus(c, quotation, "send", "sent", []string{"draft", "sent"}, map[string]interface{}{"sent_at": now}, gin.H{"recipient": recipient}) {
+		return
+	}
+	quotation.SentAt = &now
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quotation sent", "quotation": quotation})
+}
+
+// quotationEmailBody is the default plain-text email accompanying the quotation document
+func quotationEmailBody(profile models.CompanyProfile, quotation *models.Quotation) string {
+	lines := []string{
+		"Dear " + quotation.CustomerName + ",",
+		"",
+		fmt.Sprintf("Please find attached quotation %s for a total of %s, valid until %s.",
+			quotation.QuoteNumber, formatMoney(profile.Currency, quotation.Total), quotation.ValidUntil.Format("02 Jan 2006")),
+		"Reply to this email to accept it or if you have any questions.",
+		"",
+		"Regards,",
+		profile.CompanyName,
+	}
+	if contact := joinNonEmpty(" | ", profile.CompanyPhone, profile.CompanyEmail); contact != "" {
+		lines = append(lines, contact)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderQuotation builds the A4 quotation document
+func renderQuotation(profile models.CompanyProfile, quotation *models.Quotation) ([]byte, error) {
+	currency := profile.Currency
+	doc := newPDFDocument(profile, "QUOTATION", quotation.QuoteNumber, quotation.CreatedAt)
+
+	doc.keyValues([][2]string{
+		{"Quote To:", quotation.CustomerName},
+		{"Contact:", joinNonEmpty(" | ", quotation.CustomerPhone, quotation.CustomerEmail)},
+		{"Date:", quotation.CreatedAt.Format("02 Jan 2006")},
+		{"Valid Until:", quotation.ValidUntil.Format("02 Jan 2006")},
+		{"Prepared By:", quotation.User.Name},
+		{"Status:", quotation.Status},
+	})
+
+	rows := make([][]string, 0, len(quotation.Items))
+	for i, item := range quotation.Items {
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			item.Product.SKU,
+			item.Product.Name,
+			strconv.Itoa(item.Quantity),
+			formatMoney(currency, item.Price),
+			formatMoney(currency, item.Total),
+		})
+	}
+	doc.table(
+		[]string{"#", "SKU", "Description", "Qty", "Unit Price", "Amount"},
+		[]float64{10, 28, 62, 15, 32, 33},
+		[]string{"C", "L", "L", "R", "R", "R"},
+		rows,
+	)
+
+	totals := [][2]string{{"Subtotal", formatMoney(currency, quotation.Subtotal)}}
+	if !quotation.Discount.IsZero() {
+		totals = append(totals, [2]string{"Discount", formatMoney(currency, quotation.Discount.Neg())})
+	}
+	if !quotation.Tax.IsZero() {
+		totals = append(totals, [2]string{"Tax", formatMoney(currency, quotation.Tax)})
+	}
+	totals = append(totals, [2]string{"Total", formatMoney(currency, quotation.Total)})
+	doc.totals(totals)
+
+	doc.section("Notes", quotation.Notes)
+	doc.section("Terms", fmt.Sprintf("Prices are valid until %s and subject to stock availability.", quotation.ValidUntil.Format("02 Jan 2006")))
+	doc.section("Payment Details", profile.BankAccount)
+
+	return doc.bytes()
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// ReorderSuggestion is how much of a low-stock product to order and from which supplier
+type ReorderSuggestion struct {
+	ProductID         uint            `json:"product_id"`
+	SKU               string          `json:"sku"`
+	Name              string          `json:"name"`
+	Available         int             `json:"available"`     // Stock of active suppliers less reservations
+	ReorderLevel      int             `json:"reorder_level"` // Sum of the active suppliers' minimum stock
+	Shortfall         int             `json:"shortfall"`
+	ProductSupplierID uint            `json:"product_supplier_id"`
+	SupplierID        uint            `json:"supplier_id"`
+	SupplierName      string          `json:"supplier_name"`
+	Reason            string          `json:"reason"` // preferred, lowest_cost
+	Quantity          int             `json:"quantity"`
+	UnitCost          decimal.Decimal `json:"unit_cost" cost:"true"`
+	EstimatedTotal    decimal.Decimal `json:"estimated_total" cost:"true"`
+	LeadTimeDays      int             `json:"lead_time_days"`
+	ExpectedDate      string          `json:"expected_date"`
+	MinOrderQty       int             `json:"min_order_qty"`
+	OrderMultiple     int             `json:"order_multiple"`
+}
+
+// ReorderSupplierSummary totals the suggestions to order from one supplier
+type ReorderSupplierSummary struct {
+	SupplierID     uint            `json:"supplier_id"`
+	SupplierName   string          `json:"supplier_name"`
+	Items          int             `json:"items"`
+	EstimatedTotal decimal.Decimal `json:"estimated_total" cost:"true"`
+}
+
+// validateOrderQuantity checks an order quantity against the supplier's minimum order quantity and pack size
+func validateOrderQuantity(productSupplier *models.ProductSupplier, quantity int) error {
+	if quantity < productSupplier.MinOrderQty {
+		return fmt.Errorf("the supplier's minimum order quantity is %d", productSupplier.MinOrderQty)
+	}
+	if productSupplier.OrderMultiple > 1 && quantity%productSupplier.OrderMultiple != 0 {
+		return fmt.Errorf("the supplier sells in multiples of %d", productSupplier.OrderMultiple)
+	}
+	return nil
+}
+
+// roundOrderQuantity raises a needed quantity to one the supplier accepts
+func roundOrderQuantity(productSupplier *models.ProductSupplier, needed int) int {
+	quantity := max(needed, productSupplier.MinOrderQty, 1)
+	if multiple := productSupplier.OrderMultiple; multiple > 1 && quantity%multiple != 0 {
+		quantity += multiple - quantity%multiple
+	}
+	return quantity
+}
+
+// reorderSupplier picks the supplier to reorder a product from: the preferred one if it is active,
+// otherwise the cheapest active one, with the shorter lead time breaking ties
+func reorderSupplier(suppliers []models.ProductSupplier) (*models.ProductSupplier, string) {
+	var best *models.ProductSupplier
+	for i := range suppliers {
+		candidate := &suppliers[i]
+		if !candidate.IsActive || (candidate.Supplier.ID != 0 && !candidate.Supplier.IsActive) {
+			continue
+		}
+		if candidate.IsPreferred {
+			return candidate, "preferred"
+		}
+		if best == nil || candidate.Cost.LessThan(best.Cost) ||
+			(candidate.Cost.Equal(best.Cost) && candidate.LeadTimeDays < best.LeadTimeDays) {
+			best = candidate
+		}
+	}
+	return best, "lowest_cost"
+}
+
+// GetReorderSuggestions lists low-stock products with the supplier to reorder each from and a quantity
+// that brings stock back up to the reorder level while respecting the supplier's MOQ and pack size
+func GetReorderSuggestions(c *gin.Context) {
+	query := database.DB.Preload("Suppliers.Supplier").Where("is_active = ?", true)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var products []models.Product
+	if err := query.Order("name ASC").Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+		return
+	}
+
+	supplierFilter := c.Query("supplier_id")
+	today := startOfToday()
+
+	suggestions := []ReorderSuggestion{}
+	bySupplier := make(map[uint]*ReorderSupplierSummary)
+	for _, product := range products {
+		if !product.IsLowStock() {
+			continue
+		}
+		productSupplier, reason := reorderSupplier(product.Suppliers)
+		if productSupplier == nil {
+			continue
+		}
+		if supplierFilter != "" && fmt.Sprint(productSupplier.SupplierID) != supplierFilter {
+			continue
+		}
+
+		var stock, reorderLevel int
+		for _, ps := range product.Suppliers {
+			if ps.IsActive {
+				stock += ps.Stock
+				reorderLevel += ps.MinStock
+			}
+		}
+		available := stock - reservedStock(database.DB, product.ID, nil, 0, 0)
+		shortfall := max(reorderLevel-available, 0)
+		quantity := roundOrderQuantity(productSupplier, shortfall)
+
+		suggestion := ReorderSuggestion{
+			ProductID:         product.ID,
+			SKU:               product.SKU,
+			Name:              product.Name,
+			Available:         available,
+			ReorderLevel:      reorderLevel,
+			Shortfall:         shortfall,
+			ProductSupplierID: productSupplier.ID,
+			SupplierID:        productSupplier.SupplierID,
+			SupplierName:      productSupplier.Supplier.Name,
+			Reason:            reason,
+			Quantity:          quantity,
+			UnitCost:          productSupplier.Cost,
+			EstimatedTotal:    money.LineTotal(baseCurrency(), quantity, productSupplier.Cost),
+			LeadTimeDays:      productSupplier.LeadTimeDays,
+			ExpectedDate:      today.AddDate(0, 0, productSupplier.LeadTimeDays).Format("2006-01-02"),
+			MinOrderQty:       productSupplier.MinOrderQty,
+			OrderMultiple:     productSupplier.OrderMultiple,
+		}
+		suggestions = append(suggestions, suggestion)
+
+		summary, ok := bySupplier[suggestion.SupplierID]
+		if !ok {
+			summary = &ReorderSupplierSummary{SupplierID: suggestion.SupplierID, SupplierName: suggestion.SupplierName}
+			bySupplier[suggestion.SupplierID] = summary
+		}
+		summary.Items++
+		summary.EstimatedTotal = summary.EstimatedTotal.Add(suggestion.EstimatedTotal)
+	}
+
+	suppliers := make([]ReorderSupplierSummary, 0, len(bySupplier))
+	for _, summary := range bySupplier {
+		suppliers = append(suppliers, *summary)
+	}
+	sort.Slice(suppliers, func(i, j int) bool { return suppliers[i].SupplierName < suppliers[j].SupplierName })
+
+	c.JSON(http.StatusOK, gin.H{
+		"suggestions": suggestions,
+		"suppliers":   suppliers,
+	})
+}
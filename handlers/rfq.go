@@ -0,0 +1,694 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// RFQRequest represents the request body for asking several suppliers to quote
+type RFQRequest struct {
+	SupplierIDs []uint           `json:"supplier_ids" binding:"required,min=1"`
+	RespondBy   string           `json:"respond_by"` // YYYY-MM-DD
+	Notes       string           `json:"notes"`
+	Items       []RFQItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// RFQItemRequest is a product and quantity to ask quotes for
+type RFQItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+// RFQQuoteRequest represents a supplier's response to an RFQ
+type RFQQuoteRequest struct {
+	Declined bool                  `json:"declined"` // The supplier won't quote
+	Notes    string                `json:"notes"`
+	Items    []RFQQuoteItemRequest `json:"items" binding:"omitempty,dive"`
+}
+
+// RFQQuoteItemRequest is the quoted unit cost and lead time of one RFQ item
+type RFQQuoteItemRequest struct {
+	RFQItemID    uint            `json:"rfq_item_id" binding:"required"`
+	UnitCost     decimal.Decimal `json:"unit_cost"`
+	LeadTimeDays int             `json:"lead_time_days" binding:"min=0"`
+	Notes        string          `json:"notes"`
+}
+
+// AwardRFQRequest represents the request body for awarding an RFQ; the payment terms are those of
+// the purchase order it creates
+type AwardRFQRequest struct {
+	SupplierID    uint            `json:"supplier_id" binding:"required"`
+	PaymentMethod string          `json:"payment_method"`
+	PaymentDays   int             `json:"payment_days"`
+	DownPayment   decimal.Decimal `json:"down_payment"`
+	OrderDate     string          `json:"order_date"` // YYYY-MM-DD, defaults to today
+	Notes         string          `json:"notes"`
+}
+
+// RFQQuoteComparison is one supplier's quote for an RFQ item in the comparison. Quotes are in the
+// supplier's currency and compared in the base currency at today's rate.
+type RFQQuoteComparison struct {
+	SupplierID   uint             `json:"supplier_id"`
+	SupplierName string           `json:"supplier_name"`
+	Currency     string           `json:"currency"`
+	ExchangeRate decimal.Decimal  `json:"exchange_rate"` // Base currency per unit of Currency today
+	UnitCost     decimal.Decimal  `json:"unit_cost" cost:"true"`
+	UnitCostBase decimal.Decimal  `json:"unit_cost_base" cost:"true"`
+	LeadTimeDays int              `json:"lead_time_days"`
+	Total        decimal.Decimal  `json:"total"`
+	TotalBase    decimal.Decimal  `json:"total_base" cost:"true"`
+	CurrentCost  *decimal.Decimal `json:"current_cost" cost:"true"` // What the supplier charges today in the base currency, if it already supplies the product
+	Notes        string           `json:"notes"`
+}
+
+// RFQItemComparison lines up the quotes for one RFQ item
+type RFQItemComparison struct {
+	RFQItemID             uint                 `json:"rfq_item_id"`
+	ProductID             uint                 `json:"product_id"`
+	SKU                   string               `json:"sku"`
+	Name                  string               `json:"name"`
+	Quantity              int                  `json:"quantity"`
+	Quotes                []RFQQuoteComparison `json:"quotes"`
+	LowestCostSupplierID  *uint                `json:"lowest_cost_supplier_id" cost:"true"`
+	FastestLeadSupplierID *uint                `json:"fastest_lead_supplier_id"`
+}
+
+// RFQSupplierComparison sums up a supplier's quote over the whole RFQ
+type RFQSupplierComparison struct {
+	SupplierID      uint            `json:"supplier_id"`
+	SupplierName    string          `json:"supplier_name"`
+	Status          string          `json:"status"`
+	ItemsQuoted     int             `json:"items_quoted"`
+	Complete        bool            `json:"complete"` // Quoted every item
+	Currency        string          `json:"currency"`
+	Total           decimal.Decimal `json:"total"`
+	TotalBase       decimal.Decimal `json:"total_base" cost:"true"`
+	MaxLeadTimeDays int             `json:"max_lead_time_days"`
+}
+
+// RFQUnquotedItem is an RFQ item the awarded supplier didn't quote, left off the purchase order
+type RFQUnquotedItem struct {
+	RFQItemID uint   `json:"rfq_item_id"`
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// loadRFQ loads the RFQ in the :id parameter with its items, suppliers and their quotes
+func loadRFQ(c *gin.Context) (*models.RFQ, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid RFQ ID"})
+		return nil, false
+	}
+
+	var rfq models.RFQ
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).Preload("Items.Product").
+		Preload("Suppliers", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).Preload("Suppliers.Supplier").
+		Preload("Suppliers.Quotes").Preload("User").
+		First(&rfq, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "RFQ not found"})
+		return nil, false
+	}
+	return &rfq, true
+}
+
+// findRFQSupplier returns the invitation of the supplier in the :supplier_id parameter, responding when
+// the supplier isn't on the RFQ
+func findRFQSupplier(c *gin.Context, rfq *models.RFQ) (*models.RFQSupplier, bool) {
+	supplierID, err := strconv.ParseUint(c.Param("supplier_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return nil, false
+	}
+
+	for i := range rfq.Suppliers {
+		if rfq.Suppliers[i].SupplierID == uint(supplierID) {
+			return &rfq.Suppliers[i], true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Supplier is not on this RFQ"})
+	return nil, false
+}
+
+// GetRFQs lists RFQs with optional status and supplier filters
+func GetRFQs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.RFQ{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if supplierID := c.Query("supplier_id"); supplierID != "" {
+		query = query.Where("id IN (?)", database.DB.Model(&models.RFQSupplier{}).Select("rfq_id").Where("supplier_id = ?", supplierID))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var rfqs []models.RFQ
+	if err := query.Preload("User").Preload("Items.Product").Preload("Suppliers.Supplier").
+		Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&rfqs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch RFQs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rfqs":  rfqs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetRFQ returns an RFQ with its items and the quotes received so far
+func GetRFQ(c *gin.Context) {
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, rfq)
+}
+
+// CreateRFQ drafts a request for quotation to the given suppliers
+func CreateRFQ(c *gin.Context) {
+	var req RFQRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rfq := models.RFQ{
+		UserID: c.GetUint("user_id"),
+		Status: "draft",
+		Notes:  req.Notes,
+	}
+	if req.RespondBy != "" {
+		respondBy, err := time.ParseInLocation("2006-01-02", req.RespondBy, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid respond_by, expected YYYY-MM-DD"})
+			return
+		}
+		rfq.RespondBy = &respondBy
+	}
+
+	tx := database.DB.Begin()
+
+	invited := make(map[uint]bool)
+	for _, supplierID := range req.SupplierIDs {
+		if invited[supplierID] {
+			continue
+		}
+		var supplier models.Supplier
+		if err := tx.Where("id = ? AND is_active = ?", supplierID, true).First(&supplier).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Supplier with ID %d not found or inactive", supplierID)})
+			return
+		}
+		invited[supplierID] = true
+		rfq.Suppliers = append(rfq.Suppliers, models.RFQSupplier{SupplierID: supplier.ID, Status: "pending"})
+	}
+
+	// The same product asked twice is one line
+	lines := make(map[uint]int)
+	for _, itemReq := range req.Items {
+		if _, ok := lines[itemReq.ProductID]; ok {
+			rfq.Items[lines[itemReq.ProductID]].Quantity += itemReq.Quantity
+			continue
+		}
+		var product models.Product
+		if err := tx.First(&product, itemReq.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product with ID %d not found", itemReq.ProductID)})
+			return
+		}
+		lines[product.ID] = len(rfq.Items)
+		rfq.Items = append(rfq.Items, models.RFQItem{ProductID: product.ID, Quantity: itemReq.Quantity})
+	}
+
+	rfq.RFQNumber = nextDocumentNumber(tx, &models.RFQ{}, "rfq_number", "RFQ")
+	if err := tx.Create(&rfq).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create RFQ"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "rfq", rfq.ID, nil, rfq) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "RFQ created successfully", "rfq": rfq})
+}
+
+// SubmitRFQQuote records a supplier's quoted unit costs and lead times, replacing any earlier quote,
+// or that the supplier declined to quote
+func SubmitRFQQuote(c *gin.Context) {
+	var req RFQQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+	invitation, ok := findRFQSupplier(c, rfq)
+	if !ok {
+		return
+	}
+	if rfq.Status != "draft" && rfq.Status != "sent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("RFQ is %s and no longer takes quotes", rfq.Status)})
+		return
+	}
+	if !req.Declined && len(req.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quote at least one item, or set declined"})
+		return
+	}
+
+	onRFQ := make(map[uint]bool)
+	for _, item := range rfq.Items {
+		onRFQ[item.ID] = true
+	}
+	quoted := make(map[uint]bool)
+	var quotes []models.RFQQuote
+	for _, line := range req.Items {
+		if !onRFQ[line.RFQItemID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d is not on this RFQ", line.RFQItemID)})
+			return
+		}
+		if quoted[line.RFQItemID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d is quoted more than once", line.RFQItemID)})
+			return
+		}
+		if line.UnitCost.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d has a negative unit cost", line.RFQItemID)})
+			return
+		}
+		quoted[line.RFQItemID] = true
+		quotes = append(quotes, models.RFQQuote{
+			RFQSupplierID: invitation.ID,
+			RFQItemID:     line.RFQItemID,
+			UnitCost:      money.RoundUnit(line.UnitCost),
+			LeadTimeDays:  line.LeadTimeDays,
+			Notes:         line.Notes,
+		})
+	}
+
+	before := audit.Snapshot(invitation)
+
+	tx := database.DB.Begin()
+
+	if err := tx.Where("rfq_supplier_id = ?", invitation.ID).Delete(&models.RFQQuote{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace quote"})
+		return
+	}
+
+	now := time.Now()
+	invitation.Status = "quoted"
+	invitation.QuotedAt = &now
+	invitation.Notes = req.Notes
+	invitation.Quotes = nil
+	if req.Declined {
+		invitation.Status = "declined"
+		quotes = nil
+	}
+	for i := range quotes {
+		if err := tx.Create(&quotes[i]).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quote"})
+			return
+		}
+	}
+	invitation.Quotes = quotes
+
+	if err := tx.Model(invitation).Select("status", "quoted_at", "notes").Updates(invitation).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update RFQ supplier"})
+		return
+	}
+
+	if !recordAudit(c, tx, "quote", "rfq", rfq.ID, before, invitation) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quote recorded", "supplier": invitation})
+}
+
+// CompareRFQ lines the suppliers' quotes up side by side per item and in total. Quotes in other
+// currencies are compared at today's exchange rate. The recommended supplier is the cheapest of
+// those that quoted every item.
+func CompareRFQ(c *gin.Context) {
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+
+	// What the invited suppliers charge today for the products they already supply
+	productIDs := make([]uint, 0, len(rfq.Items))
+	for _, item := range rfq.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	supplierIDs := make([]uint, 0, len(rfq.Suppliers))
+	for _, invitation := range rfq.Suppliers {
+		supplierIDs = append(supplierIDs, invitation.SupplierID)
+	}
+	var current []models.ProductSupplier
+	database.DB.Where("product_id IN ? AND supplier_id IN ?", productIDs, supplierIDs).Find(&current)
+	currentCost := make(map[[2]uint]decimal.Decimal)
+	for _, ps := range current {
+		currentCost[[2]uint{ps.ProductID, ps.SupplierID}] = ps.Cost
+	}
+
+	items := make([]RFQItemComparison, 0, len(rfq.Items))
+	position := make(map[uint]int)
+	for _, item := range rfq.Items {
+		position[item.ID] = len(items)
+		items = append(items, RFQItemComparison{
+			RFQItemID: item.ID,
+			ProductID: item.ProductID,
+			SKU:       item.Product.SKU,
+			Name:      item.Product.Name,
+			Quantity:  item.Quantity,
+			Quotes:    []RFQQuoteComparison{},
+		})
+	}
+
+	base := baseCurrency()
+	rates := make(map[string]decimal.Decimal)
+	suppliers := make([]RFQSupplierComparison, 0, len(rfq.Suppliers))
+	for _, invitation := range rfq.Suppliers {
+		// Quotes are in the currency the purchase order would be in if the supplier is awarded
+		currency := base
+		if invitation.Supplier.Currency != "" {
+			currency = strings.ToUpper(invitation.Supplier.Currency)
+		}
+		rate, ok := rates[currency]
+		if !ok && len(invitation.Quotes) > 0 {
+			var err error
+			if rate, err = lookupExchangeRate(database.DB, currency, base, time.Now()); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Enter an exchange rate to compare quotes from %s: %v", invitation.Supplier.Name, err)})
+				return
+			}
+			rates[currency] = rate
+		}
+
+		summary := RFQSupplierComparison{
+			SupplierID:   invitation.SupplierID,
+			SupplierName: invitation.Supplier.Name,
+			Status:       invitation.Status,
+			Currency:     currency,
+		}
+		for _, quote := range invitation.Quotes {
+			i, ok := position[quote.RFQItemID]
+			if !ok {
+				continue
+			}
+			line := RFQQuoteComparison{
+				SupplierID:   invitation.SupplierID,
+				SupplierName: invitation.Supplier.Name,
+				Currency:     currency,
+				ExchangeRate: rate,
+				UnitCost:     quote.UnitCost,
+				UnitCostBase: money.RoundUnit(quote.UnitCost.Mul(rate)),
+				LeadTimeDays: quote.LeadTimeDays,
+				Total:        money.LineTotal(currency, items[i].Quantity, quote.UnitCost),
+				Notes:        quote.Notes,
+			}
+			line.TotalBase = toBase(line.Total, rate)
+			if cost, ok := currentCost[[2]uint{items[i].ProductID, invitation.SupplierID}]; ok {
+				line.CurrentCost = &cost
+			}
+			items[i].Quotes = append(items[i].Quotes, line)
+
+			summary.ItemsQuoted++
+			summary.Total = summary.Total.Add(line.Total)
+			summary.TotalBase = summary.TotalBase.Add(line.TotalBase)
+			summary.MaxLeadTimeDays = max(summary.MaxLeadTimeDays, quote.LeadTimeDays)
+		}
+		summary.Complete = summary.ItemsQuoted == len(rfq.Items)
+		suppliers = append(suppliers, summary)
+	}
+
+	for i := range items {
+		quotes := items[i].Quotes
+		if len(quotes) == 0 {
+			continue
+		}
+		sort.SliceStable(quotes, func(a, b int) bool { return quotes[a].UnitCostBase.LessThan(quotes[b].UnitCostBase) })
+		lowest := quotes[0].SupplierID
+		items[i].LowestCostSupplierID = &lowest
+
+		fastest := quotes[0]
+		for _, quote := range quotes[1:] {
+			if quote.LeadTimeDays < fastest.LeadTimeDays {
+				fastest = quote
+			}
+		}
+		items[i].FastestLeadSupplierID = &fastest.SupplierID
+	}
+
+	var recommended *RFQSupplierComparison
+	for i := range suppliers {
+		if suppliers[i].Complete && (recommended == nil || suppliers[i].TotalBase.LessThan(recommended.TotalBase)) {
+			recommended = &suppliers[i]
+		}
+	}
+	var recommendedID *uint
+	if recommended != nil {
+		recommendedID = &recommended.SupplierID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rfq_id":                  rfq.ID,
+		"rfq_number":              rfq.RFQNumber,
+		"status":                  rfq.Status,
+		"items":                   items,
+		"suppliers":               suppliers,
+		"recommended_supplier_id": recommendedID,
+	})
+}
+
+// AwardRFQ awards the RFQ to one supplier, creating a purchase order for the items it quoted at the
+// quoted costs. As with any purchase order, the supplier's product cost is updated to what it quoted,
+// and so is its lead time. The order is placed unreceived, so no stock is booked until the goods
+// arrive and it is received.
+func AwardRFQ(c *gin.Context) {
+	var req AwardRFQRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+	if rfq.Status != "draft" && rfq.Status != "sent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("RFQ is %s and can't be awarded", rfq.Status)})
+		return
+	}
+
+	var winner *models.RFQSupplier
+	for i := range rfq.Suppliers {
+		if rfq.Suppliers[i].SupplierID == req.SupplierID {
+			winner = &rfq.Suppliers[i]
+			break
+		}
+	}
+	if winner == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Supplier is not on this RFQ"})
+		return
+	}
+	if winner.Status != "quoted" || len(winner.Quotes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Supplier hasn't quoted on this RFQ"})
+		return
+	}
+
+	if req.OrderDate == "" {
+		req.OrderDate = time.Now().Format("2006-01-02")
+	}
+	if strings.TrimSpace(req.Notes) == "" {
+		req.Notes = fmt.Sprintf("Awarded from %s", rfq.RFQNumber)
+	}
+	order := CreatePurchaseOrderRequest{
+		SupplierID:    winner.SupplierID,
+		PaymentMethod: req.PaymentMethod,
+		PaymentDays:   req.PaymentDays,
+		DownPayment:   req.DownPayment,
+		Notes:         req.Notes,
+		OrderDate:     req.OrderDate,
+	}
+	quotes := make(map[uint]models.RFQQuote, len(winner.Quotes))
+	leadTime := 0
+	for _, quote := range winner.Quotes {
+		quotes[quote.RFQItemID] = quote
+		leadTime = max(leadTime, quote.LeadTimeDays)
+	}
+	if orderDate, err := time.Parse("2006-01-02", req.OrderDate); err == nil {
+		order.ExpectedDate = orderDate.AddDate(0, 0, leadTime).Format("2006-01-02")
+	}
+	unquoted := []RFQUnquotedItem{}
+	for _, item := range rfq.Items {
+		quote, ok := quotes[item.ID]
+		if !ok {
+			unquoted = append(unquoted, RFQUnquotedItem{
+				RFQItemID: item.ID,
+				ProductID: item.ProductID,
+				SKU:       item.Product.SKU,
+				Name:      item.Product.Name,
+				Quantity:  item.Quantity,
+			})
+			continue
+		}
+		order.Items = append(order.Items, CreatePurchaseOrderItem{
+			SKU:         item.Product.SKU,
+			ProductName: item.Product.Name,
+			Quantity:    item.Quantity,
+			UnitCost:    quote.UnitCost,
+		})
+	}
+
+	before := audit.Snapshot(gin.H{"status": rfq.Status})
+
+	tx := database.DB.Begin()
+
+	// Claim the RFQ first so it can't be awarded twice
+	result := tx.Model(&models.RFQ{}).Where("id = ? AND status = ?", rfq.ID, rfq.Status).
+		Updates(map[string]interface{}{"status": "awarded", "awarded_supplier_id": winner.SupplierID})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to award RFQ"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "RFQ was changed by someone else"})
+		return
+	}
+
+	po, ok := createPurchaseOrderTx(c, tx, order, false)
+	if !ok {
+		return
+	}
+
+	if err := tx.Model(&models.RFQ{}).Where("id = ?", rfq.ID).Update("purchase_order_id", po.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link purchase order"})
+		return
+	}
+	// The winner's quoted lead times become its lead times for these products
+	for _, item := range rfq.Items {
+		quote, ok := quotes[item.ID]
+		if !ok {
+			continue
+		}
+		if err := tx.Model(&models.ProductSupplier{}).Where("product_id = ? AND supplier_id = ?", item.ProductID, winner.SupplierID).
+			Update("lead_time_days", quote.LeadTimeDays).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier lead time"})
+			return
+		}
+	}
+
+	if err := tx.Model(&models.RFQSupplier{}).Where("id = ?", winner.ID).Update("status", "awarded").Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update RFQ suppliers"})
+		return
+	}
+	if err := tx.Model(&models.RFQSupplier{}).Where("rfq_id = ? AND id <> ? AND status <> ?", rfq.ID, winner.ID, "declined").
+		Update("status", "lost").Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update RFQ suppliers"})
+		return
+	}
+
+	if !recordAudit(c, tx, "award", "rfq", rfq.ID, before, gin.H{
+		"status":              "awarded",
+		"awarded_supplier_id": winner.SupplierID,
+		"purchase_order_id":   po.ID,
+	}) {
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to award RFQ"})
+		return
+	}
+
+	var completePO models.PurchaseOrder
+	database.DB.Preload("User").Preload("Supplier").Preload("Items.Product").Preload("Items.ProductSupplier.Supplier").First(&completePO, po.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "RFQ awarded and purchase order created",
+		"rfq_id":         rfq.ID,
+		"purchase_order": completePO,
+		"unquoted_items": unquoted,
+	})
+}
+
+// CancelRFQ cancels an RFQ that hasn't been awarded
+func CancelRFQ(c *gin.Context) {
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+	if rfq.Status != "draft" && rfq.Status != "sent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("RFQ is %s and can't be cancelled", rfq.Status)})
+		return
+	}
+
+	before := audit.Snapshot(gin.H{"status": rfq.Status})
+
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.RFQ{}).Where("id = ? AND status = ?", rfq.ID, rfq.Status).Update("status", "cancelled")
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel RFQ"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "RFQ was changed by someone else"})
+		return
+	}
+	rfq.Status = "cancelled"
+
+	if !recordAudit(c, tx, "cancel", "rfq", rfq.ID, before, gin.H{"status": rfq.Status}) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "RFQ cancelled", "rfq": rfq})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/mailer"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendRFQRequest represents the request body for sending an RFQ to its suppliers
+type SendRFQRequest struct {
+	Message string `json:"message"` // Replaces the default email body
+	Manual  bool   `json:"manual"`  // Mark as sent without emailing, e.g. when asked by phone
+}
+
+// RFQSendResult is the outcome of sending an RFQ to one supplier
+type RFQSendResult struct {
+	SupplierID   uint   `json:"supplier_id"`
+	SupplierName string `json:"supplier_name"`
+	Recipient    string `json:"recipient,omitempty"`
+	Status       string `json:"status"` // sent, failed, skipped
+	Error        string `json:"error,omitempty"`
+}
+
+// GetRFQPDF renders an RFQ as an A4 document
+func GetRFQPDF(c *gin.Context) {
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+
+	data, err := renderRFQ(loadDocumentProfile(), rfq, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate RFQ document"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("rfq_%s.pdf", rfq.RFQNumber), data)
+}
+
+// SendRFQ emails the RFQ document to every invited supplier that hasn't answered yet, or with manual
+// set only records that they were asked. Suppliers without a valid email address are skipped.
+func SendRFQ(c *gin.Context) {
+	var req SendRFQRequest
+	// The body is optional; an empty request emails the default text
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	rfq, ok := loadRFQ(c)
+	if !ok {
+		return
+	}
+	if rfq.Status != "draft" && rfq.Status != "sent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("RFQ is %s and can't be sent", rfq.Status)})
+		return
+	}
+
+	profile := loadDocumentProfile()
+	var results []RFQSendResult
+	var sent []uint
+	for _, invitation := range rfq.Suppliers {
+		if invitation.Status != "pending" && invitation.Status != "sent" {
+			continue
+		}
+		result := RFQSendResult{SupplierID: invitation.SupplierID, SupplierName: invitation.Supplier.Name, Status: "sent"}
+		if req.Manual {
+			results = append(results, result)
+			sent = append(sent, invitation.ID)
+			continue
+		}
+
+		result.Recipient = strings.TrimSpace(invitation.Supplier.Email)
+		if _, err := mail.ParseAddress(result.Recipient); err != nil {
+			result.Status = "skipped"
+			result.Error = "Supplier has no valid email address"
+			results = append(results, result)
+			continue
+		}
+
+		data, err := renderRFQ(profile, rfq, &invitation.Supplier)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate RFQ document"})
+			return
+		}
+
+		body := req.Message
+		if body == "" {
+			body = rfqEmailBody(profile, rfq, &invitation.Supplier)
+		}
+
+		sendErr := mailer.Send(mailer.Message{
+			To:      []string{result.Recipient},
+			ReplyTo: profile.CompanyEmail,
+			Subject: fmt.Sprintf("Request for Quotation %s from %s", rfq.RFQNumber, profile.CompanyName),
+			Body:    body,
+			Attachments: []mailer.Attachment{{
+				Filename:    fmt.Sprintf("rfq_%s.pdf", rfq.RFQNumber),
+				ContentType: "application/pdf",
+				Data:        data,
+			}},
+		})
+		if errors.Is(sendErr, mailer.ErrNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured on this server"})
+			return
+		}
+		if sendErr != nil {
+			result.Status = "failed"
+			result.Error = sendErr.Error()
+		} else {
+			sent = append(sent, invitation.ID)
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Every supplier has already answered this RFQ"})
+		return
+	}
+	if len(sent) == 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "RFQ could not be sent to any supplier", "results": results})
+		return
+	}
+
+	before := audit.Snapshot(gin.H{"status": rfq.Status, "sent_at": rfq.SentAt})
+
+	tx := database.DB.Begin()
+
+	now := time.Now()
+	if err := tx.Model(&models.RFQSupplier{}).Where("id IN ?", sent).
+		Updates(map[string]interface{}{"status": "sent", "sent_at": now}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "RFQ was sent but its suppliers could not be updated"})
+		return
+	}
+	if err := tx.Model(&models.RFQ{}).Where("id = ?", rfq.ID).
+		Updates(map[string]interface{}{"status": "sent", "sent_at": now}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "RFQ was sent but its status could not be updated"})
+		return
+	}
+	rfq.Status = "sent"
+	rfq.SentAt = &now
+
+	if !recordAudit(c, tx, "send", "rfq", rfq.ID, before, gin.H{"status": rfq.Status, "sent_at": rfq.SentAt, "results": results}) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "RFQ sent to suppliers", "results": results})
+}
+
+// rfqEmailBody is the default plain-text email accompanying the RFQ document
+func rfqEmailBody(profile models.CompanyProfile, rfq *models.RFQ, supplier *models.Supplier) string {
+	greeting := "Hello"
+	if supplier.ContactPerson != "" {
+		greeting = "Dear " + supplier.ContactPerson
+	}
+
+	request := fmt.Sprintf("Please find attached our request for quotation %s. Kindly quote your unit cost and lead time for each item.", rfq.RFQNumber)
+	if rfq.RespondBy != nil {
+		request += fmt.Sprintf(" We would appreciate your quote by %s.", rfq.RespondBy.Format("02 Jan 2006"))
+	}
+
+	lines := []string{
+		greeting + ",",
+		"",
+		request,
+		"",
+		"Regards,",
+		profile.CompanyName,
+	}
+	if contact := joinNonEmpty(" | ", profile.CompanyPhone, profile.CompanyEmail); contact != "" {
+		lines = append(lines, contact)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderRFQ builds the A4 RFQ document, addressed to supplier when given. Suppliers fill in the
+// blank cost and lead time columns.
+func renderRFQ(profile models.CompanyProfile, rfq *models.RFQ, supplier *models.Supplier) ([]byte, error) {
+	doc := newPDFDocument(profile, "REQUEST FOR QUOTATION", rfq.RFQNumber, rfq.CreatedAt)
+
+	respondBy := ""
+	if rfq.RespondBy != nil {
+		respondBy = rfq.RespondBy.Format("02 Jan 2006")
+	}
+	var details [][2]string
+	if supplier != nil {
+		details = append(details,
+			[2]string{"Supplier:", supplier.Name},
+			[2]string{"Attention:", supplier.ContactPerson},
+			[2]string{"Contact:", joinNonEmpty(" | ", supplier.Phone, supplier.Email)},
+		)
+	}
+	details = append(details,
+		[2]string{"Date:", rfq.CreatedAt.Format("02 Jan 2006")},
+		[2]string{"Respond By:", respondBy},
+		[2]string{"Requested By:", rfq.User.Name},
+	)
+	doc.keyValues(details)
+
+	rows := make([][]string, 0, len(rfq.Items))
+	for i, item := range rfq.Items {
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			item.Product.SKU,
+			item.Product.Name,
+			strconv.Itoa(item.Quantity),
+			"",
+			"",
+		})
+	}
+	doc.table(
+		[]string{"#", "SKU", "Description", "Qty", "Unit Cost", "Lead Time"},
+		[]float64{10, 28, 62, 15, 35, 30},
+		[]string{"C", "L", "L", "R", "R", "R"},
+		rows,
+	)
+
+	doc.section("Notes", rfq.Notes)
+
+	return doc.bytes()
+}
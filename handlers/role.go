@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleRequest represents the request body for creating or updating a role
+type RoleRequest struct {
+	Name             string   `json:"name"`
+	Description      *string  `json:"description"`        // Omit on update to keep the current description
+	Permissions      []string `json:"permissions"`        // Omit on update to keep the current set
+	RequireTwoFactor *bool    `json:"require_two_factor"` // Omit on update to keep the current policy
+}
+
+// validatePermissions checks the requested permissions and removes duplicates
+func validatePermissions(requested []string) ([]string, error) {
+	seen := make(map[string]bool, len(requested))
+	var result []string
+	for _, permission := range requested {
+		if !permissions.Valid(permission) {
+			return nil, fmt.Errorf("unknown permission %q", permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// checkGrantable responds with 403 and returns false when the user lacks one of the permissions,
+// so nobody can put more access into a role than they hold themselves
+func checkGrantable(c *gin.Context, granted []string) bool {
+	for _, permission := range granted {
+		if !middleware.HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can't grant a permission you don't hold: " + permission})
+			return false
+		}
+	}
+	return true
+}
+
+// GetPermissions lists every permission that can be granted (admin only)
+func GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions.All})
+}
+
+// GetMyPermissions returns the permissions of the current user's role, narrowed to the key's
+// scopes for API key requests
+func GetMyPermissions(c *gin.Context) {
+	role, _ := c.Get("user_role")
+	name, _ := role.(string)
+
+	granted := make([]string, 0)
+	for permission := range middleware.RolePermissions(name) {
+		if middleware.HasPermission(c, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	sort.Strings(granted)
+
+	c.JSON(http.StatusOK, gin.H{"role": name, "permissions": granted})
+}
+
+// GetRoles lists roles with their permissions and how many users hold each (admin only)
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("name ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	type roleCount struct {
+		Role  string
+		Count int64
+	}
+	var counts []roleCount
+	database.DB.Model(&models.User{}).Select("role, COUNT(*) AS count").Group("role").Scan(&counts)
+	userCounts := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		userCounts[count.Role] = count.Count
+	}
+
+	result := make([]gin.H, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, gin.H{
+			"role":       role,
+			"user_count": userCounts[role.Name],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": result})
+}
+
+// CreateRole creates a custom role (admin only)
+func CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name is required"})
+		return
+	}
+	if isValidRole(name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+		return
+	}
+
+	granted, err := validatePermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkGrantable(c, granted) {
+		return
+	}
+
+	role := models.Role{Name: name}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.RequireTwoFactor != nil {
+		role.RequireTwoFactor = *req.RequireTwoFactor
+	}
+	for _, permission := range granted {
+		role.Permissions = append(role.Permissions, models.RolePermission{Permission: permission})
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&role).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "role", role.ID, nil, role) {
+		return
+	}
+
+	tx.Commit()
+	middleware.InvalidatePermissionCache()
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole updates a role's description, two-factor policy and permission set (admin only).
+// The admin role's permissions are fixed but its two-factor policy can be changed.
+func UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if !middleware.CanGrantRole(c, role.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't edit a role with permissions you don't hold"})
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != "" && strings.ToLower(strings.TrimSpace(req.Name)) != role.Name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Roles can't be renamed"})
+		return
+	}
+	if role.Name == permissions.AdminRole && req.Permissions != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role always has every permission and can't be edited"})
+		return
+	}
+
+	granted, err := validatePermissions(req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkGrantable(c, granted) {
+		return
+	}
+
+	before := audit.Snapshot(role)
+
+	updates := map[string]interface{}{}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.RequireTwoFactor != nil {
+		updates["require_two_factor"] = *req.RequireTwoFactor
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&role).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if req.Permissions != nil {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+			return
+		}
+
+		for _, permission := range granted {
+			if err := tx.Create(&models.RolePermission{RoleID: role.ID, Permission: permission}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+				return
+			}
+		}
+	}
+
+	tx.Preload("Permissions").First(&role, role.ID)
+	if !recordAudit(c, tx, audit.ActionUpdate, "role", role.ID, before, role) {
+		return
+	}
+
+	tx.Commit()
+	middleware.InvalidatePermissionCache()
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role that no user holds (admin only)
+func DeleteRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles can't be deleted"})
+		return
+	}
+
+	var userCount int64
+	database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&userCount)
+	if userCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Role is assigned to %d user(s)", userCount)})
+		return
+	}
+
+	var pendingInvites int64
+	database.DB.Model(&models.Invitation{}).Where("role = ? AND accepted_at IS NULL AND revoked_at IS NULL", role.Name).Count(&pendingInvites)
+	if pendingInvites > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Role has %d pending invitation(s)", pendingInvites)})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	if err := tx.Delete(&role).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionDelete, "role", role.ID, role, nil) {
+		return
+	}
+
+	tx.Commit()
+	middleware.InvalidatePermissionCache()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
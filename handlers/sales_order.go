@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// SalesOrderRequest represents the request body for placing a sales order
+type SalesOrderRequest struct {
+	CustomerName    string                  `json:"customer_name" binding:"required"`
+	CustomerPhone   string                  `json:"customer_phone"`
+	DeliveryAddress string                  `json:"delivery_address"`
+	RequestedDate   string                  `json:"requested_date"` // YYYY-MM-DD
+	Discount        decimal.Decimal         `json:"discount"`
+	Tax             decimal.Decimal         `json:"tax"`
+	Notes           string                  `json:"notes"`
+	Items           []SalesOrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	// Manager credentials for prices beyond what the user may set alone; see SaleRequest
+	OverrideApproval *PriceOverrideApproval `json:"override_approval"`
+}
+
+// SalesOrderItemRequest represents an ordered line
+type SalesOrderItemRequest struct {
+	ProductID  uint             `json:"product_id" binding:"required"`
+	SupplierID *uint            `json:"supplier_id"` // Optional supplier to ship from
+	Quantity   int              `json:"quantity" binding:"required,min=1"`
+	Price      *decimal.Decimal `json:"price"` // Defaults to the list price
+}
+
+// DeliveryNoteRequest represents a shipment; without items everything outstanding ships
+type DeliveryNoteRequest struct {
+	Carrier        string                    `json:"carrier"`
+	TrackingNumber string                    `json:"tracking_number"`
+	Notes          string                    `json:"notes"`
+	Items          []DeliveryNoteItemRequest `json:"items" binding:"omitempty,dive"`
+}
+
+// DeliveryNoteItemRequest is the quantity of one sales order line to ship
+type DeliveryNoteItemRequest struct {
+	SalesOrderItemID uint `json:"sales_order_item_id" binding:"required"`
+	Quantity         int  `json:"quantity" binding:"required,min=1"`
+}
+
+// loadSalesOrder loads the sales order in the :id parameter with its lines and delivery notes
+func loadSalesOrder(c *gin.Context) (*models.SalesOrder, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sales order ID"})
+		return nil, false
+	}
+
+	var order models.SalesOrder
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("Items.ProductSupplier.Supplier").Preload("User").
+		Preload("DeliveryNotes", func(db *gorm.DB) *gorm.DB { return db.Order("shipped_at ASC") }).
+		Preload("DeliveryNotes.Items.Product").Preload("DeliveryNotes.User").
+		First(&order, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sales order not found"})
+		return nil, false
+	}
+	return &order, true
+}
+
+// shipStock deducts quantity of a sales order line from stock and records the movement. Stock
+// reserved for other carts and orders can't be shipped. On failure it rolls tx back, responds and
+// returns false.
+func shipStock(c *gin.Context, tx *gorm.DB, order *models.SalesOrder, item models.SalesOrderItem, quantity int, reference string) bool {
+	var product models.Product
+	if err := tx.Preload("Suppliers.Supplier").First(&product, item.ProductID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product with ID %d not found", item.ProductID)})
+		return false
+	}
+
+	var supplierName string
+	if item.ProductSupplierID != nil {
+		var productSupplier *models.ProductSupplier
+		for i := range product.Suppliers {
+			if product.Suppliers[i].ID == *item.ProductSupplierID {
+				productSupplier = &product.Suppliers[i]
+				break
+			}
+		}
+		if productSupplier == nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Supplier for product %s no longer exists", product.Name)})
+			return false
+		}
+
+		available := productSupplier.Stock - reservedStock(tx, product.ID, &productSupplier.ID, 0, order.ID)
+		if available < quantity {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Insufficient stock for product %s from selected supplier. Available: %d, Requested: %d", product.Name, available, quantity),
+			})
+			return false
+		}
+
+		productSupplier.Stock -= quantity
+		if err := tx.Save(productSupplier).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
+			return false
+		}
+		supplierName = productSupplier.Supplier.Name
+	} else {
+		available := product.GetTotalStock() - reservedStock(tx, product.ID, nil, 0, order.ID)
+		if available < quantity {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Insufficient stock for product %s. Available: %d, Requested: %d", product.Name, available, quantity),
+			})
+			return false
+		}
+
+		// Same FIFO order as POS sales
+		remainingQty := quantity
+		for i, supplier := range product.Suppliers {
+			if remainingQty <= 0 || !supplier.IsActive || supplier.Stock <= 0 {
+				continue
+			}
+			deductQty := min(remainingQty, supplier.Stock)
+			product.Suppliers[i].Stock -= deductQty
+			remainingQty -= deductQty
+
+			if err := tx.Save(&product.Suppliers[i]).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier stock"})
+				return false
+			}
+		}
+	}
+
+	notes := fmt.Sprintf("Delivery for sales order %s", order.OrderNumber)
+	if supplierName != "" {
+		notes = fmt.Sprintf("%s - Supplier: %s", notes, supplierName)
+	}
+	movement := models.StockMovement{
+		ProductID: product.ID,
+		UserID:    c.GetUint("user_id"),
+		Type:      "out",
+		Quantity:  quantity,
+		Reference: reference,
+		Notes:     notes,
+	}
+	if err := tx.Create(&movement).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
+		return false
+	}
+	return true
+}
+
+// GetSalesOrders lists sales orders with optional status, customer and date filters
+func GetSalesOrders(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.SalesOrder{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if customer := c.Query("customer"); customer != "" {
+		query = query.Where("customer_name ILIKE ?", "%"+customer+"%")
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("order_date >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("order_date <= ?", endDate+" 23:59:59")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var orders []models.SalesOrder
+	if err := query.Preload("User").Preload("Items.Product").Order("order_date DESC").Offset((page - 1) * limit).Limit(limit).Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sales orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sales_orders": orders,
+		"total":        total,
+		"page":         page,
+		"limit":        limit,
+	})
+}
+
+// GetSalesOrder returns a sales order with its lines and delivery notes
+func GetSalesOrder(c *gin.Context) {
+	order, ok := loadSalesOrder(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// CreateSalesOrder places a sales order and reserves its stock until it ships
+func CreateSalesOrder(c *gin.Context) {
+	var req SalesOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Discount.IsNegative() || req.Tax.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Discount and tax can't be negative"})
+		return
+	}
+	for _, itemReq := range req.Items {
+		if itemReq.Price != nil && itemReq.Price.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Price of product %d can't be negative", itemReq.ProductID)})
+			return
+		}
+	}
+
+	currency := baseCurrency()
+	order := models.SalesOrder{
+		UserID:          c.GetUint("user_id"),
+		CustomerName:    strings.TrimSpace(req.CustomerName),
+		CustomerPhone:   strings.TrimSpace(req.CustomerPhone),
+		DeliveryAddress: req.DeliveryAddress,
+		Status:          "pending",
+		OrderDate:       time.Now(),
+		Discount:        money.Round(currency, req.Discount),
+		Tax:             money.Round(currency, req.Tax),
+		Notes:           req.Notes,
+	}
+	if req.RequestedDate != "" {
+		requested, err := time.ParseInLocation("2006-01-02", req.RequestedDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requested_date, expected YYYY-MM-DD"})
+			return
+		}
+		order.RequestedDate = &requested
+	}
+
+	tx := database.DB.Begin()
+
+	// Stock already reserved for earlier lines of this order
+	heldByProduct := make(map[uint]int)
+	heldBySupplier := make(map[uint]int)
+	heldUnpinned := make(map[uint]int) // Per product, by lines without a supplier
+	var overrideApprover *models.User
+
+	subtotal := decimal.Zero
+	for _, itemReq := range req.Items {
+		var product models.Product
+		if err := tx.Preload("Suppliers").First(&product, itemReq.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product with ID %d not found", itemReq.ProductID)})
+			return
+		}
+
+		item := models.SalesOrderItem{
+			ProductID:       product.ID,
+			QuantityOrdered: itemReq.Quantity,
+			Price:           product.GetLowestPrice(),
+		}
+		listCost := product.GetLowestCost()
+
+		var available int
+		if itemReq.SupplierID != nil {
+			productSupplier := findProductSupplier(tx, product.ID, *itemReq.SupplierID)
+			if productSupplier == nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Selected supplier ID %d not found or inactive for product %s", *itemReq.SupplierID, product.Name)})
+				return
+			}
+			item.ProductSupplierID = &productSupplier.ID
+			item.Price = productSupplier.Price
+			listCost = productSupplier.Cost
+			available = productSupplier.Stock - reservedStock(tx, product.ID, &productSupplier.ID, 0, 0) - heldBySupplier[productSupplier.ID] - heldUnpinned[product.ID]
+		} else {
+			available = product.GetTotalStock() - reservedStock(tx, product.ID, nil, 0, 0) - heldByProduct[product.ID]
+		}
+		if available < itemReq.Quantity {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Insufficient stock for product %s. Available: %d, Requested: %d", product.Name, available, itemReq.Quantity),
+			})
+			return
+		}
+		heldByProduct[product.ID] += itemReq.Quantity
+		if item.ProductSupplierID != nil {
+			heldBySupplier[*item.ProductSupplierID] += itemReq.Quantity
+		} else {
+			heldUnpinned[product.ID] += itemReq.Quantity
+		}
+
+		item.ListPrice = item.Price
+		if itemReq.Price != nil {
+			item.Price = money.RoundUnit(*itemReq.Price)
+		}
+
+		// Apply the same price override policy as a POS sale
+		overridden, approvalReason := checkPriceOverride(item.ListPrice, listCost, item.Price, listCost)
+		if overridden {
+			item.PriceOverridden = true
+			if canApprovePriceOverride(c) {
+				// Managers and admins approve their own overrides
+				id := c.GetUint("user_id")
+				item.OverrideApprovedByID = &id
+			} else if approvalReason != "" {
+				if overrideApprover == nil {
+					approver, err := resolveOverrideApprover(tx, req.OverrideApproval)
+					if err != nil {
+						tx.Rollback()
+						c.JSON(http.StatusForbidden, gin.H{
+							"error": fmt.Sprintf("Manager approval required for %s on product %s: %v", approvalReason, product.Name, err),
+						})
+						return
+					}
+					overrideApprover = approver
+				}
+				item.OverrideApprovedByID = &overrideApprover.ID
+			}
+			item.OverrideReason = approvalReason
+		}
+		item.Total = money.LineTotal(currency, item.QuantityOrdered, item.Price)
+		order.Items = append(order.Items, item)
+		subtotal = subtotal.Add(item.Total)
+	}
+
+	order.Subtotal = subtotal
+	order.Total = documentTotal(subtotal, order.Discount, order.Tax)
+	if order.Total.IsNegative() {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Discount can't exceed the order subtotal"})
+		return
+	}
+
+	order.OrderNumber = nextDocumentNumber(tx, &models.SalesOrder{}, "order_number", "SO")
+	if err := tx.Create(&order).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sales order"})
+		return
+	}
+
+	for _, item := range order.Items {
+		reservation := models.StockReservation{
+			SalesOrderID:      &order.ID,
+			SalesOrderItemID:  &item.ID,
+			ProductID:         item.ProductID,
+			ProductSupplierID: item.ProductSupplierID,
+			Quantity:          item.QuantityOrdered,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+			return
+		}
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "sales_order", order.ID, nil, order) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Sales order created successfully", "sales_order": order})
+}
+
+// CreateDeliveryNote ships some or all of what is outstanding on a sales order, deducting the stock
+func CreateDeliveryNote(c *gin.Context) {
+	order, ok := loadSalesOrder(c)
+	if !ok {
+		return
+	}
+
+	var req DeliveryNoteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if order.Status != "pending" && order.Status != "partially_shipped" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Sales order is %s and can't be shipped", order.Status)})
+		return
+	}
+
+	// Work out how much of each line ships
+	shipping := make(map[uint]int)
+	if len(req.Items) == 0 {
+		for _, item := range order.Items {
+			if outstanding := item.QuantityOrdered - item.QuantityShipped; outstanding > 0 {
+				shipping[item.ID] = outstanding
+			}
+		}
+	} else {
+		for _, line := range req.Items {
+			shipping[line.SalesOrderItemID] += line.Quantity
+		}
+	}
+
+	onOrder := make(map[uint]bool)
+	for _, item := range order.Items {
+		onOrder[item.ID] = true
+		quantity := shipping[item.ID]
+		if outstanding := item.QuantityOrdered - item.QuantityShipped; quantity > outstanding {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Only %d of %s is left to ship, requested %d", outstanding, item.Product.Name, quantity),
+			})
+			return
+		}
+	}
+	for id := range shipping {
+		if !onOrder[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d is not on this sales order", id)})
+			return
+		}
+	}
+	if len(shipping) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing left to ship"})
+		return
+	}
+
+	before := audit.Snapshot(gin.H{"status": order.Status})
+
+	tx := database.DB.Begin()
+
+	note := models.DeliveryNote{
+		DeliveryNumber: nextDocumentNumber(tx, &models.DeliveryNote{}, "delivery_number", "DN"),
+		SalesOrderID:   order.ID,
+		UserID:         c.GetUint("user_id"),
+		ShippedAt:      time.Now(),
+		Carrier:        strings.TrimSpace(req.Carrier),
+		TrackingNumber: strings.TrimSpace(req.TrackingNumber),
+		Notes:          req.Notes,
+	}
+
+	for _, item := range order.Items {
+		quantity := shipping[item.ID]
+		if quantity == 0 {
+			continue
+		}
+
+		if !shipStock(c, tx, order, item, quantity, note.DeliveryNumber) {
+			return
+		}
+
+		// The quantity condition stops two deliveries shipping the same goods
+		result := tx.Model(&models.SalesOrderItem{}).
+			Where("id = ? AND quantity_shipped + ? <= quantity_ordered", item.ID, quantity).
+			Update("quantity_shipped", gorm.Expr("quantity_shipped + ?", quantity))
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sales order"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Sales order was shipped by someone else"})
+			return
+		}
+
+		// Release the shipped quantity from the order's reservation
+		if err := tx.Model(&models.StockReservation{}).Where("sales_order_item_id = ?", item.ID).
+			Update("quantity", gorm.Expr("quantity - ?", quantity)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reserved stock"})
+			return
+		}
+		if err := tx.Where("sales_order_item_id = ? AND quantity <= 0", item.ID).Delete(&models.StockReservation{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reserved stock"})
+			return
+		}
+
+		note.Items = append(note.Items, models.DeliveryNoteItem{
+			SalesOrderItemID: item.ID,
+			ProductID:        item.ProductID,
+			Quantity:         quantity,
+		})
+	}
+
+	if err := tx.Create(&note).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create delivery note"})
+		return
+	}
+
+	// Whether everything has shipped follows from the lines as updated here, not as loaded
+	var outstanding int64
+	if err := tx.Model(&models.SalesOrderItem{}).
+		Where("sales_order_id = ? AND quantity_shipped < quantity_ordered", order.ID).Count(&outstanding).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sales order"})
+		return
+	}
+	status := "partially_shipped"
+	if outstanding == 0 {
+		status = "delivered"
+	}
+
+	// The status condition stops an order cancelled in the meantime from shipping anyway
+	result := tx.Model(&models.SalesOrder{}).
+		Where("id = ? AND status IN ?", order.ID, []string{"pending", "partially_shipped"}).Update("status", status)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sales order"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Sales order was cancelled or changed by someone else"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "delivery_note", note.ID, nil, note) {
+		return
+	}
+	if !recordAudit(c, tx, "ship", "sales_order", order.ID, before, gin.H{"status": status, "delivery_note": note.DeliveryNumber}) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Delivery note created successfully", "delivery_note": note, "status": status})
+}
+
+// CancelSalesOrder cancels what hasn't shipped yet and releases its reserved stock
+func CancelSalesOrder(c *gin.Context) {
+	order, ok := loadSalesOrder(c)
+	if !ok {
+		return
+	}
+	if order.Status != "pending" && order.Status != "partially_shipped" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Sales order is %s and can't be cancelled", order.Status)})
+		return
+	}
+
+	before := audit.Snapshot(gin.H{"status": order.Status})
+
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.SalesOrder{}).Where("id = ? AND status = ?", order.ID, order.Status).Update("status", "cancelled")
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel sales order"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Sales order was changed by someone else"})
+		return
+	}
+
+	if err := tx.Where("sales_order_id = ?", order.ID).Delete(&models.StockReservation{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reserved stock"})
+		return
+	}
+
+	if !recordAudit(c, tx, "cancel", "sales_order", order.ID, before, gin.H{"status": "cancelled"}) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sales order cancelled successfully"})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDeliveryNotePDF renders a delivery note of a sales order for the driver and the customer to sign
+func GetDeliveryNotePDF(c *gin.Context) {
+	order, ok := loadSalesOrder(c)
+	if !ok {
+		return
+	}
+
+	noteID, err := strconv.ParseUint(c.Param("note_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery note ID"})
+		return
+	}
+
+	var note *models.DeliveryNote
+	for i := range order.DeliveryNotes {
+		if order.DeliveryNotes[i].ID == uint(noteID) {
+			note = &order.DeliveryNotes[i]
+			break
+		}
+	}
+	if note == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery note not found"})
+		return
+	}
+
+	data, err := renderDeliveryNote(loadDocumentProfile(), order, note)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate delivery note"})
+		return
+	}
+
+	writePDF(c, fmt.Sprintf("delivery_note_%s.pdf", note.DeliveryNumber), data)
+}
+
+// renderDeliveryNote builds the A4 delivery note. It lists quantities only; prices stay on the invoice.
+func renderDeliveryNote(profile models.CompanyProfile, order *models.SalesOrder, note *models.DeliveryNote) ([]byte, error) {
+	doc := newPDFDocument(profile, "DELIVERY NOTE", note.DeliveryNumber, note.ShippedAt)
+
+	doc.keyValues([][2]string{
+		{"Ship To:", order.CustomerName},
+		{"Address:", order.DeliveryAddress},
+		{"Phone:", order.CustomerPhone},
+		{"Order No:", order.OrderNumber},
+		{"Order Date:", order.OrderDate.Format("02 Jan 2006")},
+		{"Shipped:", note.ShippedAt.Format("02 Jan 2006 15:04")},
+		{"Carrier:", note.Carrier},
+		{"Tracking No:", note.TrackingNumber},
+		{"Shipped By:", note.User.Name},
+	})
+
+	ordered := make(map[uint]models.SalesOrderItem, len(order.Items))
+	for _, item := range order.Items {
+		ordered[item.ID] = item
+	}
+
+	rows := make([][]string, 0, len(note.Items))
+	for i, item := range note.Items {
+		line := ordered[item.SalesOrderItemID]
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			item.Product.SKU,
+			item.Product.Name,
+			strconv.Itoa(line.QuantityOrdered),
+			strconv.Itoa(item.Quantity),
+		})
+	}
+	doc.table(
+		[]string{"#", "SKU", "Description", "Ordered", "Shipped"},
+		[]float64{10, 32, 88, 25, 25},
+		[]string{"C", "L", "L", "R", "R"},
+		rows,
+	)
+
+	doc.section("Notes", note.Notes)
+	doc.section("Received By", "Name:\n\nSignature:\n\nDate:")
+
+	return doc.bytes()
+}
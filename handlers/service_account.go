@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/middleware"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// serviceAccountDomain is the email domain given to service accounts created without an email
+const serviceAccountDomain = "service-accounts.local"
+
+// nonSlugChars matches characters that can't appear in a generated service account email
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ServiceAccountRequest represents the request body for creating a service account
+type ServiceAccountRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Email string `json:"email" binding:"omitempty,email"` // Defaults to <name>@service-accounts.local
+	Role  string `json:"role" binding:"required"`
+}
+
+// CreateAPIKeyRequest represents the request body for issuing an API key
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=3650"` // Never expires when omitted
+}
+
+// loadServiceAccount loads a service account by the :id parameter
+func loadServiceAccount(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND is_service_account = ?", id, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// CreateServiceAccount creates a user that integrations act as through API keys (admin only)
+func CreateServiceAccount(c *gin.Context) {
+	var req ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if !middleware.CanGrantRole(c, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't assign a role with permissions you don't have"})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(req.Name), "-"), "-")
+		if slug == "" {
+			slug = "service"
+		}
+		email = slug + "@" + serviceAccountDomain
+	}
+
+	var existing int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+		return
+	}
+
+	// Service accounts never sign in with a password, so they get a random one nobody knows
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(random)), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+
+	user := models.User{
+		Email:            email,
+		Password:         string(hashedPassword),
+		Name:             req.Name,
+		Role:             req.Role,
+		IsActive:         true,
+		IsServiceAccount: true,
+	}
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "service_account", user.ID, nil, user) {
+		return
+	}
+
+	tx.Commit()
+
+	adminID, _ := c.Get("user_id")
+	middleware.LogActivity(c, adminID.(uint), "service_account_created", "users", user.ID, "Created service account "+user.Email)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Service account created successfully", "service_account": user})
+}
+
+// GetServiceAccounts lists service accounts with their number of active keys (admin only)
+func GetServiceAccounts(c *gin.Context) {
+	var accounts []models.User
+	if err := database.DB.Where("is_service_account = ?", true).Order("name ASC").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch service accounts"})
+		return
+	}
+
+	type keyCount struct {
+		UserID uint
+		Count  int64
+	}
+	var counts []keyCount
+	database.DB.Model(&models.APIKey{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).
+		Group("user_id").Scan(&counts)
+	activeKeys := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		activeKeys[count.UserID] = count.Count
+	}
+
+	result := make([]gin.H, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, gin.H{
+			"service_account": account,
+			"active_keys":     activeKeys[account.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"service_accounts": result})
+}
+
+// DeleteServiceAccount deletes a service account and revokes all of its keys (admin only)
+func DeleteServiceAccount(c *gin.Context) {
+	user, ok := loadServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API keys"})
+		return
+	}
+	if err := tx.Delete(user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete service account"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionDelete, "service_account", user.ID, user, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	adminID, _ := c.Get("user_id")
+	middleware.LogActivity(c, adminID.(uint), "service_account_deleted", "users", user.ID, "Deleted service account "+user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deleted successfully"})
+}
+
+// CreateAPIKey issues an API key for a service account. The key is returned once; only its hash is kept (admin only).
+func CreateAPIKey(c *gin.Context) {
+	user, ok := loadServiceAccount(c)
+	if !ok {
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Service account is inactive"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes, err := validatePermissions(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A scope the role doesn't grant would silently do nothing, so reject it up front
+	granted := middleware.RolePermissions(user.Role)
+	var missing []string
+	for _, scope := range scopes {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Role %s does not grant: %s", user.Role, strings.Join(missing, ", "))})
+		return
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	key := middleware.APIKeyPrefix + hex.EncodeToString(random)
+
+	adminID, _ := c.Get("user_id")
+	apiKey := models.APIKey{
+		UserID:      user.ID,
+		Name:        req.Name,
+		Prefix:      key[:len(middleware.APIKeyPrefix)+8],
+		KeyHash:     middleware.HashAPIKey(key),
+		CreatedByID: adminID.(uint),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+	for _, scope := range scopes {
+		apiKey.Scopes = append(apiKey.Scopes, models.APIKeyScope{Permission: scope})
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&apiKey).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "api_key", apiKey.ID, nil, apiKey) {
+		return
+	}
+
+	tx.Commit()
+
+	middleware.LogActivity(c, adminID.(uint), "api_key_created", "users", user.ID,
+		fmt.Sprintf("Created API key %s (%s) for %s with scopes %s", apiKey.Name, apiKey.Prefix, user.Email, strings.Join(scopes, ", ")))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created successfully. Store it now; it can't be shown again",
+		"api_key": apiKey,
+		"key":     key, // Only returned once; the database keeps a hash
+	})
+}
+
+// GetAPIKeys lists a service account's API keys; revoked and expired keys are included with all=true (admin only)
+func GetAPIKeys(c *gin.Context) {
+	user, ok := loadServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Preload("Scopes").Where("user_id = ?", user.ID)
+	if c.Query("all") != "true" {
+		query = query.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"service_account": user, "api_keys": keys})
+}
+
+// RevokeAPIKey revokes an API key immediately (admin only)
+func RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := database.DB.First(&apiKey, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if apiKey.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key is already revoked"})
+		return
+	}
+
+	before := audit.Snapshot(apiKey)
+
+	now := time.Now()
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	apiKey.RevokedAt = &now
+
+	if !recordAudit(c, tx, "revoke", "api_key", apiKey.ID, before, apiKey) {
+		return
+	}
+
+	tx.Commit()
+
+	adminID, _ := c.Get("user_id")
+	middleware.LogActivity(c, adminID.(uint), "api_key_revoked", "users", apiKey.UserID,
+		fmt.Sprintf("Revoked API key %s (%s)", apiKey.Name, apiKey.Prefix))
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully", "api_key": apiKey})
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/database"
+	"inventory_system/middleware"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultRefreshTokenHours is the refresh token lifetime unless REFRESH_TOKEN_TTL_HOURS is set
+const defaultRefreshTokenHours = 720
+
+// RefreshRequest represents the request body for refreshing an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents the request body for logging out
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"` // Revoke every session of the user, not just this one
+}
+
+// refreshTokenTTL returns how long a refresh token stays valid after it was last used
+func refreshTokenTTL() time.Duration {
+	if value := os.Getenv("REFRESH_TOKEN_TTL_HOURS"); value != "" {
+		if hours, err := strconv.Atoi(value); err == nil && hours > 0 {
+			return time.Duration(hours) * time.Hour
+		}
+	}
+	return defaultRefreshTokenHours * time.Hour
+}
+
+// startSession creates a session for the user and returns the login response with both tokens
+func startSession(c *gin.Context, user models.User) (*LoginResponse, error) {
+	refreshToken, refreshHash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.UserSession{
+		UserID:           user.ID,
+		RefreshTokenHash: refreshHash,
+		ExpiresAt:        now.Add(refreshTokenTTL()),
+		LastUsedAt:       now,
+		IPAddress:        c.ClientIP(),
+		UserAgent:        c.Request.UserAgent(),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	token, err := middleware.GenerateToken(user.ID, session.ID, user.Email, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(middleware.AccessTokenTTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+// revokeUserSessions revokes every active session of a user
+func revokeUserSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	presentedHash := hashSecretToken(strings.TrimSpace(req.RefreshToken))
+
+	var session models.UserSession
+	if err := database.DB.Preload("User").Where("refresh_token_hash = ?", presentedHash).First(&session).Error; err != nil {
+		// A rotated-out token being replayed means it leaked; end that session entirely
+		var reused models.UserSession
+		if database.DB.Where("previous_token_hash = ?", presentedHash).First(&reused).Error == nil {
+			database.DB.Model(&reused).Where("revoked_at IS NULL").Update("revoked_at", time.Now())
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+		return
+	}
+	if session.User.ID == 0 || !session.User.IsActive {
+		revokeUserSessions(database.DB, session.UserID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
+		return
+	}
+
+	refreshToken, refreshHash, err := newSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		return
+	}
+
+	// Conditional on the presented hash so two concurrent refreshes can't both succeed
+	result := database.DB.Model(&models.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, presentedHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  refreshHash,
+			"previous_token_hash": presentedHash,
+			"expires_at":          now.Add(refreshTokenTTL()),
+			"last_used_at":        now,
+			"ip_address":          c.ClientIP(),
+			"user_agent":          c.Request.UserAgent(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	token, err := middleware.GenerateToken(session.User.ID, session.ID, session.User.Email, session.User.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": LoginResponse{
+			Token:        token,
+			RefreshToken: refreshToken,
+			ExpiresIn:    int(middleware.AccessTokenTTL().Seconds()),
+			User:         session.User,
+		},
+	})
+}
+
+// Logout revokes the session identified by the refresh token or the bearer access token
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var session models.UserSession
+	found := false
+	if req.RefreshToken != "" {
+		found = database.DB.Where("refresh_token_hash = ?", hashSecretToken(strings.TrimSpace(req.RefreshToken))).First(&session).Error == nil
+	}
+	// Fall back to the access token, which still identifies its session
+	if !found {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			if claims, err := middleware.ParseToken(strings.Replace(authHeader, "Bearer ", "", 1)); err == nil && claims.SessionID != 0 {
+				found = database.DB.Where("id = ? AND user_id = ?", claims.SessionID, claims.UserID).First(&session).Error == nil
+			}
+		}
+	}
+	if !found {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No valid session to log out"})
+		return
+	}
+
+	var err error
+	if req.All {
+		err = revokeUserSessions(database.DB, session.UserID)
+	} else {
+		err = database.DB.Model(&session).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GetUserSessions lists a user's sessions; only active ones unless all=true (admin only)
+func GetUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", id)
+	if c.Query("all") != "true" {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var sessions []models.UserSession
+	if err := query.Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeUserSessions signs a user out everywhere (admin only)
+func RevokeUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := revokeUserSessions(tx, uint(id)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if !recordAudit(c, tx, "revoke_sessions", "user", uint(id), nil, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked successfully"})
+}
+
+// RevokeSession revokes a single session (admin only)
+func RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.UserSession{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Active session not found"})
+		return
+	}
+
+	if !recordAudit(c, tx, "revoke", "session", uint(id), nil, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
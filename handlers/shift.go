@@ -0,0 +1,588 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/money"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// drawerMethods are always listed on shift reports, in this order, even when nothing was taken
+var drawerMethods = []string{"cash", "card", "transfer"}
+
+// OpenShiftRequest represents the request body for opening a shift
+type OpenShiftRequest struct {
+	OpeningFloat decimal.Decimal `json:"opening_float"`
+	Notes        string          `json:"notes"`
+}
+
+// CashMovementRequest represents a pay-in or pay-out
+type CashMovementRequest struct {
+	Type   string          `json:"type" binding:"required,oneof=pay_in pay_out"`
+	Amount decimal.Decimal `json:"amount"`
+	Reason string          `json:"reason" binding:"required"`
+}
+
+// CloseShiftRequest represents the counted drawer at the end of a shift
+type CloseShiftRequest struct {
+	CountedCash *decimal.Decimal           `json:"counted_cash"`
+	Counted     map[string]decimal.Decimal `json:"counted"` // Optional totals for other methods, e.g. card slips
+	Notes       string                     `json:"notes"`
+}
+
+// ShiftMethodSummary is one payment method on an X or Z report
+type ShiftMethodSummary struct {
+	PaymentMethod string           `json:"payment_method"`
+	Sales         decimal.Decimal  `json:"sales"`    // Tendered for sales rung up in the shift
+	Payments      decimal.Decimal  `json:"payments"` // Down payments and payments on credit sales
+	PayIns        decimal.Decimal  `json:"pay_ins"`
+	PayOuts       decimal.Decimal  `json:"pay_outs"`
+	Expected      decimal.Decimal  `json:"expected"`
+	Counted       *decimal.Decimal `json:"counted"`
+	Variance      *decimal.Decimal `json:"variance"`
+}
+
+// ShiftReport is an X report (open shift, running totals) or Z report (closed shift, with counts)
+type ShiftReport struct {
+	Type          string               `json:"type"` // X or Z
+	Shift         models.Shift         `json:"shift"`
+	SaleCount     int64                `json:"sale_count"`
+	VoidedCount   int64                `json:"voided_count"`
+	GrossSales    decimal.Decimal      `json:"gross_sales"`
+	CreditSales   decimal.Decimal      `json:"credit_sales"` // Charged to customer accounts after down payments; nothing to count
+	OpeningFloat  decimal.Decimal      `json:"opening_float"`
+	PayIns        decimal.Decimal      `json:"pay_ins"`
+	PayOuts       decimal.Decimal      `json:"pay_outs"`
+	Methods       []ShiftMethodSummary `json:"methods"`
+	ExpectedCash  decimal.Decimal      `json:"expected_cash"`
+	CountedCash   *decimal.Decimal     `json:"counted_cash"`
+	CashVariance  *decimal.Decimal     `json:"cash_variance"`
+	TotalVariance decimal.Decimal      `json:"total_variance"` // Sum of the variances of every counted method
+}
+
+// shiftRequired reports whether sales can only be rung up during an open shift
+func shiftRequired() bool {
+	return os.Getenv("POS_REQUIRE_SHIFT") == "true"
+}
+
+// openShiftFor returns the user's open shift, or nil when they have none
+func openShiftFor(db *gorm.DB, userID uint) *models.Shift {
+	var shift models.Shift
+	if err := db.Where("user_id = ? AND status = ?", userID, "open").First(&shift).Error; err != nil {
+		return nil
+	}
+	return &shift
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// loadShift loads the shift in the :id parameter. Cashiers can only see their own shifts;
+// shifts.manage is needed for anyone else's.
+func loadShift(c *gin.Context) (*models.Shift, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shift ID"})
+		return nil, false
+	}
+
+	var shift models.Shift
+	if err := database.DB.Preload("User").Preload("ClosedBy").First(&shift, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+		return nil, false
+	}
+	if shift.UserID != c.GetUint("user_id") && !middleware.HasPermission(c, permissions.ShiftsManage) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+		return nil, false
+	}
+	return &shift, true
+}
+
+// buildShiftReport totals a shift's sales, payments and cash movements per payment method.
+// Voided and deleted sales, and payments against them, are left out. Amounts are summed exactly
+// in the database, so the report reconciles with the sales to the cent.
+func buildShiftReport(db *gorm.DB, shift models.Shift) (*ShiftReport, error) {
+	report := &ShiftReport{Type: "X", Shift: shift, OpeningFloat: shift.OpeningFloat}
+	if shift.Status == "closed" {
+		report.Type = "Z"
+	}
+
+	methods := make(map[string]*ShiftMethodSummary)
+	method := func(name string) *ShiftMethodSummary {
+		if summary, ok := methods[name]; ok {
+			return summary
+		}
+		summary := &ShiftMethodSummary{PaymentMethod: name}
+		methods[name] = summary
+		return summary
+	}
+	for _, name := range drawerMethods {
+		method(name)
+	}
+
+	var sales []struct {
+		PaymentMethod string
+		Count         int64
+		Total         decimal.Decimal
+	}
+	if err := db.Model(&models.Sale{}).
+		Select("payment_method, COUNT(*) AS count, COALESCE(SUM(total), 0) AS total").
+		Where("shift_id = ? AND status <> ?", shift.ID, "cancelled").
+		Group("payment_method").Scan(&sales).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range sales {
+		report.SaleCount += row.Count
+		report.GrossSales = report.GrossSales.Add(row.Total)
+		if row.PaymentMethod == "credit" {
+			report.CreditSales = report.CreditSales.Add(row.Total)
+		}
+	}
+
+	// Sales from before split tenders have no tender payments and count under their own method
+	var untendered []struct {
+		PaymentMethod string
+		Total         decimal.Decimal
+	}
+	if err := db.Model(&models.Sale{}).
+		Select("payment_method, COALESCE(SUM(total), 0) AS total").
+		Where("shift_id = ? AND status <> ? AND payment_method <> ?", shift.ID, "cancelled", "credit").
+		Where("NOT EXISTS (SELECT 1 FROM sale_payments sp WHERE sp.sale_id = sales.id AND sp.payment_type = ?)", "tender").
+		Group("payment_method").Scan(&untendered).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range untendered {
+		summary := method(row.PaymentMethod)
+		summary.Sales = summary.Sales.Add(row.Total)
+	}
+
+	if err := db.Model(&models.Sale{}).Where("shift_id = ? AND status = ?", shift.ID, "cancelled").Count(&report.VoidedCount).Error; err != nil {
+		return nil, err
+	}
+
+	var payments []struct {
+		PaymentMethod string
+		PaymentType   string
+		Total         decimal.Decimal
+	}
+	if err := db.Model(&models.SalePayment{}).
+		Select("sale_payments.payment_method, sale_payments.payment_type, COALESCE(SUM(sale_payments.amount), 0) AS total").
+		Joins("JOIN sales ON sales.id = sale_payments.sale_id AND sales.deleted_at IS NULL AND sales.status <> ?", "cancelled").
+		Where("sale_payments.shift_id = ?", shift.ID).
+		Group("sale_payments.payment_method, sale_payments.payment_type").Scan(&payments).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range payments {
+		if row.PaymentType == "tender" {
+			summary := method(row.PaymentMethod)
+			summary.Sales = summary.Sales.Add(row.Total)
+			continue
+		}
+		if row.PaymentType == "downpayment" {
+			report.CreditSales = report.CreditSales.Sub(row.Total)
+		}
+		name := row.PaymentMethod
+		// Older down payments were recorded against the credit method but were taken in cash
+		if name == "credit" {
+			name = "cash"
+		}
+		summary := method(name)
+		summary.Payments = summary.Payments.Add(row.Total)
+	}
+
+	var movements []struct {
+		Type  string
+		Total decimal.Decimal
+	}
+	if err := db.Model(&models.CashMovement{}).
+		Select("type, COALESCE(SUM(amount), 0) AS total").
+		Where("shift_id = ?", shift.ID).
+		Group("type").Scan(&movements).Error; err != nil {
+		return nil, err
+	}
+	cash := method("cash")
+	for _, row := range movements {
+		switch row.Type {
+		case "pay_in":
+			report.PayIns = report.PayIns.Add(row.Total)
+			cash.PayIns = cash.PayIns.Add(row.Total)
+		case "pay_out":
+			report.PayOuts = report.PayOuts.Add(row.Total)
+			cash.PayOuts = cash.PayOuts.Add(row.Total)
+		}
+	}
+
+	for _, summary := range methods {
+		summary.Expected = money.Sum(summary.Sales, summary.Payments, summary.PayIns).Sub(summary.PayOuts)
+	}
+	cash.Expected = cash.Expected.Add(shift.OpeningFloat)
+
+	// A closed shift reports what was counted and frozen at close
+	for _, total := range shift.Totals {
+		summary := method(total.PaymentMethod)
+		summary.Expected = total.Expected
+		summary.Counted = total.Counted
+		if total.Counted != nil {
+			variance := total.Variance
+			summary.Variance = &variance
+			report.TotalVariance = report.TotalVariance.Add(variance)
+		}
+	}
+
+	report.Methods = make([]ShiftMethodSummary, 0, len(methods))
+	for _, name := range drawerMethods {
+		report.Methods = append(report.Methods, *methods[name])
+		delete(methods, name)
+	}
+	var others []string
+	for name := range methods {
+		others = append(others, name)
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		report.Methods = append(report.Methods, *methods[name])
+	}
+
+	report.ExpectedCash = cash.Expected
+	report.CountedCash = cash.Counted
+	report.CashVariance = cash.Variance
+	return report, nil
+}
+
+// OpenShift opens a shift for the current user with a starting float
+func OpenShift(c *gin.Context) {
+	var req OpenShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OpeningFloat.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Opening float can't be negative"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+
+	tx := database.DB.Begin()
+
+	if existing := openShiftFor(tx, userID); existing != nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have an open shift", "shift": existing})
+		return
+	}
+
+	shift := models.Shift{
+		UserID:       userID,
+		Status:       "open",
+		OpeningFloat: money.Round(baseCurrency(), req.OpeningFloat),
+		OpenedAt:     time.Now(),
+		Notes:        req.Notes,
+	}
+	if err := tx.Create(&shift).Error; err != nil {
+		tx.Rollback()
+		// The unique index on open shifts catches a shift opened concurrently
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have an open shift"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open shift"})
+		return
+	}
+
+	if !recordAudit(c, tx, "open", "shift", shift.ID, nil, shift) {
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have an open shift"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open shift"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Shift opened successfully", "shift": shift})
+}
+
+// GetCurrentShift returns the current user's open shift with its X report
+func GetCurrentShift(c *gin.Context) {
+	shift := openShiftFor(database.DB, c.GetUint("user_id"))
+	if shift == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No open shift"})
+		return
+	}
+
+	report, err := buildShiftReport(database.DB, *shift)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build shift report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetShifts lists shifts; cashiers see their own, shifts.manage sees everyone's
+func GetShifts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.Shift{}).Preload("User")
+
+	if !middleware.HasPermission(c, permissions.ShiftsManage) {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	} else if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("opened_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("opened_at <= ?", endDate+" 23:59:59")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var shifts []models.Shift
+	if err := query.Order("opened_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&shifts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shifts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shifts": shifts,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// GetShiftReport returns the X report of an open shift or the Z report of a closed one
+func GetShiftReport(c *gin.Context) {
+	shift, ok := loadShift(c)
+	if !ok {
+		return
+	}
+	database.DB.Where("shift_id = ?", shift.ID).Find(&shift.Totals)
+	database.DB.Preload("User").Where("shift_id = ?", shift.ID).Order("created_at ASC").Find(&shift.CashMovements)
+
+	report, err := buildShiftReport(database.DB, *shift)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build shift report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// AddCashMovement records a pay-in or pay-out on an open shift
+func AddCashMovement(c *gin.Context) {
+	shift, ok := loadShift(c)
+	if !ok {
+		return
+	}
+
+	var req CashMovementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than 0"})
+		return
+	}
+	if shift.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shift is closed"})
+		return
+	}
+
+	movement := models.CashMovement{
+		ShiftID: shift.ID,
+		UserID:  c.GetUint("user_id"),
+		Type:    req.Type,
+		Amount:  money.Round(baseCurrency(), req.Amount),
+		Reason:  strings.TrimSpace(req.Reason),
+	}
+
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&movement).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cash movement"})
+		return
+	}
+
+	if !recordAudit(c, tx, audit.ActionCreate, "cash_movement", movement.ID, nil, movement) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Cash movement recorded successfully", "cash_movement": movement})
+}
+
+// CloseShift closes a shift with the counted drawer and returns its Z report
+func CloseShift(c *gin.Context) {
+	shift, ok := loadShift(c)
+	if !ok {
+		return
+	}
+
+	var req CloseShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.CountedCash == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "counted_cash is required"})
+		return
+	}
+	if req.CountedCash.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Counted amounts can't be negative"})
+		return
+	}
+	for name, amount := range req.Counted {
+		if name == "cash" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send the counted cash as counted_cash"})
+			return
+		}
+		if amount.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Counted amounts can't be negative"})
+			return
+		}
+	}
+	if shift.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shift is already closed"})
+		return
+	}
+
+	before := audit.Snapshot(shift)
+
+	tx := database.DB.Begin()
+
+	report, err := buildShiftReport(tx, *shift)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build shift report"})
+		return
+	}
+
+	currency := baseCurrency()
+	counted := map[string]decimal.Decimal{"cash": money.Round(currency, *req.CountedCash)}
+	for name, amount := range req.Counted {
+		counted[name] = money.Round(currency, amount)
+	}
+
+	for _, summary := range report.Methods {
+		total := models.ShiftTotal{
+			ShiftID:       shift.ID,
+			PaymentMethod: summary.PaymentMethod,
+			Expected:      summary.Expected,
+		}
+		if amount, ok := counted[summary.PaymentMethod]; ok {
+			total.Counted = &amount
+			total.Variance = amount.Sub(summary.Expected)
+		}
+		shift.Totals = append(shift.Totals, total)
+	}
+	for name, amount := range counted {
+		found := false
+		for _, total := range shift.Totals {
+			if total.PaymentMethod == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			// Counted a method nothing was expected for, so all of it is variance
+			shift.Totals = append(shift.Totals, models.ShiftTotal{ShiftID: shift.ID, PaymentMethod: name, Counted: &amount, Variance: amount})
+		}
+	}
+
+	now := time.Now()
+	closedByID := c.GetUint("user_id")
+	countedCash := counted["cash"]
+	cashVariance := countedCash.Sub(report.ExpectedCash)
+	notes := shift.Notes
+	if req.Notes != "" {
+		notes = strings.TrimSpace(notes + "\n" + req.Notes)
+	}
+
+	// The status condition stops two concurrent closes from both succeeding
+	result := tx.Model(&models.Shift{}).Where("id = ? AND status = ?", shift.ID, "open").Updates(map[string]interface{}{
+		"status":        "closed",
+		"closed_at":     now,
+		"closed_by_id":  closedByID,
+		"expected_cash": report.ExpectedCash,
+		"counted_cash":  countedCash,
+		"cash_variance": cashVariance,
+		"notes":         notes,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close shift"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shift is already closed"})
+		return
+	}
+
+	if err := tx.Create(&shift.Totals).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save shift totals"})
+		return
+	}
+
+	shift.Status = "closed"
+	shift.ClosedAt = &now
+	shift.ClosedByID = &closedByID
+	shift.ExpectedCash = report.ExpectedCash
+	shift.CountedCash = &countedCash
+	shift.CashVariance = cashVariance
+	shift.Notes = notes
+
+	if !recordAudit(c, tx, "close", "shift", shift.ID, before, shift) {
+		return
+	}
+
+	report, err = buildShiftReport(tx, *shift)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build shift report"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shift closed successfully", "report": report})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"inventory_system/audit"
 	"inventory_system/database"
+	"inventory_system/middleware"
 	"inventory_system/models"
+	"inventory_system/permissions"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -26,7 +29,8 @@ func GetSuppliers(c *gin.Context) {
 	})
 }
 
-// GetSupplier returns a specific supplier by ID
+// GetSupplier returns a specific supplier by ID, with its scorecard for the last
+// defaultScorecardDays days when the user may view purchase orders
 func GetSupplier(c *gin.Context) {
 	id := c.Param("id")
 	var supplier models.Supplier
@@ -39,10 +43,24 @@ func GetSupplier(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"success": true,
 		"data":    supplier,
-	})
+	}
+	if middleware.HasPermission(c, permissions.POView) {
+		end := startOfToday().AddDate(0, 0, 1)
+		scorecard, err := buildSupplierScorecard(database.DB, supplier, end.AddDate(0, 0, -defaultScorecardDays), end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to compute supplier scorecard",
+			})
+			return
+		}
+		response["scorecard"] = scorecard
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // CreateSupplier creates a new supplier
@@ -66,10 +84,26 @@ func CreateSupplier(c *gin.Context) {
 		return
 	}
 
+	// An empty currency means the supplier invoices in the base currency
+	if supplier.Currency != "" {
+		currency, err := normalizeCurrency(supplier.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Currency must be a three-letter ISO code",
+			})
+			return
+		}
+		supplier.Currency = currency
+	}
+
 	// Set default values
 	supplier.IsActive = true
 
-	if err := database.DB.Create(&supplier).Error; err != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&supplier).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to create supplier: " + err.Error(),
@@ -77,6 +111,12 @@ func CreateSupplier(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionCreate, "supplier", supplier.ID, nil, supplier) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Supplier created successfully",
@@ -117,6 +157,20 @@ func UpdateSupplier(c *gin.Context) {
 		return
 	}
 
+	if updateData.Currency != "" {
+		currency, err := normalizeCurrency(updateData.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Currency must be a three-letter ISO code",
+			})
+			return
+		}
+		updateData.Currency = currency
+	}
+
+	before := audit.Snapshot(supplier)
+
 	// Update fields
 	supplier.Name = updateData.Name
 	supplier.Email = updateData.Email
@@ -124,8 +178,12 @@ func UpdateSupplier(c *gin.Context) {
 	supplier.Address = updateData.Address
 	supplier.ContactPerson = updateData.ContactPerson
 	supplier.Website = updateData.Website
+	supplier.Currency = updateData.Currency
 
-	if err := database.DB.Save(&supplier).Error; err != nil {
+	tx := database.DB.Begin()
+
+	if err := tx.Save(&supplier).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to update supplier: " + err.Error(),
@@ -133,6 +191,12 @@ func UpdateSupplier(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionUpdate, "supplier", supplier.ID, before, supplier) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Supplier updated successfully",
@@ -154,9 +218,14 @@ func DeleteSupplier(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(supplier)
+
+	tx := database.DB.Begin()
+
 	// Soft delete by setting is_active to false
 	supplier.IsActive = false
-	if err := database.DB.Save(&supplier).Error; err != nil {
+	if err := tx.Save(&supplier).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to delete supplier: " + err.Error(),
@@ -164,6 +233,12 @@ func DeleteSupplier(c *gin.Context) {
 		return
 	}
 
+	if !recordAudit(c, tx, audit.ActionDelete, "supplier", supplier.ID, before, supplier) {
+		return
+	}
+
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Supplier deleted successfully",
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inventory_system/database"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// defaultScorecardDays is the period a scorecard covers when no dates are given
+const defaultScorecardDays = 90
+
+// SupplierScorecard measures how a supplier performed on the purchase orders placed in a period.
+// Rates are percentages and are null when there is nothing to measure.
+type SupplierScorecard struct {
+	SupplierID             uint                  `json:"supplier_id"`
+	SupplierName           string                `json:"supplier_name"`
+	StartDate              string                `json:"start_date"`
+	EndDate                string                `json:"end_date"`
+	Orders                 int                   `json:"orders"`
+	OrdersWithExpectedDate int                   `json:"orders_with_expected_date"`
+	OnTimeOrders           int                   `json:"on_time_orders"`
+	OnTimeRate             *float64              `json:"on_time_rate"` // Received by the expected date
+	QuantityOrdered        int                   `json:"quantity_ordered"`
+	QuantityReceived       int                   `json:"quantity_received"`
+	FillRate               *float64              `json:"fill_rate"` // Received of ordered, on orders received or past their expected date
+	AverageLeadTimeDays    *float64              `json:"average_lead_time_days"`
+	Returns                int                   `json:"returns"`
+	QuantityReturned       int                   `json:"quantity_returned"`
+	ReturnedValue          decimal.Decimal       `json:"returned_value" cost:"true"`
+	ReturnRate             *float64              `json:"return_rate"` // Returned of received
+	PriceTrend             *float64              `json:"price_trend"` // Average change in unit cost of products bought more than once
+	PriceChanges           []SupplierPriceChange `json:"price_changes"`
+}
+
+// SupplierPriceChange is how the unit cost of one product moved over the period
+type SupplierPriceChange struct {
+	ProductID uint            `json:"product_id"`
+	SKU       string          `json:"sku"`
+	Name      string          `json:"name"`
+	Purchases int             `json:"purchases"`
+	FirstCost decimal.Decimal `json:"first_cost" cost:"true"`
+	LastCost  decimal.Decimal `json:"last_cost" cost:"true"`
+	Change    *float64        `json:"change"` // Percentage from first to last cost
+}
+
+// percentage returns part as a percentage of whole rounded to two decimals, or nil without a whole
+func percentage(part, whole float64) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	value := math.Round(part/whole*10000) / 100
+	return &value
+}
+
+// scorecardPeriod reads the optional start_date and end_date parameters. The period defaults to the
+// last defaultScorecardDays days; the returned end is exclusive.
+func scorecardPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	end := startOfToday().AddDate(0, 0, 1)
+	if endDate := c.Query("end_date"); endDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format"})
+			return time.Time{}, time.Time{}, false
+		}
+		end = parsed.AddDate(0, 0, 1)
+	}
+
+	start := end.AddDate(0, 0, -defaultScorecardDays)
+	if startDate := c.Query("start_date"); startDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format"})
+			return time.Time{}, time.Time{}, false
+		}
+		start = parsed
+	}
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be after end_date"})
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+// buildSupplierScorecard scores the supplier's purchase orders dated in [start, end)
+func buildSupplierScorecard(db *gorm.DB, supplier models.Supplier, start, end time.Time) (SupplierScorecard, error) {
+	scorecards, err := buildSupplierScorecards(db, []models.Supplier{supplier}, start, end)
+	if err != nil {
+		return SupplierScorecard{}, err
+	}
+	return scorecards[0], nil
+}
+
+// buildSupplierScorecards scores each supplier's purchase orders dated in [start, end), in the order
+// of suppliers. Orders and returns are loaded for all suppliers at once.
+func buildSupplierScorecards(db *gorm.DB, suppliers []models.Supplier, start, end time.Time) ([]SupplierScorecard, error) {
+	supplierIDs := make([]uint, len(suppliers))
+	for i, supplier := range suppliers {
+		supplierIDs[i] = supplier.ID
+	}
+
+	var orders []models.PurchaseOrder
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).Preload("Items.Product").
+		Where("supplier_id IN ? AND order_date >= ? AND order_date < ?", supplierIDs, start, end).
+		Order("order_date ASC, id ASC").Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ordersBySupplier := make(map[uint][]models.PurchaseOrder)
+	orderIDs := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		ordersBySupplier[order.SupplierID] = append(ordersBySupplier[order.SupplierID], order)
+		orderIDs = append(orderIDs, order.ID)
+	}
+
+	type supplierReturns struct {
+		SupplierID uint
+		Count      int
+		Total      decimal.Decimal
+	}
+	returnsBySupplier := make(map[uint]supplierReturns)
+	if len(orderIDs) > 0 {
+		var returns []supplierReturns
+		err := db.Model(&models.PurchaseReturn{}).
+			Select("supplier_id, COUNT(*) AS count, COALESCE(SUM(total), 0) AS total").
+			Where("purchase_order_id IN ?", orderIDs).Group("supplier_id").Scan(&returns).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range returns {
+			returnsBySupplier[r.SupplierID] = r
+		}
+	}
+
+	scorecards := make([]SupplierScorecard, len(suppliers))
+	for i, supplier := range suppliers {
+		scorecards[i] = scoreSupplierOrders(supplier, ordersBySupplier[supplier.ID], start, end)
+		returns := returnsBySupplier[supplier.ID]
+		scorecards[i].Returns = returns.Count
+		scorecards[i].ReturnedValue = returns.Total
+	}
+	return scorecards, nil
+}
+
+// scoreSupplierOrders works out a scorecard from the supplier's orders in the period, oldest first
+func scoreSupplierOrders(supplier models.Supplier, orders []models.PurchaseOrder, start, end time.Time) SupplierScorecard {
+	scorecard := SupplierScorecard{
+		SupplierID:   supplier.ID,
+		SupplierName: supplier.Name,
+		StartDate:    start.Format("2006-01-02"),
+		EndDate:      end.AddDate(0, 0, -1).Format("2006-01-02"),
+		PriceChanges: []SupplierPriceChange{},
+	}
+
+	today := startOfToday()
+	var leadDays float64
+	var received, dueOrdered, dueReceived int
+	prices := make(map[uint]*SupplierPriceChange)
+	var productOrder []uint
+	for _, order := range orders {
+		scorecard.Orders++
+
+		if order.ReceivedDate != nil {
+			received++
+			leadDays += order.ReceivedDate.Sub(order.OrderDate).Hours() / 24
+			if order.ExpectedDate != nil {
+				scorecard.OrdersWithExpectedDate++
+				// Anything arriving on the promised day counts as on time
+				if order.ReceivedDate.Before(order.ExpectedDate.AddDate(0, 0, 1)) {
+					scorecard.OnTimeOrders++
+				}
+			}
+		}
+
+		// Orders not yet received and not yet expected don't count against the fill rate
+		due := order.ReceivedDate != nil || (order.ExpectedDate != nil && order.ExpectedDate.Before(today))
+
+		for _, item := range order.Items {
+			scorecard.QuantityOrdered += item.QuantityOrdered
+			scorecard.QuantityReceived += item.QuantityReceived
+			scorecard.QuantityReturned += item.QuantityReturned
+			if due {
+				dueOrdered += item.QuantityOrdered
+				dueReceived += item.QuantityReceived
+			}
+
+			change, ok := prices[item.ProductID]
+			if !ok {
+				change = &SupplierPriceChange{
+					ProductID: item.ProductID,
+					SKU:       item.Product.SKU,
+					Name:      item.Product.Name,
+					FirstCost: item.UnitCost,
+				}
+				prices[item.ProductID] = change
+				productOrder = append(productOrder, item.ProductID)
+			}
+			change.Purchases++
+			change.LastCost = item.UnitCost
+		}
+	}
+
+	scorecard.OnTimeRate = percentage(float64(scorecard.OnTimeOrders), float64(scorecard.OrdersWithExpectedDate))
+	scorecard.FillRate = percentage(float64(dueReceived), float64(dueOrdered))
+	scorecard.ReturnRate = percentage(float64(scorecard.QuantityReturned), float64(scorecard.QuantityReceived))
+	if received > 0 {
+		average := math.Round(leadDays/float64(received)*10) / 10
+		scorecard.AverageLeadTimeDays = &average
+	}
+
+	var changeSum float64
+	var changed int
+	for _, productID := range productOrder {
+		change := prices[productID]
+		if change.Purchases > 1 {
+			change.Change = percentage(change.LastCost.Sub(change.FirstCost).InexactFloat64(), change.FirstCost.InexactFloat64())
+			if change.Change != nil {
+				changeSum += *change.Change
+				changed++
+			}
+		}
+		scorecard.PriceChanges = append(scorecard.PriceChanges, *change)
+	}
+	if changed > 0 {
+		trend := math.Round(changeSum/float64(changed)*100) / 100
+		scorecard.PriceTrend = &trend
+	}
+
+	return scorecard
+}
+
+// GetSupplierScorecard returns a supplier's on-time, fill, lead time, return and price performance
+// over a period (start_date, end_date)
+func GetSupplierScorecard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	start, end, ok := scorecardPeriod(c)
+	if !ok {
+		return
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	scorecard, err := buildSupplierScorecard(database.DB, supplier, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute supplier scorecard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, scorecard)
+}
+
+// ExportSupplierScorecards exports one scorecard row per supplier over a period
+func ExportSupplierScorecards(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	start, end, ok := scorecardPeriod(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&models.Supplier{})
+	if c.DefaultQuery("active", "true") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	headers := columns(
+		"Supplier ID", "Supplier", "Start Date", "End Date", "Orders", "On-Time Rate %", "Fill Rate %",
+		"Avg Lead Time (days)", "Returns", "Return Rate %", "Price Trend %",
+	)
+	writer, err := newExportWriter(c, format, "supplier_scorecards", headers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	// Empty cells where there is nothing to measure
+	optional := func(value *float64) interface{} {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	var suppliers []models.Supplier
+	result := query.FindInBatches(&suppliers, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		scorecards, err := buildSupplierScorecards(database.DB, suppliers, start, end)
+		if err != nil {
+			return err
+		}
+		for i, supplier := range suppliers {
+			scorecard := scorecards[i]
+			row := []interface{}{
+				supplier.ID, supplier.Name, scorecard.StartDate, scorecard.EndDate, scorecard.Orders,
+				optional(scorecard.OnTimeRate), optional(scorecard.FillRate), optional(scorecard.AverageLeadTimeDays),
+				scorecard.Returns, optional(scorecard.ReturnRate), optional(scorecard.PriceTrend),
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	finishExport(c, writer, result.Error)
+}
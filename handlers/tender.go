@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// tenderMethods are the ways a customer can hand over money; credit is a sale on account, not a tender
+var tenderMethods = []string{"cash", "card", "transfer"}
+
+// TenderRequest is one payment towards a sale, e.g. part cash and part card
+type TenderRequest struct {
+	Method string          `json:"method" binding:"required"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// tenderLine is a tender after merging per method and giving change
+type tenderLine struct {
+	Method   string
+	Amount   decimal.Decimal // Applied to the sale
+	Tendered decimal.Decimal // Handed over; more than Amount only for cash with change
+}
+
+// TenderTotal is revenue taken with one tender
+type TenderTotal struct {
+	PaymentMethod string          `json:"payment_method"`
+	Count         int64           `json:"count"` // Sales that used this tender
+	Total         decimal.Decimal `json:"total"`
+}
+
+// validateTenders checks tender methods and amounts before any work is done
+func validateTenders(tenders []TenderRequest) error {
+	for _, tender := range tenders {
+		if !slices.Contains(tenderMethods, tender.Method) {
+			return fmt.Errorf("invalid tender method %q", tender.Method)
+		}
+		if !tender.Amount.IsPositive() {
+			return fmt.Errorf("%s tender amount must be greater than 0", tender.Method)
+		}
+	}
+	return nil
+}
+
+// mergeTenders adds up tenders per method, keeping the order in which methods first appear
+func mergeTenders(currency string, tenders []TenderRequest) []tenderLine {
+	var lines []tenderLine
+	for _, tender := range tenders {
+		amount := money.Round(currency, tender.Amount)
+		merged := false
+		for i := range lines {
+			if lines[i].Method == tender.Method {
+				lines[i].Tendered = lines[i].Tendered.Add(amount)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			lines = append(lines, tenderLine{Method: tender.Method, Tendered: amount})
+		}
+	}
+	for i := range lines {
+		lines[i].Amount = lines[i].Tendered
+	}
+	return lines
+}
+
+// resolveSaleTenders works out how a sale of total is paid and the change to give. A paid sale
+// needs tenders covering the total, and only cash can be overpaid. For a credit sale the tenders
+// are the down payment and may not exceed the total. Amounts are rounded to currency.
+func resolveSaleTenders(request SaleRequest, currency string, total decimal.Decimal) ([]tenderLine, decimal.Decimal, error) {
+	total = money.Round(currency, total)
+
+	if request.PaymentMethod == "credit" {
+		if len(request.Tenders) == 0 {
+			downPayment := money.Round(currency, request.DownPayment)
+			if !downPayment.IsPositive() {
+				return nil, decimal.Zero, nil
+			}
+			if downPayment.GreaterThan(total) {
+				return nil, decimal.Zero, fmt.Errorf("down payment of %s exceeds the sale total of %s",
+					money.Format(currency, downPayment), money.Format(currency, total))
+			}
+			// Down payments without tenders are taken in cash
+			return []tenderLine{{Method: "cash", Amount: downPayment, Tendered: downPayment}}, decimal.Zero, nil
+		}
+
+		lines := mergeTenders(currency, request.Tenders)
+		paid := decimal.Zero
+		for _, line := range lines {
+			paid = paid.Add(line.Amount)
+		}
+		if paid.GreaterThan(total) {
+			return nil, decimal.Zero, fmt.Errorf("down payment tenders of %s exceed the sale total of %s",
+				money.Format(currency, paid), money.Format(currency, total))
+		}
+		return lines, decimal.Zero, nil
+	}
+
+	if len(request.Tenders) == 0 {
+		return []tenderLine{{Method: request.PaymentMethod, Amount: total, Tendered: total}}, decimal.Zero, nil
+	}
+
+	lines := mergeTenders(currency, request.Tenders)
+	tendered, cash := decimal.Zero, decimal.Zero
+	cashIndex := -1
+	for i, line := range lines {
+		tendered = tendered.Add(line.Tendered)
+		if line.Method == "cash" {
+			cash = line.Tendered
+			cashIndex = i
+		}
+	}
+
+	if tendered.LessThan(total) {
+		return nil, decimal.Zero, fmt.Errorf("tenders of %s don't cover the sale total of %s",
+			money.Format(currency, tendered), money.Format(currency, total))
+	}
+
+	change := tendered.Sub(total)
+	if change.IsPositive() {
+		// Change comes out of the cash tendered; card and transfer amounts must be exact
+		if cashIndex < 0 || change.GreaterThanOrEqual(cash) {
+			return nil, decimal.Zero, fmt.Errorf("tenders exceed the sale total by %s; only cash can be overpaid", money.Format(currency, change))
+		}
+		lines[cashIndex].Amount = cash.Sub(change)
+	}
+	return lines, change, nil
+}
+
+// salePaymentMethod is the method stored on the sale: the single tender used, "split" for several, or "credit"
+func salePaymentMethod(request SaleRequest, lines []tenderLine) string {
+	if request.PaymentMethod == "credit" {
+		return "credit"
+	}
+	if len(lines) == 1 {
+		return lines[0].Method
+	}
+	return "split"
+}
+
+// saleTenders breaks a sale down by tender. Sales from before split tenders have no tender
+// payments and count under their own method; what a credit sale didn't take up front counts as credit.
+func saleTenders(sale models.Sale) []TenderTotal {
+	var tenders []TenderTotal
+	add := func(method string, amount decimal.Decimal) {
+		for i := range tenders {
+			if tenders[i].PaymentMethod == method {
+				tenders[i].Total = tenders[i].Total.Add(amount)
+				return
+			}
+		}
+		tenders = append(tenders, TenderTotal{PaymentMethod: method, Count: 1, Total: amount})
+	}
+
+	taken := decimal.Zero
+	hasTender := false
+	for _, payment := range sale.Payments {
+		if payment.PaymentType != "tender" && payment.PaymentType != "downpayment" {
+			continue
+		}
+		if payment.PaymentType == "tender" {
+			hasTender = true
+		}
+		if payment.PaymentMethod == "credit" {
+			continue
+		}
+		add(payment.PaymentMethod, payment.Amount)
+		taken = taken.Add(payment.Amount)
+	}
+
+	switch {
+	case sale.PaymentMethod == "credit":
+		if remaining := sale.Total.Sub(taken); !remaining.IsZero() {
+			add("credit", remaining)
+		}
+	case !hasTender:
+		add(sale.PaymentMethod, sale.Total)
+	}
+	return tenders
+}
+
+// formatTenders describes a sale's tenders for exports, e.g. "cash 50.00; card 30.00"
+func formatTenders(sale models.Sale) string {
+	tenders := saleTenders(sale)
+	parts := make([]string, 0, len(tenders))
+	for _, tender := range tenders {
+		parts = append(parts, fmt.Sprintf("%s %s", tender.PaymentMethod, tender.Total.StringFixed(2)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// filterSalesByTender narrows a sales query to sales paid at least partly with method
+func filterSalesByTender(query *gorm.DB, method string) *gorm.DB {
+	return query.Where(
+		"sales.payment_method = ? OR sales.id IN (SELECT sale_id FROM sale_payments WHERE payment_type IN ('tender', 'downpayment') AND payment_method = ?)",
+		method, method,
+	)
+}
+
+// revenueByTender totals the revenue of the sales matched by salesQuery per tender, using the same
+// rules as saleTenders
+func revenueByTender(db *gorm.DB, salesQuery *gorm.DB) ([]TenderTotal, error) {
+	saleIDs := salesQuery.Select("sales.id")
+
+	var totals []TenderTotal
+	err := db.Raw(`
+		SELECT payment_method, COUNT(DISTINCT sale_id) AS count, COALESCE(SUM(amount), 0) AS total
+		FROM (
+			SELECT sp.sale_id, sp.payment_method, sp.amount
+			FROM sale_payments sp
+			WHERE sp.sale_id IN (?) AND sp.payment_type IN ('tender', 'downpayment') AND sp.payment_method <> 'credit'
+			UNION ALL
+			SELECT s.id, s.payment_method, s.total - COALESCE((
+				SELECT SUM(sp.amount) FROM sale_payments sp
+				WHERE sp.sale_id = s.id AND sp.payment_type IN ('tender', 'downpayment') AND sp.payment_method <> 'credit'
+			), 0)
+			FROM sales s
+			WHERE s.id IN (?) AND (s.payment_method = 'credit' OR NOT EXISTS (
+				SELECT 1 FROM sale_payments sp WHERE sp.sale_id = s.id AND sp.payment_type = 'tender'
+			))
+		) tenders
+		WHERE amount <> 0
+		GROUP BY payment_method
+		ORDER BY total DESC`, saleIDs, saleIDs).Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// documentTotal is the total of a sale, quotation or order: its subtotal less the discount plus tax.
+// All three are already rounded to the document's currency, so the total is too.
+func documentTotal(subtotal, discount, tax decimal.Decimal) decimal.Decimal {
+	return subtotal.Sub(discount).Add(tax)
+}
+
+// splitPayment returns what is paid and what is left due of total once paid has been paid towards
+// it. Paying more than total settles it without leaving a negative amount due.
+func splitPayment(total, paid decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	paid = decimal.Min(paid, total)
+	return paid, total.Sub(paid)
+}
+
+// applyPayment adds a payment of amount to what is paid and takes it off what is due. Callers reject
+// amounts over the amount due; anything over is ignored so paid plus due stays the total.
+func applyPayment(paid, due, amount decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	amount = decimal.Min(amount, due)
+	return paid.Add(amount), due.Sub(amount)
+}
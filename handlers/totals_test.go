@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"math/rand"
+	"testing"
+
+	"inventory_system/models"
+	"inventory_system/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// totalsCurrencies covers every currency scale: none, two and three decimals
+var totalsCurrencies = []string{"JPY", "USD", "IDR", "KWD"}
+
+// randomAmount returns an amount of at most max minor units in currency
+func randomAmount(rng *rand.Rand, currency string, max int64) decimal.Decimal {
+	return decimal.New(rng.Int63n(max+1), -money.Scale(currency))
+}
+
+// randomShare returns a random part of amount, rounded to currency
+func randomShare(rng *rand.Rand, currency string, amount decimal.Decimal) decimal.Decimal {
+	return money.Round(currency, amount.Mul(decimal.NewFromFloat32(rng.Float32())))
+}
+
+// randomUnitPrice returns a unit price with four decimals, as prices and costs are stored
+func randomUnitPrice(rng *rand.Rand) decimal.Decimal {
+	return money.RoundUnit(decimal.New(rng.Int63n(100_000_000), -money.UnitScale))
+}
+
+// randomSaleItems rings up one to ten lines the way createSaleTx does
+func randomSaleItems(rng *rand.Rand, currency string) []models.SaleItem {
+	items := make([]models.SaleItem, 1+rng.Intn(10))
+	for i := range items {
+		items[i].Quantity = 1 + rng.Intn(100)
+		items[i].Price = randomUnitPrice(rng)
+		items[i].Total = money.LineTotal(currency, items[i].Quantity, items[i].Price)
+	}
+	return items
+}
+
+// randomPurchaseOrderItems orders one to ten lines the way createPurchaseOrderTx does
+func randomPurchaseOrderItems(rng *rand.Rand, currency string) []models.PurchaseOrderItem {
+	items := make([]models.PurchaseOrderItem, 1+rng.Intn(10))
+	for i := range items {
+		items[i].QuantityOrdered = 1 + rng.Intn(100)
+		items[i].UnitCost = randomUnitPrice(rng)
+		items[i].Total = money.LineTotal(currency, items[i].QuantityOrdered, items[i].UnitCost)
+	}
+	return items
+}
+
+// randomSaleRequest picks how a sale of total is paid: a single method, split tenders with cash change,
+// or on credit with a down payment given as an amount or as tenders
+func randomSaleRequest(rng *rand.Rand, currency string, total decimal.Decimal) SaleRequest {
+	switch rng.Intn(4) {
+	case 0:
+		return SaleRequest{PaymentMethod: tenderMethods[rng.Intn(len(tenderMethods))]}
+	case 1:
+		card := randomShare(rng, currency, total)
+		if !total.Sub(card).IsPositive() {
+			return SaleRequest{PaymentMethod: "cash"}
+		}
+		tenders := []TenderRequest{{Method: "cash", Amount: total.Sub(card).Add(randomAmount(rng, currency, 1_000))}}
+		if card.IsPositive() {
+			tenders = append(tenders, TenderRequest{Method: "card", Amount: card})
+		}
+		return SaleRequest{Tenders: tenders}
+	case 2:
+		return SaleRequest{PaymentMethod: "credit", PaymentDays: 30, DownPayment: randomShare(rng, currency, total)}
+	default:
+		request := SaleRequest{PaymentMethod: "credit", PaymentDays: 30}
+		for _, method := range []string{"card", "cash", "card"} {
+			if amount := randomShare(rng, currency, total.Div(decimal.NewFromInt(3))); amount.IsPositive() {
+				request.Tenders = append(request.Tenders, TenderRequest{Method: method, Amount: amount})
+			}
+		}
+		return request
+	}
+}
+
+// checkAtScale fails unless every amount has at most the decimals of currency
+func checkAtScale(t *testing.T, currency string, amounts ...decimal.Decimal) {
+	t.Helper()
+	scale := money.Scale(currency)
+	for _, amount := range amounts {
+		if !amount.Round(scale).Equal(amount) {
+			t.Fatalf("%s: %s has more than %d decimals", currency, amount, scale)
+		}
+	}
+}
+
+// checkSettled fails unless paid and due are at the scale of currency, neither is negative and they add up to total
+func checkSettled(t *testing.T, currency string, total, paid, due decimal.Decimal) {
+	t.Helper()
+	checkAtScale(t, currency, paid, due)
+	if due.IsNegative() || paid.IsNegative() {
+		t.Fatalf("%s: paid %s or due %s is negative", currency, paid, due)
+	}
+	if !paid.Add(due).Equal(total) {
+		t.Fatalf("%s: paid %s + due %s != total %s", currency, paid, due, total)
+	}
+}
+
+// checkSalePayments fails unless the sale's payments add up to what it has been paid
+func checkSalePayments(t *testing.T, currency string, sale models.Sale, payments []models.SalePayment) {
+	t.Helper()
+	paid := decimal.Zero
+	for _, payment := range payments {
+		checkAtScale(t, currency, payment.Amount, payment.Tendered)
+		paid = paid.Add(payment.Amount)
+	}
+	if !paid.Equal(sale.AmountPaid) {
+		t.Fatalf("%s: payments of %s recorded for %s paid", currency, paid, sale.AmountPaid)
+	}
+	checkSettled(t, currency, sale.Total, sale.AmountPaid, sale.AmountDue)
+}
+
+// nextInstalment returns a random payment of at most due, paying it off every so often
+func nextInstalment(rng *rand.Rand, currency string, due decimal.Decimal) decimal.Decimal {
+	amount := randomShare(rng, currency, due)
+	if !amount.IsPositive() || rng.Intn(4) == 0 {
+		return due
+	}
+	return amount
+}
+
+func TestSettleSaleProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, currency := range totalsCurrencies {
+		for i := 0; i < 500; i++ {
+			items := randomSaleItems(rng, currency)
+			lineTotals := make([]decimal.Decimal, len(items))
+			for j, item := range items {
+				lineTotals[j] = item.Total
+			}
+			subtotal := money.Sum(lineTotals...)
+			sale := models.Sale{
+				Discount: randomShare(rng, currency, subtotal),
+				Tax:      randomAmount(rng, currency, 1_000_000),
+			}
+			total := subtotal.Sub(sale.Discount).Add(sale.Tax)
+			request := randomSaleRequest(rng, currency, total)
+
+			payments, err := settleSale(&sale, items, request, currency)
+			if err != nil {
+				t.Fatalf("%s: settling %+v for %s: %v", currency, request, total, err)
+			}
+
+			// Line totals less the discount plus tax are the total
+			if !sale.Subtotal.Equal(subtotal) || !sale.Total.Equal(total) {
+				t.Fatalf("%s: subtotal %s and total %s, want %s and %s", currency, sale.Subtotal, sale.Total, subtotal, total)
+			}
+			checkAtScale(t, currency, sale.Total, sale.ChangeGiven, sale.DownPayment)
+
+			// What was handed over less the change is what was applied to the sale
+			tendered, applied := decimal.Zero, decimal.Zero
+			for _, payment := range payments {
+				tendered = tendered.Add(payment.Tendered)
+				applied = applied.Add(payment.Amount)
+			}
+			if !tendered.Sub(sale.ChangeGiven).Equal(applied) {
+				t.Fatalf("%s: tendered %s less change %s != applied %s", currency, tendered, sale.ChangeGiven, applied)
+			}
+			checkSalePayments(t, currency, sale, payments)
+
+			if request.PaymentMethod != "credit" {
+				if !sale.AmountDue.IsZero() {
+					t.Fatalf("%s: paid sale of %s leaves %s due", currency, sale.Total, sale.AmountDue)
+				}
+				continue
+			}
+			if !sale.DownPayment.Equal(sale.AmountPaid) {
+				t.Fatalf("%s: down payment %s but %s paid", currency, sale.DownPayment, sale.AmountPaid)
+			}
+
+			// Pay off the rest in instalments, as RecordSalePayment does
+			for sale.AmountDue.IsPositive() {
+				amount := nextInstalment(rng, currency, sale.AmountDue)
+				sale.AmountPaid, sale.AmountDue = applyPayment(sale.AmountPaid, sale.AmountDue, amount)
+				updateSalePaymentStatus(&sale)
+				payments = append(payments, models.SalePayment{Amount: amount, Tendered: amount})
+				checkSalePayments(t, currency, sale, payments)
+			}
+			if sale.PaymentStatus != "paid" {
+				t.Fatalf("%s: sale paid off has status %q", currency, sale.PaymentStatus)
+			}
+		}
+	}
+}
+
+func TestSettleSaleDiscountOverSubtotal(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, currency := range totalsCurrencies {
+		items := randomSaleItems(rng, currency)
+		subtotal := decimal.Zero
+		for _, item := range items {
+			subtotal = subtotal.Add(item.Total)
+		}
+		// Tax must not hide a discount over the subtotal
+		sale := models.Sale{
+			Discount: subtotal.Add(decimal.New(1, -money.Scale(currency))),
+			Tax:      subtotal,
+		}
+		if _, err := settleSale(&sale, items, SaleRequest{PaymentMethod: "cash"}, currency); err == nil {
+			t.Errorf("%s: discount of %s on a subtotal of %s was accepted", currency, sale.Discount, subtotal)
+		}
+	}
+}
+
+func TestSettleSaleDownPaymentOverTotal(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, currency := range totalsCurrencies {
+		items := randomSaleItems(rng, currency)
+		sale := models.Sale{}
+		request := SaleRequest{PaymentMethod: "credit", PaymentDays: 30}
+		if _, err := settleSale(&sale, items, request, currency); err != nil {
+			t.Fatalf("%s: settling a credit sale: %v", currency, err)
+		}
+		request.DownPayment = sale.Total.Add(decimal.New(1, -money.Scale(currency)))
+		if _, err := settleSale(&sale, items, request, currency); err == nil {
+			t.Errorf("%s: down payment of %s on a sale of %s was accepted", currency, request.DownPayment, sale.Total)
+		}
+	}
+}
+
+func TestSettlePurchaseOrderProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for _, currency := range totalsCurrencies {
+		for i := 0; i < 500; i++ {
+			items := randomPurchaseOrderItems(rng, currency)
+			lineTotals := make([]decimal.Decimal, len(items))
+			for j, item := range items {
+				lineTotals[j] = item.Total
+			}
+			total := money.Sum(lineTotals...)
+
+			// Paid on receipt
+			po := models.PurchaseOrder{Currency: currency}
+			if err := settlePurchaseOrder(&po, items, "cash", decimal.Zero); err != nil {
+				t.Fatalf("%s: settling a cash order: %v", currency, err)
+			}
+			if !po.Total.Equal(total) || !po.AmountDue.IsZero() {
+				t.Fatalf("%s: cash order of %s has total %s and %s due", currency, total, po.Total, po.AmountDue)
+			}
+			checkSettled(t, currency, po.Total, po.AmountPaid, po.AmountDue)
+
+			// On credit with a down payment of at most the total, then paid off as RecordPurchasePayment does
+			po = models.PurchaseOrder{Currency: currency}
+			if err := settlePurchaseOrder(&po, items, "credit", randomShare(rng, currency, total)); err != nil {
+				t.Fatalf("%s: settling a credit order: %v", currency, err)
+			}
+			if !po.Total.Equal(total) || !po.DownPayment.Equal(po.AmountPaid) {
+				t.Fatalf("%s: credit order of %s has total %s, down payment %s and %s paid", currency, total, po.Total, po.DownPayment, po.AmountPaid)
+			}
+			checkSettled(t, currency, po.Total, po.AmountPaid, po.AmountDue)
+			paid := po.AmountPaid
+			for po.AmountDue.IsPositive() {
+				amount := nextInstalment(rng, currency, po.AmountDue)
+				po.AmountPaid, po.AmountDue = applyPayment(po.AmountPaid, po.AmountDue, amount)
+				updatePurchasePaymentStatus(&po)
+				paid = paid.Add(amount)
+				if !paid.Equal(po.AmountPaid) {
+					t.Fatalf("%s: payments of %s recorded for %s paid", currency, paid, po.AmountPaid)
+				}
+				checkSettled(t, currency, po.Total, po.AmountPaid, po.AmountDue)
+			}
+			if po.PaymentStatus != "paid" {
+				t.Fatalf("%s: order paid off has status %q", currency, po.PaymentStatus)
+			}
+		}
+	}
+}
+
+func TestSettlePurchaseOrderDownPaymentOverTotal(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for _, currency := range totalsCurrencies {
+		items := randomPurchaseOrderItems(rng, currency)
+		po := models.PurchaseOrder{Currency: currency}
+		total := decimal.Zero
+		for _, item := range items {
+			total = total.Add(item.Total)
+		}
+		downPayment := total.Add(decimal.New(1, -money.Scale(currency)))
+		if err := settlePurchaseOrder(&po, items, "credit", downPayment); err == nil {
+			t.Errorf("%s: down payment of %s on an order of %s was accepted", currency, downPayment, total)
+		}
+	}
+}
@@ -0,0 +1,588 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory_system/audit"
+	"inventory_system/database"
+	"inventory_system/middleware"
+	"inventory_system/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// totpPeriod is the TOTP step in seconds, used to stop a code being replayed within its window
+const totpPeriod = 30
+
+// Challenge purposes for the second login step
+const (
+	challengeVerify = "verify"
+	challengeEnroll = "enroll"
+)
+
+// TwoFactorVerifyRequest represents the second login step for an enrolled user
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorEnrollRequest represents the second login step for a user whose role requires 2FA
+type TwoFactorEnrollRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorSetup is the enrollment material shown to the user once
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI for authenticator apps
+	QRCode          string `json:"qr_code"`          // PNG data URL of the provisioning URI
+}
+
+// totpIssuer is the account issuer shown in authenticator apps
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Inventory System"
+}
+
+// roleRequiresTwoFactor reports whether the role's policy forces 2FA on its holders
+func roleRequiresTwoFactor(role string) bool {
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ? AND require_two_factor = ?", role, true).Count(&count)
+	return count > 0
+}
+
+// newTwoFactorSetup generates a fresh TOTP secret, stores it as pending and returns the enrollment material
+func newTwoFactorSetup(user *models.User) (*TwoFactorSetup, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer(),
+		AccountName: user.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := key.Image(200, 200)
+	if err != nil {
+		return nil, err
+	}
+	var qr bytes.Buffer
+	if err := png.Encode(&qr, image); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(user).Update("two_factor_pending", key.Secret()).Error; err != nil {
+		return nil, err
+	}
+	user.TwoFactorPending = key.Secret()
+
+	return &TwoFactorSetup{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+		QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	}, nil
+}
+
+// validateTOTP checks a code against a secret and rejects codes from a step that was already used
+func validateTOTP(user *models.User, secret, code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if code == "" || !totp.Validate(code, secret) {
+		return false
+	}
+
+	step := time.Now().Unix() / totpPeriod
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		Update("two_factor_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed with or without dashes
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// useRecoveryCode consumes an unused recovery code of the user
+func useRecoveryCode(userID uint, code string) bool {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false
+	}
+
+	result := database.DB.Model(&models.TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashSecretToken(normalized)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// issueRecoveryCodes replaces the user's recovery codes inside tx and returns the new plain codes
+func issueRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.TwoFactorRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		random := make([]byte, 6)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(random)
+		codes = append(codes, fmt.Sprintf("%s-%s-%s", raw[0:4], raw[4:8], raw[8:12]))
+		records = append(records, models.TwoFactorRecoveryCode{UserID: userID, CodeHash: hashSecretToken(raw)})
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// enableTwoFactor promotes the pending secret, issues recovery codes and records the change in the audit log
+func enableTwoFactor(c *gin.Context, user *models.User) ([]string, error) {
+	tx := database.DB.Begin()
+
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"two_factor_enabled": true,
+		"two_factor_secret":  user.TwoFactorPending,
+		"two_factor_pending": "",
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	codes, err := issueRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := audit.Record(tx, c, "enable_two_factor", "user", user.ID, nil, nil); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	user.TwoFactorEnabled = true
+	user.TwoFactorSecret = user.TwoFactorPending
+	user.TwoFactorPending = ""
+	return codes, nil
+}
+
+// twoFactorChallenge answers a correct password with a challenge instead of tokens when a second
+// factor is needed. It returns false when the user can sign in with the password alone.
+func twoFactorChallenge(c *gin.Context, user *models.User) bool {
+	if user.TwoFactorEnabled {
+		token, err := middleware.GenerateChallengeToken(user.ID, challengeVerify)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return true
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"two_factor_required": true,
+				"challenge_token":     token,
+			},
+		})
+		return true
+	}
+
+	if !roleRequiresTwoFactor(user.Role) {
+		return false
+	}
+
+	// The role's policy requires 2FA, so the user must enroll before getting a session
+	setup, err := newTwoFactorSetup(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor enrollment"})
+		return true
+	}
+	token, err := middleware.GenerateChallengeToken(user.ID, challengeEnroll)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return true
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"two_factor_enrollment_required": true,
+			"challenge_token":                token,
+			"setup":                          setup,
+		},
+	})
+	return true
+}
+
+// loadChallengeUser resolves the active user behind a challenge token
+func loadChallengeUser(c *gin.Context, challengeToken, purpose string) (*models.User, bool) {
+	claims, err := middleware.ParseChallengeToken(challengeToken, purpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge. Please sign in again"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND is_active = ?", claims.UserID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge. Please sign in again"})
+		return nil, false
+	}
+
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		middleware.RejectTooManyAttempts(c, time.Until(*user.LockedUntil))
+		return nil, false
+	}
+	return &user, true
+}
+
+// finishLogin clears failed attempts and issues the session once every factor has been checked
+func finishLogin(c *gin.Context, user *models.User, extra gin.H) {
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		database.DB.Model(user).Updates(map[string]interface{}{"failed_login_attempts": 0, "locked_until": nil})
+		user.FailedLoginAttempts = 0
+		user.LockedUntil = nil
+	}
+
+	response, err := startSession(c, *user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	middleware.LogActivity(c, user.ID, "login", "auth", user.ID, "Signed in")
+
+	body := gin.H{"success": true, "data": response}
+	for key, value := range extra {
+		body[key] = value
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// VerifyTwoFactor completes a login with a TOTP code or a recovery code
+func VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either code or recovery_code is required"})
+		return
+	}
+
+	user, ok := loadChallengeUser(c, req.ChallengeToken, challengeVerify)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge. Please sign in again"})
+		return
+	}
+
+	var extra gin.H
+	if req.RecoveryCode != "" {
+		if !useRecoveryCode(user.ID, req.RecoveryCode) {
+			recordFailedLogin(c, user)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid recovery code"})
+			return
+		}
+
+		var remaining int64
+		database.DB.Model(&models.TwoFactorRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+		middleware.LogActivity(c, user.ID, "recovery_code_used", "auth", user.ID,
+			fmt.Sprintf("Signed in with a recovery code, %d left", remaining))
+		extra = gin.H{"recovery_codes_remaining": remaining}
+	} else if !validateTOTP(user, user.TwoFactorSecret, req.Code) {
+		recordFailedLogin(c, user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	finishLogin(c, user, extra)
+}
+
+// EnrollTwoFactor completes a login for a user whose role requires 2FA by confirming the new authenticator
+func EnrollTwoFactor(c *gin.Context) {
+	var req TwoFactorEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadChallengeUser(c, req.ChallengeToken, challengeEnroll)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled || user.TwoFactorPending == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge. Please sign in again"})
+		return
+	}
+
+	if !validateTOTP(user, user.TwoFactorPending, req.Code) {
+		recordFailedLogin(c, user)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, err := enableTwoFactor(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	middleware.LogActivity(c, user.ID, "two_factor_enabled", "auth", user.ID, "Enrolled in two-factor authentication at sign-in")
+
+	finishLogin(c, user, gin.H{"recovery_codes": codes})
+}
+
+// loadCurrentUserWithPassword loads the signed-in user and checks their password
+func loadCurrentUserWithPassword(c *gin.Context, password string) (*models.User, bool) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	// Require the account password so a borrowed session can't change 2FA
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// SetupTwoFactor starts 2FA enrollment for the current user and returns the QR code to scan
+func SetupTwoFactor(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUserWithPassword(c, req.Password)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	setup, err := newTwoFactorSetup(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator works, returning recovery codes
+func ConfirmTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TwoFactorPending == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	if !validateTOTP(&user, user.TwoFactorPending, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, err := enableTwoFactor(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	middleware.LogActivity(c, user.ID, "two_factor_enabled", "auth", user.ID, "Enabled two-factor authentication")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled. Store the recovery codes somewhere safe; they are shown only once",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns off 2FA for the current user unless their role requires it
+func DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUserWithPassword(c, req.Password)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if roleRequiresTwoFactor(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication"})
+		return
+	}
+	if !validateTOTP(user, user.TwoFactorSecret, req.Code) && !useRecoveryCode(user.ID, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := clearTwoFactor(tx, user.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	if !recordAudit(c, tx, "disable_two_factor", "user", user.ID, nil, nil) {
+		return
+	}
+
+	tx.Commit()
+	middleware.LogActivity(c, user.ID, "two_factor_disabled", "auth", user.ID, "Disabled two-factor authentication")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUserWithPassword(c, req.Password)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !validateTOTP(user, user.TwoFactorSecret, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	codes, err := issueRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if !recordAudit(c, tx, "regenerate_recovery_codes", "user", user.ID, nil, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// clearTwoFactor removes a user's TOTP secrets and recovery codes inside tx
+func clearTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled": false,
+		"two_factor_secret":  "",
+		"two_factor_pending": "",
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error
+}
+
+// ResetTwoFactor removes a user's 2FA so they can enroll again, e.g. after losing their device (admin only).
+// The user's sessions are revoked so the reset can't be used to ride an existing login.
+func ResetTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !middleware.CanGrantRole(c, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can't manage a user whose role has permissions you don't have"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := clearTwoFactor(tx, user.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if err := revokeUserSessions(tx, user.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if !recordAudit(c, tx, "reset_two_factor", "user", user.ID, nil, nil) {
+		return
+	}
+
+	tx.Commit()
+
+	adminID, _ := c.Get("user_id")
+	middleware.LogActivity(c, adminID.(uint), "two_factor_reset", "users", user.ID, "Reset two-factor authentication for "+user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
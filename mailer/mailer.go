@@ -0,0 +1,227 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotConfigured is returned when no SMTP relay has been configured
+var ErrNotConfigured = errors.New("SMTP relay is not configured")
+
+// Attachment is a file attached to an outgoing message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an outgoing email
+type Message struct {
+	To          []string
+	ReplyTo     string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Config holds the SMTP relay settings
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+// LoadConfig reads the SMTP relay settings from the environment
+func LoadConfig() Config {
+	config := Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		User:     os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.From == "" {
+		config.From = config.User
+	}
+	return config
+}
+
+// Configured reports whether enough settings are present to send mail
+func (c Config) Configured() bool {
+	return c.Host != "" && c.From != ""
+}
+
+// Sender delivers outgoing email. The SMTP relay is the default; tests and
+// deployments without a relay can plug in another implementation with SetSender.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SenderFunc adapts a function to the Sender interface
+type SenderFunc func(msg Message) error
+
+// Send calls f(msg)
+func (f SenderFunc) Send(msg Message) error {
+	return f(msg)
+}
+
+var (
+	senderMu sync.RWMutex
+	sender   Sender
+)
+
+// SetSender replaces the sender used by Send; nil restores the default chosen by MAIL_DRIVER
+func SetSender(s Sender) {
+	senderMu.Lock()
+	sender = s
+	senderMu.Unlock()
+}
+
+// currentSender returns the plugged-in sender, or the one selected by MAIL_DRIVER (smtp or log)
+func currentSender() Sender {
+	senderMu.RLock()
+	s := sender
+	senderMu.RUnlock()
+	if s != nil {
+		return s
+	}
+
+	if os.Getenv("MAIL_DRIVER") == "log" {
+		return LogSender{}
+	}
+	return SMTPSender{Config: LoadConfig()}
+}
+
+// Send delivers a message through the current sender
+func Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+	return currentSender().Send(msg)
+}
+
+// SMTPSender delivers messages through an SMTP relay
+type SMTPSender struct {
+	Config Config
+}
+
+// Send delivers a message through the relay
+func (s SMTPSender) Send(msg Message) error {
+	config := s.Config
+	if !config.Configured() {
+		return ErrNotConfigured
+	}
+
+	data, err := build(config.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if config.User != "" {
+		auth = smtp.PlainAuth("", config.User, config.Password, config.Host)
+	}
+
+	// smtp.SendMail upgrades to STARTTLS whenever the relay offers it
+	return smtp.SendMail(net.JoinHostPort(config.Host, config.Port), auth, config.From, msg.To, data)
+}
+
+// LogSender writes messages to the server log instead of sending them, for development
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(msg Message) error {
+	log.Printf("mail to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Body)
+	return nil
+}
+
+// build encodes the message as MIME, using multipart/mixed when there are attachments
+func build(from string, msg Message) ([]byte, error) {
+	for _, header := range append([]string{from, msg.ReplyTo, msg.Subject}, msg.To...) {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("message headers must not contain line breaks")
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	if msg.ReplyTo != "" {
+		fmt.Fprintf(&buf, "Reply-To: %s\r\n", msg.ReplyTo)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(msg.Attachments) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(&buf, []byte(msg.Body))
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64(&buf, []byte(msg.Body))
+
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		filename := mime.QEncoding.Encode("utf-8", attachment.Filename)
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; name=%q\r\n", contentType, filename)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n\r\n", filename)
+		writeBase64(&buf, attachment.Data)
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data base64 encoded in 76 character lines
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+}
+
+// newBoundary returns a random multipart boundary
+func newBoundary() (string, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "mixed-" + hex.EncodeToString(random), nil
+}
@@ -1,148 +1,188 @@
 package middleware
 
 import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"inventory_system/database"
 	"inventory_system/models"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ActivityLogger middleware logs user activities
+// apiPrefix is stripped from route patterns before they are looked up in routeActions
+const apiPrefix = "/api/v1"
+
+// routeActions names the activity logged for each mutating route, keyed by method and route pattern.
+// Mutating routes that aren't listed are still logged under a generic action.
+var routeActions = map[string]string{
+	"PUT /profile/approval-pin":                              "set_approval_pin",
+	"PUT /profile/password":                                  "change_password",
+	"POST /profile/2fa/setup":                                "start_two_factor_setup",
+	"POST /profile/2fa/confirm":                              "enable_two_factor",
+	"POST /profile/2fa/disable":                              "disable_two_factor",
+	"POST /profile/2fa/recovery-codes":                       "regenerate_recovery_codes",
+	"POST /products":                                         "create_product",
+	"PUT /products/:id":                                      "update_product",
+	"DELETE /products/:id":                                   "delete_product",
+	"POST /products/import":                                  "import_products",
+	"POST /products/:id/suppliers":                           "add_product_supplier",
+	"PUT /products/:id/suppliers/:supplier_id":               "update_product_supplier",
+	"DELETE /products/:id/suppliers/:supplier_id":            "remove_product_supplier",
+	"POST /products/:id/suppliers/:supplier_id/adjust-stock": "adjust_stock",
+	"POST /pos/sales":                                        "create_sale",
+	"PUT /pos/sales/:id/void":                                "void_sale",
+	"DELETE /pos/sales/:id":                                  "delete_sale",
+	"POST /pos/sales/:id/payment":                            "record_sale_payment",
+	"POST /pos/shifts":                                       "open_shift",
+	"POST /pos/shifts/:id/cash-movements":                    "record_cash_movement",
+	"POST /pos/shifts/:id/close":                             "close_shift",
+	"POST /pos/carts":                                        "create_cart",
+	"POST /pos/carts/:id/items":                              "add_cart_item",
+	"DELETE /pos/carts/:id/items/:item_id":                   "remove_cart_item",
+	"POST /pos/carts/:id/park":                               "park_cart",
+	"POST /pos/carts/:id/resume":                             "resume_cart",
+	"POST /pos/carts/:id/finalize":                           "finalize_cart",
+	"DELETE /pos/carts/:id":                                  "cancel_cart",
+	"POST /quotations":                                       "create_quotation",
+	"PUT /quotations/:id":                                    "update_quotation",
+	"DELETE /quotations/:id":                                 "delete_quotation",
+	"POST /quotations/:id/send":                              "send_quotation",
+	"POST /quotations/:id/accept":                            "accept_quotation",
+	"POST /quotations/:id/reject":                            "reject_quotation",
+	"POST /quotations/:id/convert":                           "convert_quotation",
+	"POST /sales-orders":                                     "create_sales_order",
+	"POST /sales-orders/:id/delivery-notes":                  "ship_sales_order",
+	"POST /sales-orders/:id/cancel":                          "cancel_sales_order",
+	"POST /suppliers":                                        "create_supplier",
+	"PUT /suppliers/:id":                                     "update_supplier",
+	"DELETE /suppliers/:id":                                  "delete_supplier",
+	"POST /purchase-orders":                                  "create_purchase_order",
+	"PUT /purchase-orders/:id":                               "update_purchase_order",
+	"POST /purchase-orders/:id/send":                         "send_purchase_order",
+	"POST /purchase-orders/:id/receive":                      "receive_purchase_order",
+	"DELETE /purchase-orders/:id":                            "delete_purchase_order",
+	"POST /purchase-orders/:id/payment":                      "record_purchase_payment",
+	"POST /purchase-orders/:id/apply-credit":                 "apply_supplier_credit",
+	"POST /purchase-returns":                                 "create_purchase_return",
+	"POST /exchange-rates":                                   "save_exchange_rate",
+	"POST /exchange-rates/import":                            "import_exchange_rates",
+	"DELETE /exchange-rates/:id":                             "delete_exchange_rate",
+	"POST /rfqs":                                             "create_rfq",
+	"POST /rfqs/:id/send":                                    "send_rfq",
+	"PUT /rfqs/:id/suppliers/:supplier_id/quote":             "record_rfq_quote",
+	"POST /rfqs/:id/award":                                   "award_rfq",
+	"POST /rfqs/:id/cancel":                                  "cancel_rfq",
+	"PUT /admin/system/settings":                             "update_settings",
+	"POST /admin/system/backup":                              "backup_database",
+	"POST /admin/system/restore":                             "restore_database",
+	"DELETE /admin/system/backups/:filename":                 "delete_backup",
+	"POST /admin/company-profile":                            "update_company_profile",
+	"PUT /admin/company-profile":                             "update_company_profile",
+	"PUT /admin/company-profile/logo":                        "update_company_logo",
+	"DELETE /admin/company-profile/:id":                      "delete_company_profile",
+	"POST /admin/users":                                      "create_user",
+	"PUT /admin/users/:id":                                   "update_user",
+	"DELETE /admin/users/:id":                                "delete_user",
+	"POST /admin/users/:id/unlock":                           "unlock_user",
+	"DELETE /admin/users/:id/2fa":                            "reset_two_factor",
+	"DELETE /admin/users/:id/sessions":                       "revoke_user_sessions",
+	"DELETE /admin/sessions/:id":                             "revoke_session",
+	"POST /admin/service-accounts":                           "create_service_account",
+	"DELETE /admin/service-accounts/:id":                     "delete_service_account",
+	"POST /admin/service-accounts/:id/keys":                  "create_api_key",
+	"DELETE /admin/api-keys/:id":                             "revoke_api_key",
+	"POST /admin/invitations":                                "create_invitation",
+	"DELETE /admin/invitations/:id":                          "revoke_invitation",
+	"POST /admin/roles":                                      "create_role",
+	"PUT /admin/roles/:id":                                   "update_role",
+	"DELETE /admin/roles/:id":                                "delete_role",
+	"DELETE /admin/products/:id":                             "delete_product",
+}
+
+// ActivityLogger middleware logs every successful mutating request made by an authenticated user
 func ActivityLogger() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		// Process request first
 		c.Next()
 
-		// Only log successful requests and specific actions
-		if c.Writer.Status() >= 400 {
-			return
-		}
-
-		// Get user from context
-		userInterface, exists := c.Get("user")
-		if !exists {
+		// Only log successful requests that change something
+		if c.Writer.Status() >= 400 || !isMutating(c.Request.Method) {
 			return
 		}
 
-		user, ok := userInterface.(*models.User)
-		if !ok {
+		// AuthMiddleware sets the user ID; requests it rejected never get here
+		userID := c.GetUint("user_id")
+		if userID == 0 {
 			return
 		}
 
-		// Determine action based on method and path
-		action := getActionFromRequest(c)
-		if action == "" {
-			return // Skip logging for read-only operations
-		}
-
-		// Create activity log
-		log := models.ActivityLog{
-			UserID:     user.ID,
-			Action:     action,
+		entry := models.ActivityLog{
+			UserID:     userID,
+			Action:     getActionFromRequest(c),
 			Resource:   c.Request.URL.Path,
 			ResourceID: getResourceID(c),
-			Details:    getActionDetails(c),
+			Details:    c.Request.Method + " " + c.Request.URL.Path,
 			IPAddress:  c.ClientIP(),
 			UserAgent:  c.Request.UserAgent(),
 			CreatedAt:  time.Now(),
 		}
 
-		// Save to database (non-blocking)
-		go func() {
-			database.DB.Create(&log)
-		}()
+		// Written before the handler returns, so an entry is never lost when the server stops
+		if err := database.DB.Create(&entry).Error; err != nil {
+			log.Printf("Failed to record activity %s for user %d: %v", entry.Action, userID, err)
+		}
 	})
 }
 
-// getActionFromRequest determines the action based on HTTP method and path
-func getActionFromRequest(c *gin.Context) string {
-	method := c.Request.Method
-	path := c.Request.URL.Path
-
-	switch method {
-	case "POST":
-		if contains(path, "/auth/login") {
-			return "login"
-		} else if contains(path, "/auth/register") {
-			return "register"
-		} else if contains(path, "/products") {
-			return "create_product"
-		} else if contains(path, "/pos/sales") {
-			return "create_sale"
-		} else if contains(path, "/users") {
-			return "create_user"
-		} else if contains(path, "/adjust-stock") {
-			return "adjust_stock"
-		} else if contains(path, "/backup") {
-			return "backup_database"
-		}
-	case "PUT":
-		if contains(path, "/products") {
-			return "update_product"
-		} else if contains(path, "/users") {
-			return "update_user"
-		} else if contains(path, "/settings") {
-			return "update_settings"
-		} else if contains(path, "/void") {
-			return "void_sale"
-		}
-	case "DELETE":
-		if contains(path, "/products") {
-			return "delete_product"
-		} else if contains(path, "/users") {
-			return "delete_user"
-		}
+// LogActivity records an explicit event, such as a lockout, that the request-based logger can't infer
+func LogActivity(c *gin.Context, userID uint, action, resource string, resourceID uint, details string) {
+	err := database.DB.Create(&models.ActivityLog{
+		UserID:     userID,
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		Details:    details,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		CreatedAt:  time.Now(),
+	}).Error
+	if err != nil {
+		log.Printf("Failed to record activity %s for user %d: %v", action, userID, err)
 	}
-
-	return ""
 }
 
-// getResourceID extracts resource ID from URL parameters
-func getResourceID(c *gin.Context) uint {
-	if id := c.Param("id"); id != "" {
-		// Convert string to uint (simple conversion, should add proper error handling)
-		var resourceID uint
-		if parsed := parseUint(id); parsed > 0 {
-			resourceID = parsed
-		}
-		return resourceID
+// isMutating reports whether a request method changes state
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
 	}
-	return 0
+	return false
 }
 
-// getActionDetails creates a description of the action
-func getActionDetails(c *gin.Context) string {
-	method := c.Request.Method
-	path := c.Request.URL.Path
-
-	if method == "POST" && contains(path, "/pos/sales") {
-		return "Created new sale transaction"
-	} else if method == "PUT" && contains(path, "/products") {
-		return "Updated product information"
-	} else if method == "DELETE" && contains(path, "/users") {
-		return "Deleted user account"
-	} else if contains(path, "/adjust-stock") {
-		return "Adjusted product stock levels"
+// getActionFromRequest determines the action from the matched route, falling back to the method
+// and route pattern, e.g. "post /things/:id"
+func getActionFromRequest(c *gin.Context) string {
+	route := strings.TrimPrefix(c.FullPath(), apiPrefix)
+	if route == "" {
+		route = c.Request.URL.Path
 	}
 
-	return method + " " + path
-}
-
-// Helper functions
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr ||
-		(len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr)))
+	if action, ok := routeActions[c.Request.Method+" "+route]; ok {
+		return action
+	}
+	return strings.ToLower(c.Request.Method) + " " + route
 }
 
-func parseUint(s string) uint {
-	var result uint
-	for _, ch := range s {
-		if ch >= '0' && ch <= '9' {
-			result = result*10 + uint(ch-'0')
-		} else {
-			return 0
-		}
+// getResourceID extracts the resource ID from the :id route parameter
+func getResourceID(c *gin.Context) uint {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0
 	}
-	return result
+	return uint(id)
 }
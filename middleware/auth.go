@@ -3,45 +3,139 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"inventory_system/database"
+	"inventory_system/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// defaultAccessTokenMinutes is the access token lifetime unless ACCESS_TOKEN_TTL_MINUTES is set
+const defaultAccessTokenMinutes = 15
+
 // Claims represents JWT claims
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	SessionID uint   `json:"sid"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID uint, email, role string) (string, error) {
+// AccessTokenTTL returns how long access tokens stay valid
+func AccessTokenTTL() time.Duration {
+	if value := os.Getenv("ACCESS_TOKEN_TTL_MINUTES"); value != "" {
+		if minutes, err := strconv.Atoi(value); err == nil && minutes > 0 {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+	return defaultAccessTokenMinutes * time.Minute
+}
+
+// jwtSecret returns the signing key for access tokens
+func jwtSecret() []byte {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "your-super-secret-jwt-key"
+	}
+	return []byte(secret)
+}
+
+// GenerateToken generates a short-lived access token bound to a server-side session
+func GenerateToken(userID, sessionID uint, email, role string) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		SessionID: sessionID,
+		Email:     email,
+		Role:      role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-super-secret-jwt-key"
+	return token.SignedString(jwtSecret())
+}
+
+// ParseToken validates an access token and returns its claims
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	// Challenge tokens carry an audience and must never work as access tokens
+	if !token.Valid || len(claims.Audience) > 0 {
+		return nil, jwt.ErrTokenInvalidClaims
 	}
+	return claims, nil
+}
+
+// challengeAudience marks tokens that only prove the password step of a two-factor login
+const challengeAudience = "two-factor-challenge"
+
+// challengeTTL is how long a user has to complete the second login step
+const challengeTTL = 5 * time.Minute
+
+// ChallengeClaims identify a user who passed the password check but still owes a second factor
+type ChallengeClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"` // verify, enroll
+	jwt.RegisteredClaims
+}
 
-	return token.SignedString([]byte(jwtSecret))
+// GenerateChallengeToken issues a short-lived token for the second login step
+func GenerateChallengeToken(userID uint, purpose string) (string, error) {
+	claims := ChallengeClaims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(challengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret())
 }
 
-// AuthMiddleware validates JWT tokens
+// ParseChallengeToken validates a challenge token issued for the given purpose
+func ParseChallengeToken(tokenString, purpose string) (*ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(challengeAudience))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Purpose != purpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// AuthMiddleware validates JWT tokens and checks that the session and user are still active.
+// Service accounts authenticate with an X-API-Key header instead.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+			if !authenticateAPIKey(c, apiKey) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -51,51 +145,28 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 
-		claims := &Claims{}
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret == "" {
-			jwtSecret = "your-super-secret-jwt-key"
-		}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := ParseToken(tokenString)
+		if err != nil || claims.SessionID == 0 {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
-		c.Next()
-	}
-}
-
-// AdminMiddleware ensures user has admin role
-func AdminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("user_role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		// A revoked session or deactivated user loses access immediately, not at token expiry
+		var session models.UserSession
+		if err := database.DB.Preload("User").First(&session, claims.SessionID).Error; err != nil ||
+			session.UserID != claims.UserID || session.RevokedAt != nil ||
+			session.User.ID == 0 || !session.User.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
 			c.Abort()
 			return
 		}
-		c.Next()
-	}
-}
 
-// ManagerMiddleware ensures user has manager or admin role
-func ManagerMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("user_role")
-		if !exists || (role != "admin" && role != "manager") {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Manager or admin access required"})
-			c.Abort()
-			return
-		}
+		// Role and email come from the database so changes apply without logging in again
+		c.Set("user_id", session.User.ID)
+		c.Set("user_email", session.User.Email)
+		c.Set("user_role", session.User.Role)
+		c.Set("session_id", session.ID)
 		c.Next()
 	}
 }
@@ -118,7 +189,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		} else {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:8080") // fallback
 		}
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttemptLimiter tracks failed attempts per key in memory. After FreeAttempts
+// failures each further attempt must wait an exponentially growing delay, and
+// after MaxAttempts failures the key is locked out for Lockout.
+type AttemptLimiter struct {
+	FreeAttempts int
+	MaxAttempts  int
+	Window       time.Duration // Failures older than this are forgotten
+	Lockout      time.Duration
+	// KeepOnSuccess leaves failures to age out instead of clearing them on a successful request,
+	// for keys shared by several accounts such as a client IP
+	KeepOnSuccess bool
+
+	mu       sync.Mutex
+	attempts map[string]*attemptRecord
+	calls    int
+}
+
+type attemptRecord struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// NewAttemptLimiter creates a limiter with the given thresholds
+func NewAttemptLimiter(freeAttempts, maxAttempts int, window, lockout time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{
+		FreeAttempts: freeAttempts,
+		MaxAttempts:  maxAttempts,
+		Window:       window,
+		Lockout:      lockout,
+		attempts:     make(map[string]*attemptRecord),
+	}
+}
+
+// Blocked reports how long the key must still wait before its next attempt
+func (l *AttemptLimiter) Blocked(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.attempts[key]
+	if !ok {
+		return 0, false
+	}
+	if wait := time.Until(record.blockedUntil); wait > 0 {
+		return wait, true
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt and returns the resulting wait and whether it is a full lockout
+func (l *AttemptLimiter) Fail(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	record, ok := l.attempts[key]
+	if !ok || now.Sub(record.lastFailure) > l.Window {
+		record = &attemptRecord{}
+		l.attempts[key] = record
+	}
+	record.failures++
+	record.lastFailure = now
+
+	wait, locked := l.Delay(record.failures)
+	record.blockedUntil = now.Add(wait)
+	return wait, locked
+}
+
+// Reset forgets all failures for the key, e.g. after a successful attempt
+func (l *AttemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	delete(l.attempts, key)
+	l.mu.Unlock()
+}
+
+// Delay returns the wait imposed after the given number of consecutive failures
+// and whether it is a full lockout
+func (l *AttemptLimiter) Delay(failures int) (time.Duration, bool) {
+	if failures >= l.MaxAttempts {
+		return l.Lockout, true
+	}
+	if failures <= l.FreeAttempts {
+		return 0, false
+	}
+	delay := time.Duration(math.Pow(2, float64(failures-l.FreeAttempts-1))) * time.Second
+	if delay > l.Lockout {
+		delay = l.Lockout
+	}
+	return delay, false
+}
+
+// prune periodically drops records that no longer affect anything
+func (l *AttemptLimiter) prune(now time.Time) {
+	l.calls++
+	if l.calls%1000 != 0 {
+		return
+	}
+	for key, record := range l.attempts {
+		if now.Sub(record.lastFailure) > l.Window && now.After(record.blockedUntil) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// LimitFailures rejects requests from a key that is waiting out a delay or lockout.
+// Responses with status 401 or 403 count as failures and 2xx responses reset the key unless the
+// limiter keeps failures on success. keyFunc defaults to the client IP, which only honours
+// forwarding headers from the engine's trusted proxies.
+func LimitFailures(limiter *AttemptLimiter, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	if keyFunc == nil {
+		keyFunc = func(c *gin.Context) string { return "ip:" + c.ClientIP() }
+	}
+
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if wait, blocked := limiter.Blocked(key); blocked {
+			RejectTooManyAttempts(c, wait)
+			return
+		}
+
+		c.Next()
+
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			limiter.Fail(key)
+		case status >= 200 && status < 300 && !limiter.KeepOnSuccess:
+			limiter.Reset(key)
+		}
+	}
+}
+
+// RejectTooManyAttempts aborts with 429 and a Retry-After header
+func RejectTooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       fmt.Sprintf("Too many failed attempts. Try again in %d seconds", seconds),
+		"retry_after": seconds,
+	})
+	c.Abort()
+}
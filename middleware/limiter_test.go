@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitedRouter serves /login through LimitFailures, answering with the status in the ?status query
+func limitedRouter(limiter *AttemptLimiter, proxies []string) *gin.Engine {
+	router := gin.New()
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		panic(err)
+	}
+	router.POST("/login", LimitFailures(limiter, nil), func(c *gin.Context) {
+		if c.Query("status") == "ok" {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Status(http.StatusUnauthorized)
+	})
+	return router
+}
+
+// attempt posts to /login from remote with an optional X-Forwarded-For and returns the status
+func attempt(router *gin.Engine, remote, forwardedFor, status string) int {
+	request := httptest.NewRequest(http.MethodPost, "/login?status="+status, nil)
+	request.RemoteAddr = remote + ":40000"
+	if forwardedFor != "" {
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestLimitFailuresIgnoresSpoofedForwardedFor(t *testing.T) {
+	limiter := NewAttemptLimiter(0, 2, time.Minute, time.Minute)
+	router := limitedRouter(limiter, nil)
+
+	attempt(router, "203.0.113.7", "198.51.100.1", "fail")
+	attempt(router, "203.0.113.7", "198.51.100.2", "fail")
+	if code := attempt(router, "203.0.113.7", "198.51.100.3", "fail"); code != http.StatusTooManyRequests {
+		t.Fatalf("rotating X-Forwarded-For got %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestLimitFailuresHonoursTrustedProxy(t *testing.T) {
+	limiter := NewAttemptLimiter(0, 2, time.Minute, time.Minute)
+	router := limitedRouter(limiter, []string{"10.0.0.1"})
+
+	attempt(router, "10.0.0.1", "198.51.100.1", "fail")
+	attempt(router, "10.0.0.1", "198.51.100.1", "fail")
+	if code := attempt(router, "10.0.0.1", "198.51.100.2", "fail"); code == http.StatusTooManyRequests {
+		t.Fatalf("another client behind the trusted proxy was blocked")
+	}
+	if code := attempt(router, "10.0.0.1", "198.51.100.1", "fail"); code != http.StatusTooManyRequests {
+		t.Fatalf("the failing client behind the trusted proxy got %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestLimitFailuresKeepOnSuccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		keep    bool
+		blocked bool
+	}{
+		{"reset", false, false},
+		{"keep", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewAttemptLimiter(1, 3, time.Minute, time.Minute)
+			limiter.KeepOnSuccess = tt.keep
+			router := limitedRouter(limiter, nil)
+
+			// Guesses at other accounts with a successful login of one's own in between
+			attempt(router, "203.0.113.7", "", "fail")
+			attempt(router, "203.0.113.7", "", "fail")
+			limiter.mu.Lock()
+			limiter.attempts["ip:203.0.113.7"].blockedUntil = time.Time{}
+			limiter.mu.Unlock()
+			attempt(router, "203.0.113.7", "", "ok")
+			attempt(router, "203.0.113.7", "", "fail")
+
+			_, blocked := limiter.Blocked("ip:203.0.113.7")
+			if blocked != tt.blocked {
+				t.Errorf("blocked after a success in between: %v, want %v", blocked, tt.blocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"inventory_system/database"
+	"inventory_system/models"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// permissionCacheTTL bounds how stale another instance's role edits can be
+const permissionCacheTTL = time.Minute
+
+var permissionCache = struct {
+	sync.RWMutex
+	roles    map[string]map[string]bool
+	loadedAt time.Time
+}{}
+
+// InvalidatePermissionCache drops cached role permissions after a role is edited
+func InvalidatePermissionCache() {
+	permissionCache.Lock()
+	permissionCache.roles = nil
+	permissionCache.Unlock()
+}
+
+// RolePermissions returns the permission set of a role, loading all roles on a cache miss
+func RolePermissions(role string) map[string]bool {
+	permissionCache.RLock()
+	roles, fresh := permissionCache.roles, time.Since(permissionCache.loadedAt) < permissionCacheTTL
+	permissionCache.RUnlock()
+
+	if roles == nil || !fresh {
+		roles = loadRolePermissions()
+		permissionCache.Lock()
+		permissionCache.roles = roles
+		permissionCache.loadedAt = time.Now()
+		permissionCache.Unlock()
+	}
+
+	return roles[role]
+}
+
+// loadRolePermissions reads every role's permissions from the database
+func loadRolePermissions() map[string]map[string]bool {
+	var roles []models.Role
+	database.DB.Preload("Permissions").Find(&roles)
+
+	result := make(map[string]map[string]bool, len(roles))
+	for _, role := range roles {
+		granted := make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			granted[permission.Permission] = true
+		}
+		result[role.Name] = granted
+	}
+
+	// The admin role can never lock itself out
+	admin := make(map[string]bool, len(permissions.All))
+	for _, name := range permissions.Names() {
+		admin[name] = true
+	}
+	result[permissions.AdminRole] = admin
+
+	return result
+}
+
+// RoleHasPermission reports whether a role grants a permission
+func RoleHasPermission(role, permission string) bool {
+	return RolePermissions(role)[permission]
+}
+
+// HasPermission reports whether the authenticated user holds a permission. Requests made with
+// an API key are further limited to the key's scopes.
+func HasPermission(c *gin.Context, permission string) bool {
+	if scopes, ok := c.Get("api_key_scopes"); ok {
+		if granted, _ := scopes.(map[string]bool); !granted[permission] {
+			return false
+		}
+	}
+
+	role, _ := c.Get("user_role")
+	name, _ := role.(string)
+	return RoleHasPermission(name, permission)
+}
+
+// CanGrantRole reports whether the authenticated user holds every permission of a role, so nobody
+// can hand out more access than they have themselves
+func CanGrantRole(c *gin.Context, role string) bool {
+	for permission := range RolePermissions(role) {
+		if !HasPermission(c, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequirePermission ensures the user's role grants every listed permission
+func RequirePermission(required ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range required {
+			if !HasPermission(c, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Permission required: " + permission})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
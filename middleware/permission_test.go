@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// withRoles fills the permission cache so tests don't need a database
+func withRoles(t *testing.T, roles map[string]map[string]bool) {
+	t.Helper()
+	permissionCache.Lock()
+	permissionCache.roles = roles
+	permissionCache.loadedAt = time.Now()
+	permissionCache.Unlock()
+	t.Cleanup(InvalidatePermissionCache)
+}
+
+func testContext(role string, scopes map[string]bool) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user_role", role)
+	if scopes != nil {
+		c.Set("api_key_scopes", scopes)
+	}
+	return c
+}
+
+func TestCanGrantRole(t *testing.T) {
+	all := make(map[string]bool)
+	for _, name := range permissions.Names() {
+		all[name] = true
+	}
+	withRoles(t, map[string]map[string]bool{
+		permissions.AdminRole: all,
+		"manager":             {permissions.POView: true, permissions.POManage: true, permissions.SalesOverride: true},
+		"buyer":               {permissions.POView: true, permissions.POManage: true},
+		"employee":            {permissions.POView: true},
+		"empty":               {},
+	})
+
+	tests := []struct {
+		name   string
+		actor  string
+		scopes map[string]bool
+		role   string
+		want   bool
+	}{
+		{"admin grants admin", permissions.AdminRole, nil, permissions.AdminRole, true},
+		{"admin grants manager", permissions.AdminRole, nil, "manager", true},
+		{"manager grants same role", "manager", nil, "manager", true},
+		{"manager grants subset", "manager", nil, "buyer", true},
+		{"manager can't grant admin", "manager", nil, permissions.AdminRole, false},
+		{"buyer can't grant manager", "buyer", nil, "manager", false},
+		{"employee can't grant buyer", "employee", nil, "buyer", false},
+		{"anyone grants a role without permissions", "employee", nil, "empty", true},
+		{"unknown actor can't grant", "nobody", nil, "employee", false},
+		{"api key limited to its scopes", permissions.AdminRole, map[string]bool{permissions.POView: true}, "buyer", false},
+		{"api key within its scopes", permissions.AdminRole, map[string]bool{permissions.POView: true}, "employee", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanGrantRole(testContext(tt.actor, tt.scopes), tt.role); got != tt.want {
+				t.Errorf("CanGrantRole(%s, %s) = %v, want %v", tt.actor, tt.role, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// costTag marks a struct field that reveals purchase costs or margins, e.g. `json:"unit_cost" cost:"true"`
+const costTag = "cost"
+
+// CostFields returns the JSON names of the fields tagged cost:"true" in the types of values and
+// in every struct type they contain
+func CostFields(values ...interface{}) map[string]bool {
+	fields := make(map[string]bool)
+	seen := make(map[reflect.Type]bool)
+	for _, value := range values {
+		collectCostFields(reflect.TypeOf(value), fields, seen)
+	}
+	return fields
+}
+
+// collectCostFields walks t, following pointers, slices, maps and nested structs
+func collectCostFields(t reflect.Type, fields map[string]bool, seen map[reflect.Type]bool) {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && !field.Anonymous {
+			name = field.Name
+		}
+		if name != "" && field.Tag.Get(costTag) == "true" {
+			fields[name] = true
+		}
+		collectCostFields(field.Type, fields, seen)
+	}
+}
+
+// redactingWriter buffers JSON responses so cost fields can be removed before sending
+type redactingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	buffering bool
+	decided   bool
+}
+
+func (w *redactingWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *redactingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// RedactCosts strips cost, profit and margin fields from JSON responses for users without the
+// products.cost.view permission, whatever endpoint produced them. The fields are those tagged
+// cost:"true" in the response types given; untyped responses lose the same keys.
+func RedactCosts(responses ...interface{}) gin.HandlerFunc {
+	costFields := CostFields(responses...)
+	return func(c *gin.Context) {
+		if HasPermission(c, permissions.ProductsCost) {
+			c.Next()
+			return
+		}
+
+		writer := &redactingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if !writer.buffering {
+			return
+		}
+
+		body := writer.body.Bytes()
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var payload interface{}
+		if err := decoder.Decode(&payload); err == nil {
+			if redacted, err := json.Marshal(redactCostFields(payload, costFields)); err == nil {
+				body = redacted
+			}
+		}
+		c.Writer.Write(body)
+	}
+}
+
+// redactCostFields walks decoded JSON and removes cost fields at any depth
+func redactCostFields(value interface{}, costFields map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// A line total on a purchase order line is quantity times unit cost
+		if _, ok := v["unit_cost"]; ok {
+			delete(v, "total")
+		}
+		for key, child := range v {
+			if costFields[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = redactCostFields(child, costFields)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactCostFields(child, costFields)
+		}
+	}
+	return value
+}
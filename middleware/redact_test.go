@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"inventory_system/models"
+	"inventory_system/permissions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type testQuote struct {
+	SupplierID  uint             `json:"supplier_id"`
+	UnitCost    decimal.Decimal  `json:"unit_cost" cost:"true"`
+	CurrentCost *decimal.Decimal `json:"current_cost" cost:"true"`
+}
+
+type testComparison struct {
+	Name                 string      `json:"name"`
+	Quotes               []testQuote `json:"quotes"`
+	LowestCostSupplierID *uint       `json:"lowest_cost_supplier_id" cost:"true"`
+	Hidden               string      `json:"-" cost:"true"`
+	unexported           string      `cost:"true"`
+}
+
+func TestCostFields(t *testing.T) {
+	fields := CostFields(&testComparison{}, []models.PurchaseOrder{}, models.PurchasePayment{})
+
+	for _, name := range []string{"unit_cost", "current_cost", "lowest_cost_supplier_id", "total_base", "amount_base", "fx_gain_loss"} {
+		if !fields[name] {
+			t.Errorf("CostFields is missing %q", name)
+		}
+	}
+	for _, name := range []string{"name", "supplier_id", "total", "amount", "-", "Hidden", "unexported"} {
+		if fields[name] {
+			t.Errorf("CostFields includes %q, which isn't tagged", name)
+		}
+	}
+}
+
+// costKeys are the keys every role without products.cost.view must not see
+var costKeys = []string{"unit_cost", "current_cost", "lowest_cost_supplier_id", "total_base", "amount_base", "fx_gain_loss"}
+
+// redactedResponse runs a request as role through RedactCosts and returns the decoded body
+func redactedResponse(t *testing.T, role string) map[string]interface{} {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_role", role) })
+	router.Use(RedactCosts(testComparison{}, models.PurchaseOrder{}, models.PurchasePayment{}))
+
+	supplierID := uint(3)
+	current := decimal.NewFromInt(9)
+	router.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"comparison": testComparison{
+				Name:                 "Widget",
+				Quotes:               []testQuote{{SupplierID: 3, UnitCost: decimal.NewFromInt(8), CurrentCost: &current}},
+				LowestCostSupplierID: &supplierID,
+			},
+			"purchase_order": models.PurchaseOrder{
+				Total:     decimal.NewFromInt(80),
+				TotalBase: decimal.NewFromInt(80),
+				Items:     []models.PurchaseOrderItem{{QuantityOrdered: 10, UnitCost: decimal.NewFromInt(8), Total: decimal.NewFromInt(80)}},
+			},
+			"payment":      models.PurchasePayment{Amount: decimal.NewFromInt(80), AmountBase: decimal.NewFromInt(78), FXGainLoss: decimal.NewFromInt(2)},
+			"fx_gain_loss": decimal.NewFromInt(2), // Untyped responses lose the same keys
+		})
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return body
+}
+
+// findKeys returns how often each key occurs at any depth of value
+func findKeys(value interface{}, counts map[string]int) map[string]int {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			counts[key]++
+			findKeys(child, counts)
+		}
+	case []interface{}:
+		for _, child := range v {
+			findKeys(child, counts)
+		}
+	}
+	return counts
+}
+
+func TestRedactCostsPerRole(t *testing.T) {
+	all := make(map[string]bool)
+	for _, name := range permissions.Names() {
+		all[name] = true
+	}
+	withRoles(t, map[string]map[string]bool{
+		permissions.AdminRole: all,
+		"manager":             {permissions.ProductsView: true, permissions.ProductsCost: true},
+		"employee":            {permissions.ProductsView: true},
+	})
+
+	tests := []struct {
+		role      string
+		seesCosts bool
+	}{
+		{permissions.AdminRole, true},
+		{"manager", true},
+		{"employee", false},
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			body := redactedResponse(t, tt.role)
+			keys := findKeys(body, map[string]int{})
+			for _, key := range costKeys {
+				if seen := keys[key] > 0; seen != tt.seesCosts {
+					t.Errorf("%s sees %q: %v, want %v", tt.role, key, seen, tt.seesCosts)
+				}
+			}
+
+			// Line totals go with their unit costs; the order's own total and other fields stay
+			order := body["purchase_order"].(map[string]interface{})
+			line := order["items"].([]interface{})[0].(map[string]interface{})
+			if _, seen := line["total"]; seen != tt.seesCosts {
+				t.Errorf("%s sees the line total: %v, want %v", tt.role, seen, tt.seesCosts)
+			}
+			if _, seen := order["total"]; !seen || keys["name"] == 0 || keys["supplier_id"] == 0 {
+				t.Errorf("%s lost fields that aren't costs", tt.role)
+			}
+		})
+	}
+}
@@ -1,22 +1,128 @@
 package models
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 // User represents a system user
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Email     string         `json:"email" gorm:"unique;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	Name      string         `json:"name" gorm:"not null"`
-	Role      string         `json:"role" gorm:"default:employee"` // admin, manager, employee
-	IsActive  bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                  uint           `json:"id" gorm:"primaryKey"`
+	Email               string         `json:"email" gorm:"unique;not null"`
+	Password            string         `json:"-" gorm:"not null"`
+	Name                string         `json:"name" gorm:"not null"`
+	Role                string         `json:"role" gorm:"default:employee"` // Name of a Role, e.g. admin, manager, employee
+	IsActive            bool           `json:"is_active" gorm:"default:true"`
+	ApprovalPIN         string         `json:"-"`                                      // Hashed PIN used by managers to approve POS price overrides
+	FailedLoginAttempts int            `json:"failed_login_attempts" gorm:"default:0"` // Consecutive failures; reset on success or admin unlock
+	LastFailedLoginAt   *time.Time     `json:"-"`
+	LockedUntil         *time.Time     `json:"locked_until"`
+	TwoFactorEnabled    bool           `json:"two_factor_enabled" gorm:"default:false"`
+	TwoFactorSecret     string         `json:"-"` // TOTP secret, set once enrollment is confirmed
+	TwoFactorPending    string         `json:"-"` // TOTP secret awaiting its first valid code
+	TwoFactorLastStep   int64          `json:"-"` // Last accepted TOTP time step, so a code can't be replayed
+	PasswordChangedAt   *time.Time     `json:"password_changed_at"`
+	IsServiceAccount    bool           `json:"is_service_account" gorm:"default:false"` // Integration account; signs in only with API keys
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Role is a named set of permissions assigned to users by name
+type Role struct {
+	ID               uint             `json:"id" gorm:"primaryKey"`
+	Name             string           `json:"name" gorm:"uniqueIndex;not null"`
+	Description      string           `json:"description"`
+	IsSystem         bool             `json:"is_system" gorm:"default:false"`          // Built-in roles can't be renamed or deleted
+	RequireTwoFactor bool             `json:"require_two_factor" gorm:"default:false"` // Holders must enroll in TOTP before they can sign in
+	Permissions      []RolePermission `json:"permissions" gorm:"foreignKey:RoleID"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+// RolePermission grants one permission to a role
+type RolePermission struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	RoleID     uint   `json:"role_id" gorm:"not null;uniqueIndex:idx_role_permission"`
+	Permission string `json:"permission" gorm:"not null;uniqueIndex:idx_role_permission"`
+}
+
+// UserSession is a login session; its rotating refresh token is stored hashed
+type UserSession struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"not null;index"`
+	User              User       `json:"-" gorm:"foreignKey:UserID"`
+	RefreshTokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"index"` // Last rotated-out token, kept to detect reuse
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"not null"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	IPAddress         string     `json:"ip_address"`
+	UserAgent         string     `json:"user_agent"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// TwoFactorRecoveryCode is a hashed single-use code that replaces a TOTP code when the device is lost
+type TwoFactorRecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// APIKey is a revocable key for a service account, sent in the X-API-Key header; only its hash is stored
+type APIKey struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	UserID      uint          `json:"user_id" gorm:"not null;index"` // The service account the key acts as
+	Name        string        `json:"name" gorm:"not null"`
+	Prefix      string        `json:"prefix" gorm:"index"` // First characters of the key, to recognise it in lists
+	KeyHash     string        `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes      []APIKeyScope `json:"scopes" gorm:"foreignKey:APIKeyID"`
+	ExpiresAt   *time.Time    `json:"expires_at"`
+	LastUsedAt  *time.Time    `json:"last_used_at"`
+	LastUsedIP  string        `json:"last_used_ip"`
+	CreatedByID uint          `json:"created_by_id"`
+	RevokedAt   *time.Time    `json:"revoked_at"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// APIKeyScope limits an API key to one permission; a key can use only permissions that are both
+// scoped on the key and granted to the service account's role
+type APIKeyScope struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	APIKeyID   uint   `json:"api_key_id" gorm:"not null;uniqueIndex:idx_api_key_scope"`
+	Permission string `json:"permission" gorm:"not null;uniqueIndex:idx_api_key_scope"`
+}
+
+// PasswordResetToken is a single-use, expiring token emailed by the forgot-password flow; only its hash is stored
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	IPAddress string     `json:"ip_address"` // Address that requested the reset
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Invitation is a single-use, expiring invite for someone to create an account with a given role
+type Invitation struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Email          string     `json:"email" gorm:"not null;index"`
+	Role           string     `json:"role" gorm:"not null"`
+	TokenHash      string     `json:"-" gorm:"uniqueIndex;not null"` // SHA-256 of the token sent to the invitee
+	InvitedByID    uint       `json:"invited_by_id" gorm:"not null"`
+	InvitedBy      User       `json:"invited_by" gorm:"foreignKey:InvitedByID"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uint      `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 // Product represents an inventory item
@@ -46,17 +152,17 @@ func (p *Product) GetTotalStock() int {
 }
 
 // GetLowestPrice returns the lowest selling price among suppliers
-func (p *Product) GetLowestPrice() float64 {
+func (p *Product) GetLowestPrice() decimal.Decimal {
 	if len(p.Suppliers) == 0 {
-		return 0
+		return decimal.Zero
 	}
 	
-	lowest := float64(0)
+	lowest := decimal.Zero
 	first := true
 	
 	for _, supplier := range p.Suppliers {
 		if supplier.IsActive {
-			if first || supplier.Price < lowest {
+			if first || supplier.Price.LessThan(lowest) {
 				lowest = supplier.Price
 				first = false
 			}
@@ -67,17 +173,17 @@ func (p *Product) GetLowestPrice() float64 {
 }
 
 // GetLowestCost returns the lowest cost among suppliers
-func (p *Product) GetLowestCost() float64 {
+func (p *Product) GetLowestCost() decimal.Decimal {
 	if len(p.Suppliers) == 0 {
-		return 0
+		return decimal.Zero
 	}
 	
-	lowest := float64(0)
+	lowest := decimal.Zero
 	first := true
 	
 	for _, supplier := range p.Suppliers {
 		if supplier.IsActive {
-			if first || supplier.Cost < lowest {
+			if first || supplier.Cost.LessThan(lowest) {
 				lowest = supplier.Cost
 				first = false
 			}
@@ -113,40 +219,49 @@ type StockMovement struct {
 
 // Sale represents a POS transaction
 type Sale struct {
-	ID            uint           `json:"id" gorm:"primaryKey"`
-	SaleNumber    string         `json:"sale_number" gorm:"unique;not null"`
-	UserID        uint           `json:"user_id" gorm:"not null"`
-	User          User           `json:"user" gorm:"foreignKey:UserID"`
-	CustomerName  string         `json:"customer_name"`
-	Subtotal      float64        `json:"subtotal" gorm:"not null"`
-	Tax           float64        `json:"tax" gorm:"default:0"`
-	Discount      float64        `json:"discount" gorm:"default:0"`
-	Total         float64        `json:"total" gorm:"not null"`
-	PaymentMethod string         `json:"payment_method" gorm:"not null"`     // cash, card, transfer, credit
-	PaymentDays   int            `json:"payment_days" gorm:"default:0"`      // Number of days for payment due (0 = immediate)
-	PaymentStatus string         `json:"payment_status" gorm:"default:paid"` // paid, pending, overdue
-	DownPayment   float64        `json:"down_payment" gorm:"default:0"`      // downpayment amount for credit sales
-	DueDate       *time.Time     `json:"due_date"`
-	PaidDate      *time.Time     `json:"paid_date"`
-	AmountPaid    float64        `json:"amount_paid" gorm:"default:0"`
-	AmountDue     float64        `json:"amount_due" gorm:"default:0"`
-	Status        string         `json:"status" gorm:"default:completed"` // pending, completed, cancelled
-	Items         []SaleItem     `json:"items" gorm:"foreignKey:SaleID"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	SaleNumber    string          `json:"sale_number" gorm:"unique;not null"`
+	UserID        uint            `json:"user_id" gorm:"not null"`
+	User          User            `json:"user" gorm:"foreignKey:UserID"`
+	CustomerName  string          `json:"customer_name"`
+	Subtotal      decimal.Decimal `json:"subtotal" gorm:"type:numeric(19,4);not null"`
+	Tax           decimal.Decimal `json:"tax" gorm:"type:numeric(19,4);default:0"`
+	Discount      decimal.Decimal `json:"discount" gorm:"type:numeric(19,4);default:0"`
+	Total         decimal.Decimal `json:"total" gorm:"type:numeric(19,4);not null"`
+	PaymentMethod string          `json:"payment_method" gorm:"not null"`                   // cash, card, transfer, credit, split
+	PaymentDays   int             `json:"payment_days" gorm:"default:0"`                    // Number of days for payment due (0 = immediate)
+	PaymentStatus string          `json:"payment_status" gorm:"default:paid"`               // paid, pending, overdue
+	DownPayment   decimal.Decimal `json:"down_payment" gorm:"type:numeric(19,4);default:0"` // downpayment amount for credit sales
+	DueDate       *time.Time      `json:"due_date"`
+	PaidDate      *time.Time      `json:"paid_date"`
+	AmountPaid    decimal.Decimal `json:"amount_paid" gorm:"type:numeric(19,4);default:0"`
+	AmountDue     decimal.Decimal `json:"amount_due" gorm:"type:numeric(19,4);default:0"`
+	ChangeGiven   decimal.Decimal `json:"change_given" gorm:"type:numeric(19,4);default:0"` // Cash handed back when cash tendered exceeded the total
+	Status        string          `json:"status" gorm:"default:completed"`                  // pending, completed, cancelled
+	ShiftID       *uint           `json:"shift_id" gorm:"index"`                            // Cashier shift the sale was rung up in
+	Items         []SaleItem      `json:"items" gorm:"foreignKey:SaleID"`
+	Payments      []SalePayment   `json:"payments,omitempty" gorm:"foreignKey:SaleID"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt  `json:"-" gorm:"index"`
 }
 
 // SaleItem represents items in a sale
 type SaleItem struct {
-	ID        uint    `json:"id" gorm:"primaryKey"`
-	SaleID    uint    `json:"sale_id" gorm:"not null"`
-	ProductID uint    `json:"product_id" gorm:"not null"`
-	Product   Product `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity  int     `json:"quantity" gorm:"not null"`
-	Price     float64 `json:"price" gorm:"not null"`
-	Cost      float64 `json:"cost" gorm:"not null"`
-	Total     float64 `json:"total" gorm:"not null"`
+	ID                   uint            `json:"id" gorm:"primaryKey"`
+	SaleID               uint            `json:"sale_id" gorm:"not null"`
+	ProductID            uint            `json:"product_id" gorm:"not null"`
+	Product              Product         `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity             int             `json:"quantity" gorm:"not null"`
+	Price                decimal.Decimal `json:"price" gorm:"type:numeric(19,4);not null"`
+	Cost                 decimal.Decimal `json:"cost" cost:"true" gorm:"type:numeric(19,4);not null"`
+	Total                decimal.Decimal `json:"total" gorm:"type:numeric(19,4);not null"`
+	ListPrice            decimal.Decimal `json:"list_price" gorm:"type:numeric(19,4);default:0"`            // Catalogue price before any override
+	ListCost             decimal.Decimal `json:"list_cost" cost:"true" gorm:"type:numeric(19,4);default:0"` // Catalogue cost before any override
+	PriceOverridden      bool            `json:"price_overridden" gorm:"default:false"`                     // Price or cost differs from the catalogue
+	OverrideApprovedByID *uint           `json:"override_approved_by_id"`                                   // Manager who approved the override
+	OverrideApprovedBy   *User           `json:"override_approved_by,omitempty" gorm:"foreignKey:OverrideApprovedByID"`
+	OverrideReason       string          `json:"override_reason"` // Why the override needed approval
 }
 
 // Supplier represents product suppliers
@@ -158,6 +273,7 @@ type Supplier struct {
 	Address       string         `json:"address"`
 	ContactPerson string         `json:"contact_person"`
 	Website       string         `json:"website"`
+	Currency      string         `json:"currency" gorm:"size:3"` // Invoicing currency; empty for the base currency
 	IsActive      bool           `json:"is_active" gorm:"default:true"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
@@ -173,17 +289,23 @@ type PurchaseOrder struct {
 	UserID        uint                `json:"user_id" gorm:"not null"`
 	User          User                `json:"user" gorm:"foreignKey:UserID"`
 	PaymentMethod string              `json:"payment_method" gorm:"default:net30"`   // cash, net7, net15, net30, net60, net90, credit
-	PaymentDays   int                 `json:"payment_days" gorm:"default:30"`       // Number of days for payment due
+	PaymentDays   int                 `json:"payment_days" gorm:"default:30"`        // Number of days for payment due
 	PaymentStatus string              `json:"payment_status" gorm:"default:pending"` // pending, paid, overdue
-	Total         float64             `json:"total" gorm:"not null"`
-	DownPayment   float64             `json:"down_payment" gorm:"default:0"` // downpayment amount for credit orders
-	AmountPaid    float64             `json:"amount_paid" gorm:"default:0"`
-	AmountDue     float64             `json:"amount_due" gorm:"default:0"`
+	Total         decimal.Decimal     `json:"total" gorm:"type:numeric(19,4);not null"`
+	Currency      string              `json:"currency" gorm:"size:3"`                            // Currency the amounts are in, the supplier's at order time
+	ExchangeRate  decimal.Decimal     `json:"exchange_rate" gorm:"type:numeric(19,8);default:1"` // Base currency per unit of Currency on the order date
+	TotalBase     decimal.Decimal     `json:"total_base" cost:"true" gorm:"type:numeric(19,4)"`  // Total in the base currency at ExchangeRate
+	DownPayment   decimal.Decimal     `json:"down_payment" gorm:"type:numeric(19,4);default:0"`  // downpayment amount for credit orders
+	AmountPaid    decimal.Decimal     `json:"amount_paid" gorm:"type:numeric(19,4);default:0"`
+	AmountDue     decimal.Decimal     `json:"amount_due" gorm:"type:numeric(19,4);default:0"`
 	DueDate       *time.Time          `json:"due_date"`
 	PaidDate      *time.Time          `json:"paid_date"`
 	Notes         string              `json:"notes"`
 	OrderDate     time.Time           `json:"order_date"`
+	ExpectedDate  *time.Time          `json:"expected_date"` // Delivery date promised by the supplier
 	ReceivedDate  *time.Time          `json:"received_date"`
+	Status        string              `json:"status" gorm:"default:draft"` // draft, sent
+	SentAt        *time.Time          `json:"sent_at"`
 	Items         []PurchaseOrderItem `json:"items" gorm:"foreignKey:PurchaseOrderID"`
 	CreatedAt     time.Time           `json:"created_at"`
 	UpdatedAt     time.Time           `json:"updated_at"`
@@ -192,61 +314,403 @@ type PurchaseOrder struct {
 
 // PurchaseOrderItem represents items in a purchase order
 type PurchaseOrderItem struct {
-	ID                 uint            `json:"id" gorm:"primaryKey"`
-	PurchaseOrderID    uint            `json:"purchase_order_id" gorm:"not null"`
-	ProductID          uint            `json:"product_id" gorm:"not null"`
-	Product            Product         `json:"product" gorm:"foreignKey:ProductID"`
-	ProductSupplierID  *uint           `json:"product_supplier_id"` // Link to specific supplier for this product
-	ProductSupplier    *ProductSupplier `json:"product_supplier" gorm:"foreignKey:ProductSupplierID"`
-	QuantityOrdered    int             `json:"quantity_ordered" gorm:"not null"`
-	QuantityReceived   int             `json:"quantity_received" gorm:"default:0"`
-	UnitCost           float64         `json:"unit_cost" gorm:"not null"`
-	Total              float64         `json:"total" gorm:"not null"`
+	ID                uint             `json:"id" gorm:"primaryKey"`
+	PurchaseOrderID   uint             `json:"purchase_order_id" gorm:"not null"`
+	ProductID         uint             `json:"product_id" gorm:"not null"`
+	Product           Product          `json:"product" gorm:"foreignKey:ProductID"`
+	ProductSupplierID *uint            `json:"product_supplier_id"` // Link to specific supplier for this product
+	ProductSupplier   *ProductSupplier `json:"product_supplier" gorm:"foreignKey:ProductSupplierID"`
+	QuantityOrdered   int              `json:"quantity_ordered" gorm:"not null"`
+	QuantityReceived  int              `json:"quantity_received" gorm:"default:0"`
+	QuantityReturned  int              `json:"quantity_returned" gorm:"default:0"` // Sent back to the supplier on purchase returns
+	UnitCost          decimal.Decimal  `json:"unit_cost" cost:"true" gorm:"type:numeric(19,4);not null"`
+	Total             decimal.Decimal  `json:"total" gorm:"type:numeric(19,4);not null"`
 }
 
 // PurchasePayment represents payment history for purchase orders
 type PurchasePayment struct {
-	ID              uint          `json:"id" gorm:"primaryKey"`
-	PurchaseOrderID uint          `json:"purchase_order_id" gorm:"not null"`
-	PurchaseOrder   PurchaseOrder `json:"purchase_order" gorm:"foreignKey:PurchaseOrderID"`
-	UserID          uint          `json:"user_id" gorm:"not null"`
-	User            User          `json:"user" gorm:"foreignKey:UserID"`
-	Amount          float64       `json:"amount" gorm:"not null"`
-	PaymentMethod   string        `json:"payment_method" gorm:"not null"`
-	PaymentType     string        `json:"payment_type" gorm:"not null"` // downpayment, payment, adjustment
-	Notes           string        `json:"notes"`
-	CreatedAt       time.Time     `json:"created_at"`
+	ID              uint            `json:"id" gorm:"primaryKey"`
+	PurchaseOrderID uint            `json:"purchase_order_id" gorm:"not null"`
+	PurchaseOrder   PurchaseOrder   `json:"purchase_order" gorm:"foreignKey:PurchaseOrderID"`
+	UserID          uint            `json:"user_id" gorm:"not null"`
+	User            User            `json:"user" gorm:"foreignKey:UserID"`
+	Amount          decimal.Decimal `json:"amount" gorm:"type:numeric(19,4);not null"`
+	PaymentMethod   string          `json:"payment_method" gorm:"not null"`
+	PaymentType     string          `json:"payment_type" gorm:"not null"`                       // downpayment, payment, adjustment, credit_note
+	ExchangeRate    decimal.Decimal `json:"exchange_rate" gorm:"type:numeric(19,8);default:1"`  // Base currency per unit of the order's currency when paid
+	AmountBase      decimal.Decimal `json:"amount_base" cost:"true" gorm:"type:numeric(19,4)"`  // Amount in the base currency at ExchangeRate
+	FXGainLoss      decimal.Decimal `json:"fx_gain_loss" cost:"true" gorm:"type:numeric(19,4)"` // Realised against the order's rate, in the base currency; positive is a gain
+	Notes           string          `json:"notes"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// PurchaseOrderSendLog records each attempt to email a purchase order to its supplier
+type PurchaseOrderSendLog struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	PurchaseOrderID uint      `json:"purchase_order_id" gorm:"not null;index"`
+	UserID          uint      `json:"user_id" gorm:"not null"`
+	User            User      `json:"user" gorm:"foreignKey:UserID"`
+	Recipient       string    `json:"recipient" gorm:"not null"`
+	Subject         string    `json:"subject"`
+	Status          string    `json:"status" gorm:"not null"` // sent, failed
+	Error           string    `json:"error"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// PurchaseReturn sends received goods of a purchase order back to the supplier. Creating it
+// deducts the stock and issues a credit note for the returned value.
+type PurchaseReturn struct {
+	ID              uint                 `json:"id" gorm:"primaryKey"`
+	ReturnNumber    string               `json:"return_number" gorm:"uniqueIndex;not null"`
+	PurchaseOrderID uint                 `json:"purchase_order_id" gorm:"not null;index"`
+	PurchaseOrder   *PurchaseOrder       `json:"purchase_order,omitempty" gorm:"foreignKey:PurchaseOrderID"`
+	SupplierID      uint                 `json:"supplier_id" gorm:"not null;index"`
+	Supplier        Supplier             `json:"supplier" gorm:"foreignKey:SupplierID"`
+	UserID          uint                 `json:"user_id" gorm:"not null"`
+	User            User                 `json:"user" gorm:"foreignKey:UserID"`
+	Reason          string               `json:"reason"`
+	Total           decimal.Decimal      `json:"total" gorm:"type:numeric(19,4);not null"`
+	Items           []PurchaseReturnItem `json:"items" gorm:"foreignKey:PurchaseReturnID"`
+	CreditNote      *SupplierCreditNote  `json:"credit_note,omitempty" gorm:"foreignKey:PurchaseReturnID"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
+
+// PurchaseReturnItem is the quantity of a purchase order line sent back, valued at its unit cost
+type PurchaseReturnItem struct {
+	ID                  uint            `json:"id" gorm:"primaryKey"`
+	PurchaseReturnID    uint            `json:"purchase_return_id" gorm:"not null;index"`
+	PurchaseOrderItemID uint            `json:"purchase_order_item_id" gorm:"not null;index"`
+	ProductID           uint            `json:"product_id" gorm:"not null"`
+	Product             Product         `json:"product" gorm:"foreignKey:ProductID"`
+	ProductSupplierID   *uint           `json:"product_supplier_id"`
+	Quantity            int             `json:"quantity" gorm:"not null"`
+	UnitCost            decimal.Decimal `json:"unit_cost" cost:"true" gorm:"type:numeric(19,4);not null"`
+	Total               decimal.Decimal `json:"total" gorm:"type:numeric(19,4);not null"`
+}
+
+// SupplierCreditNote is money a supplier owes us for returned goods. It is applied to what we owe
+// on purchase orders; the balance is what is left to apply.
+type SupplierCreditNote struct {
+	ID               uint                        `json:"id" gorm:"primaryKey"`
+	CreditNumber     string                      `json:"credit_number" gorm:"uniqueIndex;not null"`
+	SupplierID       uint                        `json:"supplier_id" gorm:"not null;index"`
+	Supplier         Supplier                    `json:"supplier" gorm:"foreignKey:SupplierID"`
+	PurchaseReturnID *uint                       `json:"purchase_return_id" gorm:"index"`
+	Amount           decimal.Decimal             `json:"amount" gorm:"type:numeric(19,4);not null"`
+	Balance          decimal.Decimal             `json:"balance" gorm:"type:numeric(19,4);not null"`
+	Currency         string                      `json:"currency" gorm:"size:3"`                    // Currency of the returned purchase order
+	Status           string                      `json:"status" gorm:"not null;default:open;index"` // open, applied
+	Notes            string                      `json:"notes"`
+	Applications     []SupplierCreditApplication `json:"applications,omitempty" gorm:"foreignKey:CreditNoteID"`
+	CreatedAt        time.Time                   `json:"created_at"`
+	UpdatedAt        time.Time                   `json:"updated_at"`
+}
+
+// SupplierCreditApplication records part of a credit note settling a purchase order
+type SupplierCreditApplication struct {
+	ID              uint            `json:"id" gorm:"primaryKey"`
+	CreditNoteID    uint            `json:"credit_note_id" gorm:"not null;index"`
+	PurchaseOrderID uint            `json:"purchase_order_id" gorm:"not null;index"`
+	UserID          uint            `json:"user_id" gorm:"not null"`
+	Amount          decimal.Decimal `json:"amount" gorm:"type:numeric(19,4);not null"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// ExchangeRate is the value of one unit of a foreign currency in the base currency from a date on
+type ExchangeRate struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	Currency      string          `json:"currency" gorm:"size:3;not null;uniqueIndex:idx_exchange_rate_day"`
+	BaseCurrency  string          `json:"base_currency" gorm:"size:3;not null;uniqueIndex:idx_exchange_rate_day"`
+	EffectiveDate time.Time       `json:"effective_date" gorm:"type:date;not null;uniqueIndex:idx_exchange_rate_day"`
+	Rate          decimal.Decimal `json:"rate" gorm:"type:numeric(19,8);not null"`
+	Source        string          `json:"source" gorm:"not null;default:manual"` // manual, import
+	UserID        uint            `json:"user_id" gorm:"not null"`
+	User          User            `json:"user" gorm:"foreignKey:UserID"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// RFQ is a request for quotation sent to several suppliers for the same goods. Their quotes are
+// compared and the winning supplier is awarded a purchase order.
+type RFQ struct {
+	ID                uint          `json:"id" gorm:"primaryKey"`
+	RFQNumber         string        `json:"rfq_number" gorm:"uniqueIndex;not null"`
+	UserID            uint          `json:"user_id" gorm:"not null"`
+	User              User          `json:"user" gorm:"foreignKey:UserID"`
+	Status            string        `json:"status" gorm:"not null;default:draft;index"` // draft, sent, awarded, cancelled
+	RespondBy         *time.Time    `json:"respond_by"`                                 // Deadline for supplier quotes
+	Notes             string        `json:"notes"`
+	SentAt            *time.Time    `json:"sent_at"`
+	AwardedSupplierID *uint         `json:"awarded_supplier_id"`
+	PurchaseOrderID   *uint         `json:"purchase_order_id" gorm:"index"` // Purchase order created by the award
+	Items             []RFQItem     `json:"items" gorm:"foreignKey:RFQID"`
+	Suppliers         []RFQSupplier `json:"suppliers" gorm:"foreignKey:RFQID"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// RFQItem is a product and quantity suppliers are asked to quote for
+type RFQItem struct {
+	ID        uint    `json:"id" gorm:"primaryKey"`
+	RFQID     uint    `json:"rfq_id" gorm:"not null;index"`
+	ProductID uint    `json:"product_id" gorm:"not null"`
+	Product   Product `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity  int     `json:"quantity" gorm:"not null"`
+}
+
+// RFQSupplier is a supplier invited to quote on an RFQ and where their response stands
+type RFQSupplier struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	RFQID      uint       `json:"rfq_id" gorm:"not null;uniqueIndex:idx_rfq_supplier"`
+	SupplierID uint       `json:"supplier_id" gorm:"not null;uniqueIndex:idx_rfq_supplier"`
+	Supplier   Supplier   `json:"supplier" gorm:"foreignKey:SupplierID"`
+	Status     string     `json:"status" gorm:"not null;default:pending"` // pending, sent, quoted, declined, awarded, lost
+	SentAt     *time.Time `json:"sent_at"`
+	QuotedAt   *time.Time `json:"quoted_at"`
+	Notes      string     `json:"notes"`
+	Quotes     []RFQQuote `json:"quotes,omitempty" gorm:"foreignKey:RFQSupplierID"`
+}
+
+// RFQQuote is a supplier's quoted unit cost and lead time for one RFQ item
+type RFQQuote struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	RFQSupplierID uint            `json:"rfq_supplier_id" gorm:"not null;index"`
+	RFQItemID     uint            `json:"rfq_item_id" gorm:"not null;index"`
+	UnitCost      decimal.Decimal `json:"unit_cost" cost:"true" gorm:"type:numeric(19,4);not null"`
+	LeadTimeDays  int             `json:"lead_time_days" gorm:"default:0"`
+	Notes         string          `json:"notes"`
 }
 
 // SalePayment represents payment history for sales
 type SalePayment struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	SaleID        uint      `json:"sale_id" gorm:"not null"`
-	Sale          Sale      `json:"sale" gorm:"foreignKey:SaleID"`
-	UserID        uint      `json:"user_id" gorm:"not null"`
-	User          User      `json:"user" gorm:"foreignKey:UserID"`
-	Amount        float64   `json:"amount" gorm:"not null"`
-	PaymentMethod string    `json:"payment_method" gorm:"not null"`
-	PaymentType   string    `json:"payment_type" gorm:"not null"` // downpayment, payment, adjustment
-	Notes         string    `json:"notes"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	SaleID        uint            `json:"sale_id" gorm:"not null"`
+	Sale          Sale            `json:"sale" gorm:"foreignKey:SaleID"`
+	UserID        uint            `json:"user_id" gorm:"not null"`
+	User          User            `json:"user" gorm:"foreignKey:UserID"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:numeric(19,4);not null"`
+	PaymentMethod string          `json:"payment_method" gorm:"not null"`
+	PaymentType   string          `json:"payment_type" gorm:"not null"`                 // tender, downpayment, payment, adjustment
+	Tendered      decimal.Decimal `json:"tendered" gorm:"type:numeric(19,4);default:0"` // Cash handed over before change; equals Amount for other methods
+	Notes         string          `json:"notes"`
+	ShiftID       *uint           `json:"shift_id" gorm:"index"` // Shift of the cashier who took the payment
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Shift is a cashier's till session, from the opening float to the counted close
+type Shift struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	UserID        uint             `json:"user_id" gorm:"not null;index;uniqueIndex:idx_shifts_open_user,where:status = 'open'"` // One open shift per user
+	User          User             `json:"user" gorm:"foreignKey:UserID"`
+	Status        string           `json:"status" gorm:"not null;default:open;index"` // open, closed
+	OpeningFloat  decimal.Decimal  `json:"opening_float" gorm:"type:numeric(19,4);default:0"`
+	OpenedAt      time.Time        `json:"opened_at"`
+	ClosedAt      *time.Time       `json:"closed_at"`
+	ClosedByID    *uint            `json:"closed_by_id"`
+	ClosedBy      *User            `json:"closed_by,omitempty" gorm:"foreignKey:ClosedByID"`
+	ExpectedCash  decimal.Decimal  `json:"expected_cash" gorm:"type:numeric(19,4);default:0"` // Set when the shift is closed
+	CountedCash   *decimal.Decimal `json:"counted_cash" gorm:"type:numeric(19,4)"`
+	CashVariance  decimal.Decimal  `json:"cash_variance" gorm:"type:numeric(19,4);default:0"` // Counted minus expected cash
+	Notes         string           `json:"notes"`
+	CashMovements []CashMovement   `json:"cash_movements,omitempty" gorm:"foreignKey:ShiftID"`
+	Totals        []ShiftTotal     `json:"totals,omitempty" gorm:"foreignKey:ShiftID"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// CashMovement is cash put into or taken out of the drawer during a shift for something other than a sale
+type CashMovement struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	ShiftID   uint            `json:"shift_id" gorm:"not null;index"`
+	UserID    uint            `json:"user_id" gorm:"not null"`
+	User      User            `json:"user" gorm:"foreignKey:UserID"`
+	Type      string          `json:"type" gorm:"not null"` // pay_in, pay_out
+	Amount    decimal.Decimal `json:"amount" gorm:"type:numeric(19,4);not null"`
+	Reason    string          `json:"reason" gorm:"not null"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ShiftTotal is the Z report line for one payment method, frozen when the shift is closed
+type ShiftTotal struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	ShiftID       uint             `json:"shift_id" gorm:"not null;uniqueIndex:idx_shift_total_method"`
+	PaymentMethod string           `json:"payment_method" gorm:"not null;uniqueIndex:idx_shift_total_method"`
+	Expected      decimal.Decimal  `json:"expected" gorm:"type:numeric(19,4);default:0"`
+	Counted       *decimal.Decimal `json:"counted" gorm:"type:numeric(19,4)"` // Nil when the method wasn't counted
+	Variance      decimal.Decimal  `json:"variance" gorm:"type:numeric(19,4);default:0"`
+}
+
+// Cart is a basket being rung up at a till. It can be parked while the customer fetches something,
+// resumed later and finalized into a Sale.
+type Cart struct {
+	ID           uint               `json:"id" gorm:"primaryKey"`
+	UserID       uint               `json:"user_id" gorm:"not null;index"`
+	User         User               `json:"user" gorm:"foreignKey:UserID"`
+	Till         string             `json:"till" gorm:"index"` // Register the cart was started on
+	CustomerName string             `json:"customer_name"`
+	Status       string             `json:"status" gorm:"not null;default:open;index"` // open, parked, finalized, cancelled, expired
+	Notes        string             `json:"notes"`
+	ParkedAt     *time.Time         `json:"parked_at"`
+	ExpiresAt    *time.Time         `json:"expires_at"` // Parked carts expire and release their stock after this
+	SaleID       *uint              `json:"sale_id"`    // Set when the cart is finalized
+	Items        []CartItem         `json:"items,omitempty" gorm:"foreignKey:CartID"`
+	Reservations []StockReservation `json:"reservations,omitempty" gorm:"foreignKey:CartID"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// CartItem is a line in a cart; it mirrors SaleItemRequest until the cart becomes a sale
+type CartItem struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	CartID     uint             `json:"cart_id" gorm:"not null;index"`
+	ProductID  uint             `json:"product_id" gorm:"not null"`
+	Product    Product          `json:"product" gorm:"foreignKey:ProductID"`
+	SupplierID *uint            `json:"supplier_id"` // Optional supplier selection
+	Quantity   int              `json:"quantity" gorm:"not null"`
+	Price      *decimal.Decimal `json:"price" gorm:"type:numeric(19,4)"`            // Optional price override
+	Cost       *decimal.Decimal `json:"cost" cost:"true" gorm:"type:numeric(19,4)"` // Optional cost override
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// StockReservation holds stock for a parked cart or a sales order line. It is soft: stock isn't
+// deducted, but other sales can't take it until the reservation is released or expires.
+type StockReservation struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	CartID            *uint      `json:"cart_id" gorm:"index"`
+	SalesOrderID      *uint      `json:"sales_order_id" gorm:"index"`
+	SalesOrderItemID  *uint      `json:"sales_order_item_id"`
+	ProductID         uint       `json:"product_id" gorm:"not null;index"`
+	ProductSupplierID *uint      `json:"product_supplier_id" gorm:"index"` // Nil when any supplier's stock will do
+	Quantity          int        `json:"quantity" gorm:"not null"`
+	ExpiresAt         *time.Time `json:"expires_at" gorm:"index"` // Nil for sales orders, which hold stock until shipped or cancelled
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// Quotation is a price quote for a customer. Once accepted it can be converted into a Sale.
+type Quotation struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	QuoteNumber   string          `json:"quote_number" gorm:"uniqueIndex;not null"`
+	UserID        uint            `json:"user_id" gorm:"not null"`
+	User          User            `json:"user" gorm:"foreignKey:UserID"`
+	CustomerName  string          `json:"customer_name" gorm:"not null"`
+	CustomerEmail string          `json:"customer_email"`
+	CustomerPhone string          `json:"customer_phone"`
+	Status        string          `json:"status" gorm:"not null;default:draft;index"` // draft, sent, accepted, rejected, expired, converted
+	ValidUntil    time.Time       `json:"valid_until"`                                // Last day the quoted prices hold
+	Subtotal      decimal.Decimal `json:"subtotal" gorm:"type:numeric(19,4);not null"`
+	Discount      decimal.Decimal `json:"discount" gorm:"type:numeric(19,4);default:0"`
+	Tax           decimal.Decimal `json:"tax" gorm:"type:numeric(19,4);default:0"`
+	Total         decimal.Decimal `json:"total" gorm:"type:numeric(19,4);not null"`
+	Notes         string          `json:"notes"`
+	SentAt        *time.Time      `json:"sent_at"`
+	AcceptedAt    *time.Time      `json:"accepted_at"`
+	SaleID        *uint           `json:"sale_id" gorm:"index"` // Sale the quotation was converted into
+	Sale          *Sale           `json:"sale,omitempty" gorm:"foreignKey:SaleID"`
+	Items         []QuotationItem `json:"items" gorm:"foreignKey:QuotationID"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// QuotationItem is a quoted line; its price becomes the sale price when the quotation is converted
+type QuotationItem struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	QuotationID uint            `json:"quotation_id" gorm:"not null;index"`
+	ProductID   uint            `json:"product_id" gorm:"not null"`
+	Product     Product         `json:"product" gorm:"foreignKey:ProductID"`
+	SupplierID  *uint           `json:"supplier_id"` // Optional supplier selection
+	Quantity    int             `json:"quantity" gorm:"not null"`
+	Price       decimal.Decimal `json:"price" gorm:"type:numeric(19,4);not null"`
+	Total       decimal.Decimal `json:"total" gorm:"type:numeric(19,4);not null"`
+}
+
+// SalesOrder is an order shipped later with one or more delivery notes. Its stock is reserved
+// when it is placed and deducted as it ships.
+type SalesOrder struct {
+	ID              uint             `json:"id" gorm:"primaryKey"`
+	OrderNumber     string           `json:"order_number" gorm:"uniqueIndex;not null"`
+	UserID          uint             `json:"user_id" gorm:"not null"`
+	User            User             `json:"user" gorm:"foreignKey:UserID"`
+	CustomerName    string           `json:"customer_name" gorm:"not null"`
+	CustomerPhone   string           `json:"customer_phone"`
+	DeliveryAddress string           `json:"delivery_address"`
+	Status          string           `json:"status" gorm:"not null;default:pending;index"` // pending, partially_shipped, delivered, cancelled
+	OrderDate       time.Time        `json:"order_date"`
+	RequestedDate   *time.Time       `json:"requested_date"` // When the customer wants the goods
+	Subtotal        decimal.Decimal  `json:"subtotal" gorm:"type:numeric(19,4);not null"`
+	Discount        decimal.Decimal  `json:"discount" gorm:"type:numeric(19,4);default:0"`
+	Tax             decimal.Decimal  `json:"tax" gorm:"type:numeric(19,4);default:0"`
+	Total           decimal.Decimal  `json:"total" gorm:"type:numeric(19,4);not null"`
+	Notes           string           `json:"notes"`
+	Items           []SalesOrderItem `json:"items" gorm:"foreignKey:SalesOrderID"`
+	DeliveryNotes   []DeliveryNote   `json:"delivery_notes,omitempty" gorm:"foreignKey:SalesOrderID"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+// SalesOrderItem is an ordered line and how much of it has shipped
+type SalesOrderItem struct {
+	ID                   uint             `json:"id" gorm:"primaryKey"`
+	SalesOrderID         uint             `json:"sales_order_id" gorm:"not null;index"`
+	ProductID            uint             `json:"product_id" gorm:"not null"`
+	Product              Product          `json:"product" gorm:"foreignKey:ProductID"`
+	ProductSupplierID    *uint            `json:"product_supplier_id"` // Ship from this supplier's stock; nil for any
+	ProductSupplier      *ProductSupplier `json:"product_supplier,omitempty" gorm:"foreignKey:ProductSupplierID"`
+	QuantityOrdered      int              `json:"quantity_ordered" gorm:"not null"`
+	QuantityShipped      int              `json:"quantity_shipped" gorm:"default:0"`
+	Price                decimal.Decimal  `json:"price" gorm:"type:numeric(19,4);not null"`
+	Total                decimal.Decimal  `json:"total" gorm:"type:numeric(19,4);not null"`
+	ListPrice            decimal.Decimal  `json:"list_price" gorm:"type:numeric(19,4);default:0"` // Catalogue price before any override
+	PriceOverridden      bool             `json:"price_overridden" gorm:"default:false"`          // Price differs from the catalogue
+	OverrideApprovedByID *uint            `json:"override_approved_by_id"`                        // Manager who approved the override
+	OverrideApprovedBy   *User            `json:"override_approved_by,omitempty" gorm:"foreignKey:OverrideApprovedByID"`
+	OverrideReason       string           `json:"override_reason"` // Why the override needed approval
+}
+
+// DeliveryNote is a shipment against a sales order; creating it deducts the shipped stock
+type DeliveryNote struct {
+	ID             uint               `json:"id" gorm:"primaryKey"`
+	DeliveryNumber string             `json:"delivery_number" gorm:"uniqueIndex;not null"`
+	SalesOrderID   uint               `json:"sales_order_id" gorm:"not null;index"`
+	UserID         uint               `json:"user_id" gorm:"not null"`
+	User           User               `json:"user" gorm:"foreignKey:UserID"`
+	ShippedAt      time.Time          `json:"shipped_at"`
+	Carrier        string             `json:"carrier"`
+	TrackingNumber string             `json:"tracking_number"`
+	Notes          string             `json:"notes"`
+	Items          []DeliveryNoteItem `json:"items" gorm:"foreignKey:DeliveryNoteID"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+// DeliveryNoteItem is the quantity of a sales order line shipped on a delivery note
+type DeliveryNoteItem struct {
+	ID               uint    `json:"id" gorm:"primaryKey"`
+	DeliveryNoteID   uint    `json:"delivery_note_id" gorm:"not null;index"`
+	SalesOrderItemID uint    `json:"sales_order_item_id" gorm:"not null;index"`
+	ProductID        uint    `json:"product_id" gorm:"not null"`
+	Product          Product `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity         int     `json:"quantity" gorm:"not null"`
 }
 
 // ProductSupplier represents the relationship between products and suppliers with pricing
 type ProductSupplier struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	ProductID  uint           `json:"product_id" gorm:"not null"`
-	Product    Product        `json:"product" gorm:"foreignKey:ProductID"`
-	SupplierID uint           `json:"supplier_id" gorm:"not null"`
-	Supplier   Supplier       `json:"supplier" gorm:"foreignKey:SupplierID"`
-	Cost       float64        `json:"cost" gorm:"not null"`        // Cost from this supplier
-	Price      float64        `json:"price" gorm:"not null"`       // Selling price for this supplier's stock
-	Stock      int            `json:"stock" gorm:"default:0"`      // Current stock from this supplier
-	MinStock   int            `json:"min_stock" gorm:"default:10"` // Minimum stock for this supplier
-	IsActive   bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	ProductID     uint            `json:"product_id" gorm:"not null"`
+	Product       Product         `json:"product" gorm:"foreignKey:ProductID"`
+	SupplierID    uint            `json:"supplier_id" gorm:"not null"`
+	Supplier      Supplier        `json:"supplier" gorm:"foreignKey:SupplierID"`
+	Cost          decimal.Decimal `json:"cost" cost:"true" gorm:"type:numeric(19,4);not null"` // Cost from this supplier
+	Price         decimal.Decimal `json:"price" gorm:"type:numeric(19,4);not null"`            // Selling price for this supplier's stock
+	Stock         int             `json:"stock" gorm:"default:0"`                              // Current stock from this supplier
+	MinStock      int             `json:"min_stock" gorm:"default:10"`                         // Minimum stock for this supplier
+	LeadTimeDays  int             `json:"lead_time_days" gorm:"default:0"`                     // Days from ordering to delivery
+	MinOrderQty   int             `json:"min_order_qty" gorm:"default:0"`                      // Smallest quantity the supplier accepts; 0 for no minimum
+	OrderMultiple int             `json:"order_multiple" gorm:"default:1"`                     // Pack size order quantities must be a multiple of
+	IsPreferred   bool            `json:"is_preferred" gorm:"default:false"`                   // Reorder from this supplier first
+	IsActive      bool            `json:"is_active" gorm:"default:true"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt  `json:"-" gorm:"index"`
 }
 
 // ActivityLog represents system activity logs
@@ -263,6 +727,52 @@ type ActivityLog struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+// AuditLog records one change to an entity with a field-level before/after diff.
+// Entries are written in the same transaction as the change they describe.
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ActorID    *uint     `json:"actor_id" gorm:"index"` // Nil for unauthenticated actions such as password resets
+	ActorEmail string    `json:"actor_email"`
+	APIKeyID   *uint     `json:"api_key_id"` // Set when the actor used an API key
+	Action     string    `json:"action" gorm:"not null;index"`
+	EntityType string    `json:"entity_type" gorm:"not null;index:idx_audit_entity"`
+	EntityID   uint      `json:"entity_id" gorm:"index:idx_audit_entity"`
+	Changes    JSONText  `json:"changes" gorm:"type:text"` // {"field": {"before": ..., "after": ...}}
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// JSONText is JSON kept in a text column and written as raw JSON in API responses
+type JSONText string
+
+// MarshalJSON emits the stored JSON as is
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// Value stores the JSON as text
+func (j JSONText) Value() (driver.Value, error) {
+	return string(j), nil
+}
+
+// Scan reads the JSON from a text column
+func (j *JSONText) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = ""
+	case string:
+		*j = JSONText(v)
+	case []byte:
+		*j = JSONText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONText", value)
+	}
+	return nil
+}
+
 // CompanyProfile represents company information for invoices and system branding
 type CompanyProfile struct {
 	ID              uint           `json:"id" gorm:"primaryKey"`
@@ -283,3 +793,28 @@ type CompanyProfile struct {
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+
+// ProductImportJob records a bulk product import and its results
+type ProductImportJob struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null"`
+	User         User       `json:"user" gorm:"foreignKey:UserID"`
+	FileName     string     `json:"file_name"`
+	Format       string     `json:"format"`                        // csv, xlsx
+	DryRun       bool       `json:"dry_run"`
+	Status       string     `json:"status" gorm:"default:pending"` // pending, validated, completed, failed
+	TotalRows    int        `json:"total_rows"`
+	CreatedCount int        `json:"created_count"`
+	UpdatedCount int        `json:"updated_count"`
+	ErrorCount   int        `json:"error_count"`
+	Errors       string     `json:"errors" gorm:"type:text"` // JSON array of row-level errors
+	Message      string     `json:"message"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+// SchemaMigration records a one-off data migration that has run, so startup doesn't run it again
+type SchemaMigration struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	AppliedAt time.Time `json:"applied_at"`
+}
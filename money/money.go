@@ -0,0 +1,74 @@
+package money
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	// Amounts stay JSON numbers in the API rather than quoted strings
+	decimal.MarshalJSONWithoutQuotes = true
+}
+
+// ColumnType is the PostgreSQL type of every amount column. Four decimals hold unit prices finer than
+// the currency and the three-decimal currencies; totals are rounded to the currency before saving.
+const ColumnType = "numeric(19,4)"
+
+// UnitScale is the number of decimals kept on unit prices and costs
+const UnitScale = 4
+
+// RateScale is the number of decimals kept on exchange rates, stored as numeric(19,8)
+const RateScale = 8
+
+// defaultScale is the number of decimals of currencies not listed in scales
+const defaultScale = 2
+
+// scales lists the currencies whose minor unit isn't a hundredth (ISO 4217)
+var scales = map[string]int32{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Scale returns the number of decimals amounts in currency are settled in
+func Scale(currency string) int32 {
+	if scale, ok := scales[strings.ToUpper(currency)]; ok {
+		return scale
+	}
+	return defaultScale
+}
+
+// Round rounds an amount to the minor unit of currency, halves away from zero
+func Round(currency string, amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(Scale(currency))
+}
+
+// RoundUnit rounds a unit price or cost to UnitScale decimals
+func RoundUnit(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(UnitScale)
+}
+
+// RoundRate rounds an exchange rate to RateScale decimals
+func RoundRate(rate decimal.Decimal) decimal.Decimal {
+	return rate.Round(RateScale)
+}
+
+// Format writes an amount with exactly the decimals of currency, e.g. 12.50 or 1250
+func Format(currency string, amount decimal.Decimal) string {
+	return amount.StringFixed(Scale(currency))
+}
+
+// LineTotal is the amount of quantity units at a unit price, rounded to currency
+func LineTotal(currency string, quantity int, unitPrice decimal.Decimal) decimal.Decimal {
+	return Round(currency, unitPrice.Mul(decimal.NewFromInt(int64(quantity))))
+}
+
+// Sum adds up amounts
+func Sum(amounts ...decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total
+}
@@ -0,0 +1,109 @@
+package money
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// testCurrencies covers every scale: none, two and three decimals
+var testCurrencies = []string{"JPY", "USD", "IDR", "KWD"}
+
+// randomUnitPrice returns a price of up to 10,000 with UnitScale decimals, negative when negative is set
+func randomUnitPrice(rng *rand.Rand, negative bool) decimal.Decimal {
+	price := decimal.New(rng.Int63n(100_000_000), -UnitScale)
+	if negative && rng.Intn(2) == 0 {
+		return price.Neg()
+	}
+	return price
+}
+
+// atScale reports whether amount has no more decimals than scale
+func atScale(amount decimal.Decimal, scale int32) bool {
+	return amount.Round(scale).Equal(amount)
+}
+
+func TestScale(t *testing.T) {
+	tests := map[string]int32{"JPY": 0, "jpy": 0, "USD": 2, "IDR": 2, "XYZ": 2, "KWD": 3, "bhd": 3}
+	for currency, want := range tests {
+		if got := Scale(currency); got != want {
+			t.Errorf("Scale(%q) = %d, want %d", currency, got, want)
+		}
+	}
+}
+
+func TestRoundHalvesAwayFromZero(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   string
+		want     string
+	}{
+		{"JPY", "2.5", "3"},
+		{"JPY", "-2.5", "-3"},
+		{"JPY", "2.4999", "2"},
+		{"USD", "0.125", "0.13"},
+		{"USD", "-0.125", "-0.13"},
+		{"USD", "0.1249", "0.12"},
+		{"KWD", "0.0005", "0.001"},
+		{"KWD", "1.2344", "1.234"},
+	}
+	for _, tt := range tests {
+		got := Round(tt.currency, decimal.RequireFromString(tt.amount))
+		if !got.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("Round(%s, %s) = %s, want %s", tt.currency, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestRoundProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, currency := range testCurrencies {
+		scale := Scale(currency)
+		half := decimal.New(5, -scale-1)
+		for i := 0; i < 1000; i++ {
+			amount := randomUnitPrice(rng, true)
+			rounded := Round(currency, amount)
+
+			if !atScale(rounded, scale) {
+				t.Fatalf("Round(%s, %s) = %s has more than %d decimals", currency, amount, rounded, scale)
+			}
+			if rounded.Sub(amount).Abs().GreaterThan(half) {
+				t.Fatalf("Round(%s, %s) = %s is more than half a minor unit off", currency, amount, rounded)
+			}
+			if !Round(currency, rounded).Equal(rounded) {
+				t.Fatalf("Round(%s, %s) isn't idempotent", currency, rounded)
+			}
+			if !Round(currency, amount.Neg()).Equal(rounded.Neg()) {
+				t.Fatalf("Round(%s, %s) isn't symmetric around zero", currency, amount)
+			}
+			if got := Format(currency, rounded); !decimal.RequireFromString(got).Equal(rounded) {
+				t.Fatalf("Format(%s, %s) = %s", currency, rounded, got)
+			}
+		}
+	}
+}
+
+func TestLineTotalProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, currency := range testCurrencies {
+		scale := Scale(currency)
+		half := decimal.New(5, -scale-1)
+		for i := 0; i < 1000; i++ {
+			price := RoundUnit(randomUnitPrice(rng, false))
+			quantity := 1 + rng.Intn(1000)
+			total := LineTotal(currency, quantity, price)
+
+			if !atScale(total, scale) {
+				t.Fatalf("LineTotal(%s, %d, %s) = %s has more than %d decimals", currency, quantity, price, total, scale)
+			}
+			exact := price.Mul(decimal.NewFromInt(int64(quantity)))
+			if total.Sub(exact).Abs().GreaterThan(half) {
+				t.Fatalf("LineTotal(%s, %d, %s) = %s is more than half a minor unit off %s", currency, quantity, price, total, exact)
+			}
+			if total.IsNegative() {
+				t.Fatalf("LineTotal(%s, %d, %s) = %s is negative", currency, quantity, price, total)
+			}
+		}
+	}
+}
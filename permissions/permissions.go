@@ -0,0 +1,107 @@
+package permissions
+
+// Named permissions checked by middleware.RequirePermission and handlers
+const (
+	ProductsView    = "products.view"
+	ProductsManage  = "products.manage"
+	ProductsImport  = "products.import"
+	ProductsDelete  = "products.delete"
+	ProductsCost    = "products.cost.view"
+	StockView       = "stock.view"
+	StockAdjust     = "stock.adjust"
+	SalesCreate     = "sales.create"
+	SalesView       = "sales.view"
+	SalesVoid       = "sales.void"
+	SalesDelete     = "sales.delete"
+	SalesPayment    = "sales.payment"
+	SalesOverride   = "sales.override.approve"
+	ShiftsManage    = "shifts.manage"
+	QuotesView      = "quotations.view"
+	QuotesManage    = "quotations.manage"
+	OrdersManage    = "sales_orders.manage"
+	SuppliersView   = "suppliers.view"
+	SuppliersManage = "suppliers.manage"
+	POView          = "po.view"
+	POManage        = "po.manage"
+	POPay           = "po.pay"
+	DashboardView   = "dashboard.view"
+	CompanyManage   = "company.manage"
+	UsersManage     = "users.manage"
+	RolesManage     = "roles.manage"
+	SystemManage    = "system.manage"
+)
+
+// AdminRole is the built-in role that always holds every permission
+const AdminRole = "admin"
+
+// Permission describes a permission for the role editor
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// All lists every permission known to the system
+var All = []Permission{
+	{ProductsView, "View products, categories and stock levels"},
+	{ProductsManage, "Create and edit products and their supplier links"},
+	{ProductsImport, "Bulk import products from CSV or XLSX"},
+	{ProductsDelete, "Delete products"},
+	{ProductsCost, "See product and supplier costs and margins"},
+	{StockView, "View stock movements"},
+	{StockAdjust, "Adjust supplier stock levels"},
+	{SalesCreate, "Ring up sales at the POS"},
+	{SalesView, "View sales, receipts and sales reports"},
+	{SalesVoid, "Void sales"},
+	{SalesDelete, "Delete sales"},
+	{SalesPayment, "Record payments against credit sales"},
+	{SalesOverride, "Approve POS price overrides"},
+	{ShiftsManage, "View and close every cashier's shift"},
+	{QuotesView, "View quotations and print them"},
+	{QuotesManage, "Create, send and convert quotations"},
+	{OrdersManage, "Create, ship and cancel sales orders"},
+	{SuppliersView, "View suppliers"},
+	{SuppliersManage, "Create, edit and delete suppliers"},
+	{POView, "View purchase orders"},
+	{POManage, "Create, edit, send and delete purchase orders"},
+	{POPay, "Record purchase order payments"},
+	{DashboardView, "View the admin dashboard and reports"},
+	{CompanyManage, "Edit the company profile"},
+	{UsersManage, "Manage users, sessions and invitations"},
+	{RolesManage, "Edit roles and their permissions"},
+	{SystemManage, "Change system settings, view logs and manage backups"},
+}
+
+// Defaults are the permission sets seeded for the built-in roles
+var Defaults = map[string][]string{
+	"employee": {
+		ProductsView, StockView, SalesCreate, SalesView, QuotesView, SuppliersView,
+	},
+	"manager": {
+		ProductsView, ProductsManage, ProductsImport, ProductsCost,
+		StockView, StockAdjust,
+		SalesCreate, SalesView, SalesVoid, SalesDelete, SalesPayment, SalesOverride, ShiftsManage,
+		QuotesView, QuotesManage, OrdersManage,
+		SuppliersView, SuppliersManage,
+		POView, POManage, POPay,
+	},
+	AdminRole: Names(),
+}
+
+// Names returns the names of every permission
+func Names() []string {
+	names := make([]string, len(All))
+	for i, permission := range All {
+		names[i] = permission.Name
+	}
+	return names
+}
+
+// Valid reports whether name is a known permission
+func Valid(name string) bool {
+	for _, permission := range All {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,15 +1,48 @@
 package routes
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
 	"inventory_system/handlers"
 	"inventory_system/middleware"
+	"inventory_system/models"
+	"inventory_system/permissions"
 
 	"github.com/gin-gonic/gin"
 )
 
+// costResponses are the response types whose cost:"true" fields are hidden from users without
+// products.cost.view; the types they contain are covered too
+var costResponses = []interface{}{
+	models.Product{}, models.Sale{}, models.Cart{}, models.PurchaseOrder{}, models.PurchasePayment{},
+	models.PurchaseReturn{}, models.RFQ{},
+	handlers.DashboardStats{}, handlers.RFQItemComparison{}, handlers.FXCurrencySummary{}, handlers.FXPayment{},
+	handlers.SupplierScorecard{}, handlers.ReorderSuggestion{}, handlers.ReorderSupplierSummary{},
+}
+
+// trustedProxies returns the proxies from TRUSTED_PROXIES (comma-separated IPs or CIDRs) whose
+// X-Forwarded-For header gives the client IP. Without it no proxy is trusted and the client IP is
+// the peer address, so clients can't pick their own IP to dodge the login limiter.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 // SetupRoutes configures all API routes
 func SetupRoutes() *gin.Engine {
 	router := gin.Default()
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Add CORS middleware
 	router.Use(middleware.CORSMiddleware())
@@ -17,6 +50,7 @@ func SetupRoutes() *gin.Engine {
 	// Serve static files
 	router.Static("/static", "./templates")
 	router.StaticFile("/login.html", "./templates/login.html")
+	router.StaticFile("/reset-password.html", "./templates/reset_password.html")
 	router.StaticFile("/admin-dashboard.html", "./templates/admin_dashboard.html")
 	router.StaticFile("/dashboard.html", "./templates/employee_dashboard.html")
 	// Add routes for new pages
@@ -44,128 +78,272 @@ func SetupRoutes() *gin.Engine {
 	// API version 1
 	v1 := router.Group("/api/v1")
 
+	// Failed credential checks per client IP: progressive delays after 5, a 15 minute block at 20.
+	// A successful login doesn't clear them, or one valid account would reset the IP between guesses
+	// at others; each account's own failures are cleared by its login instead.
+	authLimiter := middleware.NewAttemptLimiter(5, 20, 15*time.Minute, 15*time.Minute)
+	authLimiter.KeepOnSuccess = true
+	// Wrong passwords or codes when changing an approval PIN or 2FA settings, per user
+	profileLimiter := middleware.NewAttemptLimiter(2, 5, 15*time.Minute, 15*time.Minute)
+	perUser := func(c *gin.Context) string { return fmt.Sprintf("user:%d", c.GetUint("user_id")) }
+
 	// Public routes (no authentication required)
 	auth := v1.Group("/auth")
+	auth.Use(middleware.LimitFailures(authLimiter, nil))
 	{
 		auth.POST("/login", handlers.Login)
 		auth.POST("/register", handlers.Register)
+		auth.POST("/2fa/verify", handlers.VerifyTwoFactor)
+		auth.POST("/2fa/enroll", handlers.EnrollTwoFactor)
+		auth.POST("/accept-invite", handlers.AcceptInvitation)
+		auth.POST("/forgot-password", handlers.ForgotPassword)
+		auth.POST("/reset-password", handlers.ResetPassword)
+		auth.POST("/refresh", handlers.RefreshToken)
+		auth.POST("/logout", handlers.Logout)
 	}
 
-	// Protected routes (authentication required)
+	// Protected routes (authentication required); each route names the permission it needs
 	protected := v1.Group("/")
 	protected.Use(middleware.AuthMiddleware())
-	protected.Use(middleware.ActivityLogger()) // Log user activities
+	protected.Use(middleware.ActivityLogger())              // Log user activities
+	protected.Use(middleware.RedactCosts(costResponses...)) // Hide costs and margins without products.cost.view
 	{
 		// User profile
 		protected.GET("/profile", handlers.GetProfile)
+		protected.GET("/profile/permissions", handlers.GetMyPermissions)
+		protected.PUT("/profile/approval-pin", middleware.RequirePermission(permissions.SalesOverride),
+			middleware.LimitFailures(profileLimiter, perUser), handlers.SetApprovalPIN)
+		protected.PUT("/profile/password", middleware.LimitFailures(profileLimiter, perUser), handlers.ChangePassword)
+		protected.POST("/profile/2fa/setup", middleware.LimitFailures(profileLimiter, perUser), handlers.SetupTwoFactor)
+		protected.POST("/profile/2fa/confirm", middleware.LimitFailures(profileLimiter, perUser), handlers.ConfirmTwoFactor)
+		protected.POST("/profile/2fa/disable", middleware.LimitFailures(profileLimiter, perUser), handlers.DisableTwoFactor)
+		protected.POST("/profile/2fa/recovery-codes", middleware.LimitFailures(profileLimiter, perUser), handlers.RegenerateRecoveryCodes)
 
-		// Products (all authenticated users can view)
+		// Products
 		products := protected.Group("/products")
 		{
-			products.GET("", handlers.GetProducts)
-			products.GET("/search", handlers.SearchProducts)
-			products.GET("/categories", handlers.GetProductCategories)
-			products.GET("/low-stock", handlers.GetLowStockProducts)
-			products.GET("/:id", handlers.GetProduct)
-			products.GET("/:id/suppliers", handlers.GetProductSuppliers)
+			products.GET("", middleware.RequirePermission(permissions.ProductsView), handlers.GetProducts)
+			products.GET("/search", middleware.RequirePermission(permissions.ProductsView), handlers.SearchProducts)
+			products.GET("/categories", middleware.RequirePermission(permissions.ProductsView), handlers.GetProductCategories)
+			products.GET("/low-stock", middleware.RequirePermission(permissions.ProductsView), handlers.GetLowStockProducts)
+			products.GET("/export", middleware.RequirePermission(permissions.ProductsView), handlers.ExportProducts)
+			products.GET("/:id", middleware.RequirePermission(permissions.ProductsView), handlers.GetProduct)
+			products.GET("/:id/suppliers", middleware.RequirePermission(permissions.ProductsView), handlers.GetProductSuppliers)
+
+			products.POST("", middleware.RequirePermission(permissions.ProductsManage), handlers.CreateProduct)
+			products.PUT("/:id", middleware.RequirePermission(permissions.ProductsManage), handlers.UpdateProduct)
+			products.DELETE("/:id", middleware.RequirePermission(permissions.ProductsDelete), handlers.DeleteProduct)
+			products.POST("/import", middleware.RequirePermission(permissions.ProductsImport), handlers.ImportProducts)
+			products.GET("/import/jobs", middleware.RequirePermission(permissions.ProductsImport), handlers.GetProductImportJobs)
+			products.GET("/import/jobs/:id", middleware.RequirePermission(permissions.ProductsImport), handlers.GetProductImportJob)
+			products.POST("/:id/suppliers", middleware.RequirePermission(permissions.ProductsManage), handlers.AddProductSupplier)
+			products.PUT("/:id/suppliers/:supplier_id", middleware.RequirePermission(permissions.ProductsManage), handlers.UpdateProductSupplier)
+			products.DELETE("/:id/suppliers/:supplier_id", middleware.RequirePermission(permissions.ProductsManage), handlers.RemoveProductSupplier)
+			products.POST("/:id/suppliers/:supplier_id/adjust-stock", middleware.RequirePermission(permissions.StockAdjust), handlers.AdjustSupplierStock)
 		}
 
-		// POS (all authenticated users can make sales)
+		// POS
 		pos := protected.Group("/pos")
 		{
-			pos.POST("/sales", handlers.CreateSale)
-			pos.GET("/sales", handlers.GetSales)
-			pos.GET("/sales/:id", handlers.GetSale)
-			pos.GET("/sales/:id/payments", handlers.GetSalePayments)
-			pos.GET("/sales/summary", handlers.GetSalesSummary)
-			pos.GET("/sales/export", handlers.ExportSales)
-			pos.GET("/sales/export-excel", handlers.ExportSalesExcel)
-			pos.GET("/reports", handlers.GetSalesReport)
-			pos.GET("/sales/overdue", handlers.GetOverdueSales)
+			pos.POST("/sales", middleware.RequirePermission(permissions.SalesCreate), handlers.CreateSale)
+			pos.GET("/sales", middleware.RequirePermission(permissions.SalesView), handlers.GetSales)
+			pos.GET("/sales/:id", middleware.RequirePermission(permissions.SalesView), handlers.GetSale)
+			pos.GET("/sales/:id/payments", middleware.RequirePermission(permissions.SalesView), handlers.GetSalePayments)
+			pos.GET("/sales/:id/invoice.pdf", middleware.RequirePermission(permissions.SalesView), handlers.GetSaleInvoicePDF)
+			pos.GET("/sales/:id/receipt.pdf", middleware.RequirePermission(permissions.SalesView), handlers.GetSaleReceiptPDF)
+			pos.GET("/sales/summary", middleware.RequirePermission(permissions.SalesView), handlers.GetSalesSummary)
+			pos.GET("/sales/export", middleware.RequirePermission(permissions.SalesView), handlers.ExportSales)
+			pos.GET("/sales/export-excel", middleware.RequirePermission(permissions.SalesView), handlers.ExportSalesExcel)
+			pos.GET("/reports", middleware.RequirePermission(permissions.SalesView), handlers.GetSalesReport)
+			pos.GET("/sales/overdue", middleware.RequirePermission(permissions.SalesView), handlers.GetOverdueSales)
+
+			pos.PUT("/sales/:id/void", middleware.RequirePermission(permissions.SalesVoid), handlers.VoidSale)
+			pos.DELETE("/sales/:id", middleware.RequirePermission(permissions.SalesDelete), handlers.DeleteSale)
+			pos.POST("/sales/:id/payment", middleware.RequirePermission(permissions.SalesPayment), handlers.RecordSalePayment)
+
+			// Cashier shifts; shifts.manage is checked in the handlers for other cashiers' shifts
+			pos.POST("/shifts", middleware.RequirePermission(permissions.SalesCreate), handlers.OpenShift)
+			pos.GET("/shifts", middleware.RequirePermission(permissions.SalesCreate), handlers.GetShifts)
+			pos.GET("/shifts/current", middleware.RequirePermission(permissions.SalesCreate), handlers.GetCurrentShift)
+			pos.GET("/shifts/:id", middleware.RequirePermission(permissions.SalesCreate), handlers.GetShiftReport)
+			pos.POST("/shifts/:id/cash-movements", middleware.RequirePermission(permissions.SalesCreate), handlers.AddCashMovement)
+			pos.POST("/shifts/:id/close", middleware.RequirePermission(permissions.SalesCreate), handlers.CloseShift)
+
+			// Parked carts, shared by every cashier at a till
+			pos.POST("/carts", middleware.RequirePermission(permissions.SalesCreate), handlers.CreateCart)
+			pos.GET("/carts", middleware.RequirePermission(permissions.SalesCreate), handlers.GetCarts)
+			pos.GET("/carts/:id", middleware.RequirePermission(permissions.SalesCreate), handlers.GetCart)
+			pos.POST("/carts/:id/items", middleware.RequirePermission(permissions.SalesCreate), handlers.AddCartItem)
+			pos.DELETE("/carts/:id/items/:item_id", middleware.RequirePermission(permissions.SalesCreate), handlers.RemoveCartItem)
+			pos.POST("/carts/:id/park", middleware.RequirePermission(permissions.SalesCreate), handlers.ParkCart)
+			pos.POST("/carts/:id/resume", middleware.RequirePermission(permissions.SalesCreate), handlers.ResumeCart)
+			pos.POST("/carts/:id/finalize", middleware.RequirePermission(permissions.SalesCreate), handlers.FinalizeCart)
+			pos.DELETE("/carts/:id", middleware.RequirePermission(permissions.SalesCreate), handlers.CancelCart)
+		}
+
+		// Quotations
+		quotations := protected.Group("/quotations")
+		{
+			quotations.GET("", middleware.RequirePermission(permissions.QuotesView), handlers.GetQuotations)
+			quotations.GET("/:id", middleware.RequirePermission(permissions.QuotesView), handlers.GetQuotation)
+			quotations.GET("/:id/pdf", middleware.RequirePermission(permissions.QuotesView), handlers.GetQuotationPDF)
+
+			quotations.POST("", middleware.RequirePermission(permissions.QuotesManage), handlers.CreateQuotation)
+			quotations.PUT("/:id", middleware.RequirePermission(permissions.QuotesManage), handlers.UpdateQuotation)
+			quotations.DELETE("/:id", middleware.RequirePermission(permissions.QuotesManage), handlers.DeleteQuotation)
+			quotations.POST("/:id/send", middleware.RequirePermission(permissions.QuotesManage), handlers.SendQuotation)
+			quotations.POST("/:id/accept", middleware.RequirePermission(permissions.QuotesManage), handlers.AcceptQuotation)
+			quotations.POST("/:id/reject", middleware.RequirePermission(permissions.QuotesManage), handlers.RejectQuotation)
+			quotations.POST("/:id/convert", middleware.RequirePermission(permissions.QuotesManage, permissions.SalesCreate), handlers.ConvertQuotation)
 		}
 
-		// Stock movements (view only for employees)
-		protected.GET("/stock-movements", handlers.GetStockMovements)
-		
+		// Sales orders, shipped later with delivery notes
+		salesOrders := protected.Group("/sales-orders")
+		{
+			salesOrders.GET("", middleware.RequirePermission(permissions.SalesView), handlers.GetSalesOrders)
+			salesOrders.GET("/:id", middleware.RequirePermission(permissions.SalesView), handlers.GetSalesOrder)
+			salesOrders.GET("/:id/delivery-notes/:note_id/pdf", middleware.RequirePermission(permissions.SalesView), handlers.GetDeliveryNotePDF)
+
+			salesOrders.POST("", middleware.RequirePermission(permissions.OrdersManage), handlers.CreateSalesOrder)
+			salesOrders.POST("/:id/delivery-notes", middleware.RequirePermission(permissions.OrdersManage), handlers.CreateDeliveryNote)
+			salesOrders.POST("/:id/cancel", middleware.RequirePermission(permissions.OrdersManage), handlers.CancelSalesOrder)
+		}
+
+		// Stock movements
+		protected.GET("/stock-movements", middleware.RequirePermission(permissions.StockView), handlers.GetStockMovements)
+		protected.GET("/stock-movements/export", middleware.RequirePermission(permissions.StockView), handlers.ExportStockMovements)
+
 		// Company profile for invoices (accessible to all authenticated users)
 		protected.GET("/company-profile/invoice", handlers.GetCompanyProfileForInvoice)
-		
-		// Suppliers (view only for employees)
+
+		// Suppliers
 		suppliers := protected.Group("/suppliers")
 		{
-			suppliers.GET("", handlers.GetSuppliers)
-			suppliers.GET("/:id", handlers.GetSupplier)
-			suppliers.GET("/search", handlers.SearchSuppliers)
+			suppliers.GET("", middleware.RequirePermission(permissions.SuppliersView), handlers.GetSuppliers)
+			suppliers.GET("/:id", middleware.RequirePermission(permissions.SuppliersView), handlers.GetSupplier)
+			suppliers.GET("/search", middleware.RequirePermission(permissions.SuppliersView), handlers.SearchSuppliers)
+			suppliers.GET("/export", middleware.RequirePermission(permissions.SuppliersView), handlers.ExportSuppliers)
+			suppliers.GET("/:id/credit-notes", middleware.RequirePermission(permissions.POView), handlers.GetSupplierCreditNotes)
+			suppliers.GET("/:id/scorecard", middleware.RequirePermission(permissions.SuppliersView, permissions.POView), handlers.GetSupplierScorecard)
+			suppliers.GET("/scorecards/export", middleware.RequirePermission(permissions.SuppliersView, permissions.POView), handlers.ExportSupplierScorecards)
+
+			suppliers.POST("", middleware.RequirePermission(permissions.SuppliersManage), handlers.CreateSupplier)
+			suppliers.PUT("/:id", middleware.RequirePermission(permissions.SuppliersManage), handlers.UpdateSupplier)
+			suppliers.DELETE("/:id", middleware.RequirePermission(permissions.SuppliersManage), handlers.DeleteSupplier)
+		}
+
+		// Purchase orders
+		purchaseOrders := protected.Group("/purchase-orders")
+		{
+			purchaseOrders.GET("", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseOrders)
+			purchaseOrders.GET("/:id", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseOrder)
+			purchaseOrders.GET("/overdue", middleware.RequirePermission(permissions.POView), handlers.GetOverduePurchaseOrders)
+			purchaseOrders.GET("/summary", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseOrdersSummary)
+			purchaseOrders.GET("/export", middleware.RequirePermission(permissions.POView), handlers.ExportPurchaseOrders)
+			purchaseOrders.GET("/reorder-suggestions", middleware.RequirePermission(permissions.POView), handlers.GetReorderSuggestions)
+			purchaseOrders.GET("/fx-report", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseFXReport)
+			purchaseOrders.GET("/:id/payments", middleware.RequirePermission(permissions.POView), handlers.GetPurchasePaymentHistory)
+			purchaseOrders.GET("/:id/pdf", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseOrderPDF)
+			purchaseOrders.GET("/:id/sends", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseOrderSendLogs)
+
+			purchaseOrders.POST("", middleware.RequirePermission(permissions.POManage), handlers.CreatePurchaseOrder)
+			purchaseOrders.PUT("/:id", middleware.RequirePermission(permissions.POManage), handlers.UpdatePurchaseOrder)
+			purchaseOrders.POST("/:id/send", middleware.RequirePermission(permissions.POManage), handlers.SendPurchaseOrder)
+			purchaseOrders.POST("/:id/receive", middleware.RequirePermission(permissions.POManage), handlers.ReceivePurchaseOrder)
+			purchaseOrders.DELETE("/:id", middleware.RequirePermission(permissions.POManage), handlers.DeletePurchaseOrder)
+			purchaseOrders.POST("/:id/payment", middleware.RequirePermission(permissions.POPay), handlers.RecordPurchasePayment)
+			purchaseOrders.POST("/:id/apply-credit", middleware.RequirePermission(permissions.POPay), handlers.ApplySupplierCredit)
 		}
 
-		// Manager level routes
-		manager := protected.Group("/")
-		manager.Use(middleware.ManagerMiddleware())
+		// Exchange rates of supplier currencies into the base currency
+		exchangeRates := protected.Group("/exchange-rates")
 		{
-			// Product management
-			manager.POST("/products", handlers.CreateProduct)
-			manager.PUT("/products/:id", handlers.UpdateProduct)
-			manager.POST("/products/:id/suppliers", handlers.AddProductSupplier)
-			manager.PUT("/products/:id/suppliers/:supplier_id", handlers.UpdateProductSupplier)
-			manager.DELETE("/products/:id/suppliers/:supplier_id", handlers.RemoveProductSupplier)
-			manager.POST("/products/:id/suppliers/:supplier_id/adjust-stock", handlers.AdjustSupplierStock)
-			
-			// Supplier management
-			manager.POST("/suppliers", handlers.CreateSupplier)
-			manager.PUT("/suppliers/:id", handlers.UpdateSupplier)
-			manager.DELETE("/suppliers/:id", handlers.DeleteSupplier)
-
-			// Sales management
-			manager.PUT("/pos/sales/:id/void", handlers.VoidSale)
-			manager.DELETE("/pos/sales/:id", handlers.DeleteSale)
-			manager.POST("/pos/sales/:id/payment", handlers.RecordSalePayment)
-
-			// Purchase order management
-			manager.POST("/purchase-orders", handlers.CreatePurchaseOrder)
-			manager.GET("/purchase-orders", handlers.GetPurchaseOrders)
-			manager.GET("/purchase-orders/:id", handlers.GetPurchaseOrder)
-			manager.PUT("/purchase-orders/:id", handlers.UpdatePurchaseOrder)
-			manager.GET("/purchase-orders/overdue", handlers.GetOverduePurchaseOrders)
-			manager.GET("/purchase-orders/summary", handlers.GetPurchaseOrdersSummary)
-			manager.POST("/purchase-orders/:id/payment", handlers.RecordPurchasePayment)
-			manager.GET("/purchase-orders/:id/payments", handlers.GetPurchasePaymentHistory)
-			manager.DELETE("/purchase-orders/:id", handlers.DeletePurchaseOrder)
+			exchangeRates.GET("", middleware.RequirePermission(permissions.POView), handlers.GetExchangeRates)
 
+			exchangeRates.POST("", middleware.RequirePermission(permissions.POManage), handlers.CreateExchangeRate)
+			exchangeRates.POST("/import", middleware.RequirePermission(permissions.POManage), handlers.ImportExchangeRates)
+			exchangeRates.DELETE("/:id", middleware.RequirePermission(permissions.POManage), handlers.DeleteExchangeRate)
 		}
 
-		// Admin only routes
+		// Requests for quotation to suppliers
+		rfqs := protected.Group("/rfqs")
+		{
+			rfqs.GET("", middleware.RequirePermission(permissions.POView), handlers.GetRFQs)
+			rfqs.GET("/:id", middleware.RequirePermission(permissions.POView), handlers.GetRFQ)
+			rfqs.GET("/:id/pdf", middleware.RequirePermission(permissions.POView), handlers.GetRFQPDF)
+			rfqs.GET("/:id/comparison", middleware.RequirePermission(permissions.POView), handlers.CompareRFQ)
+
+			rfqs.POST("", middleware.RequirePermission(permissions.POManage), handlers.CreateRFQ)
+			rfqs.POST("/:id/send", middleware.RequirePermission(permissions.POManage), handlers.SendRFQ)
+			rfqs.PUT("/:id/suppliers/:supplier_id/quote", middleware.RequirePermission(permissions.POManage), handlers.SubmitRFQQuote)
+			rfqs.POST("/:id/award", middleware.RequirePermission(permissions.POManage), handlers.AwardRFQ)
+			rfqs.POST("/:id/cancel", middleware.RequirePermission(permissions.POManage), handlers.CancelRFQ)
+		}
+
+		// Returns to suppliers
+		purchaseReturns := protected.Group("/purchase-returns")
+		{
+			purchaseReturns.GET("", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseReturns)
+			purchaseReturns.GET("/:id", middleware.RequirePermission(permissions.POView), handlers.GetPurchaseReturn)
+			purchaseReturns.GET("/:id/credit-note/pdf", middleware.RequirePermission(permissions.POView), handlers.GetCreditNotePDF)
+
+			purchaseReturns.POST("", middleware.RequirePermission(permissions.POManage), handlers.CreatePurchaseReturn)
+		}
+
+		// Administration
 		admin := protected.Group("/admin")
-		admin.Use(middleware.AdminMiddleware())
 		{
 			// Dashboard
-			admin.GET("/dashboard/stats", handlers.GetDashboardStats)
-			admin.GET("/dashboard/sales-report", handlers.GetAdminSalesReport)
-			admin.GET("/system/logs", handlers.GetSystemLogs)
-			admin.GET("/users/:id/activity", handlers.GetUserActivity)
+			admin.GET("/dashboard/stats", middleware.RequirePermission(permissions.DashboardView), handlers.GetDashboardStats)
+			admin.GET("/dashboard/sales-report", middleware.RequirePermission(permissions.DashboardView), handlers.GetAdminSalesReport)
 
 			// System management
-			admin.PUT("/system/settings", handlers.UpdateSystemSettings)
-			
+			admin.GET("/system/logs", middleware.RequirePermission(permissions.SystemManage), handlers.GetSystemLogs)
+			admin.GET("/audit", middleware.RequirePermission(permissions.SystemManage), handlers.GetAuditLogs)
+			admin.PUT("/system/settings", middleware.RequirePermission(permissions.SystemManage), handlers.UpdateSystemSettings)
+			admin.POST("/system/backup", middleware.RequirePermission(permissions.SystemManage), handlers.BackupDatabase)
+			admin.POST("/system/restore", middleware.RequirePermission(permissions.SystemManage), handlers.RestoreDatabase)
+			admin.GET("/system/backups", middleware.RequirePermission(permissions.SystemManage), handlers.ListBackups)
+			admin.DELETE("/system/backups/:filename", middleware.RequirePermission(permissions.SystemManage), handlers.DeleteBackup)
+
 			// Company profile management
-			admin.GET("/company-profile", handlers.GetCompanyProfile)
-			admin.POST("/company-profile", handlers.CreateOrUpdateCompanyProfile)
-			admin.PUT("/company-profile", handlers.CreateOrUpdateCompanyProfile)
-			admin.PUT("/company-profile/logo", handlers.UpdateCompanyLogo)
-			admin.DELETE("/company-profile/:id", handlers.DeleteCompanyProfile)
-			admin.POST("/system/backup", handlers.BackupDatabase)
-			admin.POST("/system/restore", handlers.RestoreDatabase)
-			admin.GET("/system/backups", handlers.ListBackups)
-			admin.DELETE("/system/backups/:filename", handlers.DeleteBackup)
+			admin.GET("/company-profile", middleware.RequirePermission(permissions.CompanyManage), handlers.GetCompanyProfile)
+			admin.POST("/company-profile", middleware.RequirePermission(permissions.CompanyManage), handlers.CreateOrUpdateCompanyProfile)
+			admin.PUT("/company-profile", middleware.RequirePermission(permissions.CompanyManage), handlers.CreateOrUpdateCompanyProfile)
+			admin.PUT("/company-profile/logo", middleware.RequirePermission(permissions.CompanyManage), handlers.UpdateCompanyLogo)
+			admin.DELETE("/company-profile/:id", middleware.RequirePermission(permissions.CompanyManage), handlers.DeleteCompanyProfile)
 
 			// User management
-			admin.GET("/users", handlers.GetUsers)
-			admin.POST("/users", handlers.Register) // Admin can create users
-			admin.GET("/users/:id", handlers.GetUser)
-			admin.PUT("/users/:id", handlers.UpdateUser)
-			admin.DELETE("/users/:id", handlers.DeleteUser)
-
-			// Product management (admin can delete)
-			admin.DELETE("/products/:id", handlers.DeleteProduct)
+			admin.GET("/users", middleware.RequirePermission(permissions.UsersManage), handlers.GetUsers)
+			admin.POST("/users", middleware.RequirePermission(permissions.UsersManage), handlers.CreateUser)
+			admin.GET("/users/:id", middleware.RequirePermission(permissions.UsersManage), handlers.GetUser)
+			admin.PUT("/users/:id", middleware.RequirePermission(permissions.UsersManage), handlers.UpdateUser)
+			admin.DELETE("/users/:id", middleware.RequirePermission(permissions.UsersManage), handlers.DeleteUser)
+			admin.POST("/users/:id/unlock", middleware.RequirePermission(permissions.UsersManage), handlers.UnlockUser)
+			admin.DELETE("/users/:id/2fa", middleware.RequirePermission(permissions.UsersManage), handlers.ResetTwoFactor)
+			admin.GET("/users/:id/activity", middleware.RequirePermission(permissions.UsersManage), handlers.GetUserActivity)
+			admin.GET("/users/:id/sessions", middleware.RequirePermission(permissions.UsersManage), handlers.GetUserSessions)
+			admin.DELETE("/users/:id/sessions", middleware.RequirePermission(permissions.UsersManage), handlers.RevokeUserSessions)
+			admin.DELETE("/sessions/:id", middleware.RequirePermission(permissions.UsersManage), handlers.RevokeSession)
+			admin.GET("/service-accounts", middleware.RequirePermission(permissions.UsersManage), handlers.GetServiceAccounts)
+			admin.POST("/service-accounts", middleware.RequirePermission(permissions.UsersManage), handlers.CreateServiceAccount)
+			admin.DELETE("/service-accounts/:id", middleware.RequirePermission(permissions.UsersManage), handlers.DeleteServiceAccount)
+			admin.GET("/service-accounts/:id/keys", middleware.RequirePermission(permissions.UsersManage), handlers.GetAPIKeys)
+			admin.POST("/service-accounts/:id/keys", middleware.RequirePermission(permissions.UsersManage), handlers.CreateAPIKey)
+			admin.DELETE("/api-keys/:id", middleware.RequirePermission(permissions.UsersManage), handlers.RevokeAPIKey)
+			admin.POST("/invitations", middleware.RequirePermission(permissions.UsersManage), handlers.CreateInvitation)
+			admin.GET("/invitations", middleware.RequirePermission(permissions.UsersManage), handlers.GetInvitations)
+			admin.DELETE("/invitations/:id", middleware.RequirePermission(permissions.UsersManage), handlers.RevokeInvitation)
+
+			// Roles and permissions
+			admin.GET("/permissions", middleware.RequirePermission(permissions.RolesManage), handlers.GetPermissions)
+			admin.GET("/roles", middleware.RequirePermission(permissions.RolesManage), handlers.GetRoles)
+			admin.POST("/roles", middleware.RequirePermission(permissions.RolesManage), handlers.CreateRole)
+			admin.PUT("/roles/:id", middleware.RequirePermission(permissions.RolesManage), handlers.UpdateRole)
+			admin.DELETE("/roles/:id", middleware.RequirePermission(permissions.RolesManage), handlers.DeleteRole)
+
+			// Product management (kept for existing clients; same as DELETE /products/:id)
+			admin.DELETE("/products/:id", middleware.RequirePermission(permissions.ProductsDelete), handlers.DeleteProduct)
 		}
 	}
 
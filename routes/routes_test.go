@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"testing"
+
+	"inventory_system/middleware"
+)
+
+func TestCostResponsesCoverCostFields(t *testing.T) {
+	fields := middleware.CostFields(costResponses...)
+
+	for _, name := range []string{
+		"cost", "list_cost", "unit_cost", "total_profit", "today_profit",
+		"current_cost", "lowest_cost_supplier_id", "first_cost", "last_cost", "returned_value",
+		"total_base", "amount_base", "fx_gain_loss", "estimated_total", "unit_cost_base",
+	} {
+		if !fields[name] {
+			t.Errorf("no response type tags %q as a cost", name)
+		}
+	}
+	for _, name := range []string{"total", "price", "amount", "amount_paid", "amount_due", "quantity"} {
+		if fields[name] {
+			t.Errorf("%q is tagged as a cost but is shared with sales", name)
+		}
+	}
+}
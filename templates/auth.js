@@ -0,0 +1,65 @@
+// Keeps pages signed in with short-lived access tokens.
+// API calls that fail with 401 refresh the token pair once and are retried.
+(function () {
+    const originalFetch = window.fetch.bind(window);
+    let refreshing = null;
+
+    function refreshTokens() {
+        const refreshToken = localStorage.getItem('refresh_token');
+        if (!refreshToken) {
+            return Promise.resolve(false);
+        }
+        if (!refreshing) {
+            refreshing = originalFetch('/api/v1/auth/refresh', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: refreshToken })
+            })
+                .then(async (response) => {
+                    if (!response.ok) {
+                        localStorage.removeItem('refresh_token');
+                        return false;
+                    }
+                    const result = await response.json();
+                    localStorage.setItem('token', result.data.token);
+                    localStorage.setItem('refresh_token', result.data.refresh_token);
+                    localStorage.setItem('user', JSON.stringify(result.data.user));
+                    return true;
+                })
+                .catch(() => false)
+                .finally(() => { refreshing = null; });
+        }
+        return refreshing;
+    }
+
+    window.fetch = async function (input, init) {
+        const response = await originalFetch(input, init);
+        const url = typeof input === 'string' ? input : input.url;
+        const headers = new Headers((init && init.headers) || (typeof input === 'string' ? undefined : input.headers));
+
+        if (response.status !== 401 || !headers.has('Authorization') || url.includes('/auth/')) {
+            return response;
+        }
+        if (!(await refreshTokens())) {
+            return response;
+        }
+
+        headers.set('Authorization', `Bearer ${localStorage.getItem('token')}`);
+        return originalFetch(input, Object.assign({}, init, { headers }));
+    };
+
+    // Revokes the server-side session; called by each page's logout()
+    window.revokeSession = function () {
+        const refreshToken = localStorage.getItem('refresh_token');
+        localStorage.removeItem('refresh_token');
+        if (!refreshToken) {
+            return;
+        }
+        originalFetch('/api/v1/auth/logout', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ refresh_token: refreshToken }),
+            keepalive: true
+        }).catch(() => {});
+    };
+})();